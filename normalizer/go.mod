@@ -0,0 +1,5 @@
+module normalizer
+
+go 1.17
+
+require golang.org/x/text v0.13.0
@@ -0,0 +1,51 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Values for -address-case
+const (
+	// Address is left as it came
+	addressCaseOff = "off"
+	// Title case, keeping directions and unit designators uppercase
+	addressCaseSmart = "smart"
+)
+
+// The words -address-case smart keeps uppercase by default
+var defaultAddressUpper = []string{"N", "S", "E", "W", "NE", "NW", "SE", "SW", "APT", "STE", "PO"}
+
+// smartAddress title-cases each word of address, except the ones in upper,
+// which are uppercased, and anything with a digit in it (123, 4B, 1st), which
+// is left exactly as it was. Punctuation around a word doesn't stop it
+// matching, so "apt." is found as APT. The spacing is kept as it was
+func smartAddress(address string, upper []string) string {
+	isUpper := make(map[string]bool, len(upper))
+	for _, u := range upper {
+		isUpper[strings.ToLower(u)] = true
+	}
+
+	words := strings.Split(address, " ")
+	for i, word := range words {
+		if strings.IndexFunc(word, unicode.IsDigit) >= 0 {
+			continue
+		}
+		lower := strings.ToLower(word)
+		if isUpper[strings.TrimFunc(lower, unicode.IsPunct)] {
+			words[i] = strings.ToUpper(word)
+			continue
+		}
+		words[i] = titleWord(lower)
+	}
+	return strings.Join(words, " ")
+}
+
+func checkAddressCase(mode string) error {
+	switch mode {
+	case addressCaseOff, addressCaseSmart:
+		return nil
+	}
+	return fmt.Errorf("unknown -address-case %q", mode)
+}
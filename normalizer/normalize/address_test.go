@@ -0,0 +1,51 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSmartAddress(t *testing.T) {
+	tests := []struct {
+		in    string
+		upper []string
+		want  string
+	}{
+		{"123 nw main st apt 4b", defaultAddressUpper, "123 NW Main St APT 4b"},
+		{"PO BOX 12", defaultAddressUpper, "PO Box 12"},
+		{"1 elm st. apt. 2", defaultAddressUpper, "1 Elm St. APT. 2"},
+		{"1st AVE  n", defaultAddressUpper, "1st Ave  N"},
+		{"", defaultAddressUpper, ""},
+		{"9 rue de la paix", []string{"RUE"}, "9 RUE De La Paix"},
+		{"5 se oak", nil, "5 Se Oak"},
+	}
+	for _, tt := range tests {
+		if got := smartAddress(tt.in, tt.upper); got != tt.want {
+			t.Errorf("smartAddress(%q, %v) = %q, want %q", tt.in, tt.upper, got, tt.want)
+		}
+	}
+}
+
+func TestAddressCaseFlag(t *testing.T) {
+	row := strings.Replace(testRow, "123 4th St", "123 nw 4th st apt 4b", 1)
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"off", nil, "123 nw 4th st apt 4b"},
+		{"smart", []string{"-address-case", "smart"}, "123 NW 4th St APT 4b"},
+		{"own words", []string{"-address-case", "smart", "-address-upper", "ST"}, "123 Nw 4th ST Apt 4b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := outputRecords(t, testConfig(t, tt.args...), testHeader+row)
+			if len(records) != 2 || records[1][1] != tt.want {
+				t.Errorf("got %q, want Address %q", records, tt.want)
+			}
+		})
+	}
+	if err := configError(t, "-address-case", "loud"); err == nil {
+		t.Error("expected an error for -address-case loud")
+	}
+}
@@ -0,0 +1,120 @@
+package normalize
+
+import (
+	"io"
+	"time"
+)
+
+// Batch is a group of normalized records laid out column by column, the way
+// columnar writers like Arrow want them: one slice per column, with entry i
+// of each slice from the same record
+type Batch struct {
+	// Output column names, the canonical ones and then any extras
+	Columns []string
+	// Values[c] holds column Columns[c] for every record in the batch
+	Values [][]string
+	// What Normalize parsed, so typed columns don't have to be parsed back
+	// out of the strings. Zero for a record whose step was turned off
+	Timestamps     []time.Time
+	TotalDurations []time.Duration
+}
+
+func newBatch(columns []string, size int) *Batch {
+	b := &Batch{
+		Columns:        columns,
+		Values:         make([][]string, len(columns)),
+		Timestamps:     make([]time.Time, 0, size),
+		TotalDurations: make([]time.Duration, 0, size),
+	}
+	for c := range b.Values {
+		b.Values[c] = make([]string, 0, size)
+	}
+	return b
+}
+
+// Len is the number of records in the batch
+func (b *Batch) Len() int {
+	return len(b.Timestamps)
+}
+
+// Column returns the values for the named column, or nil if there isn't one
+func (b *Batch) Column(name string) []string {
+	for c, column := range b.Columns {
+		if column == name {
+			return b.Values[c]
+		}
+	}
+	return nil
+}
+
+func (b *Batch) add(r *Record, extra []string) {
+	for c, value := range r.Row(extra) {
+		b.Values[c] = append(b.Values[c], value)
+	}
+	b.Timestamps = append(b.Timestamps, r.timestamp)
+	b.TotalDurations = append(b.TotalDurations, r.totalDuration)
+}
+
+// batchSink is the Sink behind TransformBatches. It hands each full batch to
+// emit, and whatever's left over when it's flushed or closed
+type batchSink struct {
+	columns []string
+	extra   []string
+	size    int
+	emit    func(*Batch) error
+	batch   *Batch
+	// The first error from emit. After one we stop emitting and drop
+	// records, and report it at Close
+	err error
+}
+
+func (s *batchSink) WriteHeader(columns []string) error {
+	// The columns are known up front, and a Batch always carries them anyway
+	return nil
+}
+
+func (s *batchSink) WriteRecord(r *Record) error {
+	if s.err != nil {
+		return nil
+	}
+	if s.batch == nil {
+		s.batch = newBatch(s.columns, s.size)
+	}
+	s.batch.add(r, s.extra)
+	if s.batch.Len() >= s.size {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *batchSink) Flush() error {
+	if s.batch == nil || s.err != nil {
+		return nil
+	}
+	batch := s.batch
+	// emit owns the batch now, so start a fresh one rather than reusing it
+	s.batch = nil
+	s.err = s.emit(batch)
+	return nil
+}
+
+func (s *batchSink) Close() error {
+	s.Flush()
+	return s.err
+}
+
+// TransformBatches is Transform for columnar consumers: instead of writing
+// CSV it groups the normalized records into batches of up to size records and
+// calls emit with each one. The last batch can be short. emit can keep the
+// batch it's given. Once it returns an error no more batches are emitted,
+// and TransformBatches returns that error when it's done reading. Rejected
+// rows are warned about on stderr, the same as Transform
+func TransformBatches(r io.Reader, size int, emit func(*Batch) error) error {
+	if size < 1 {
+		size = 1
+	}
+	cfg := DefaultConfig()
+	cfg.batchSize = size
+	cfg.batchEmit = emit
+	return transform(cfg, r, io.Discard, nil)
+}
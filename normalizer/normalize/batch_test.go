@@ -0,0 +1,96 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTransformBatches(t *testing.T) {
+	var in strings.Builder
+	in.WriteString(testHeader)
+	for i := 0; i < 5; i++ {
+		in.WriteString(strings.Replace(testRow, "notes", fmt.Sprint("note ", i), 1))
+	}
+	in.WriteString(strings.Replace(testRow, "1:23:32.123", "soon", 1))
+
+	tests := []struct {
+		size  int
+		sizes []int
+	}{
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+		{10, []int{5}},
+		{0, []int{1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.size), func(t *testing.T) {
+			var batches []*Batch
+			err := TransformBatches(strings.NewReader(in.String()), tt.size, func(b *Batch) error {
+				batches = append(batches, b)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var sizes []int
+			var notes []string
+			for _, b := range batches {
+				sizes = append(sizes, b.Len())
+				notes = append(notes, b.Column("Notes")...)
+				if len(b.Values) != len(b.Columns) || len(b.TotalDurations) != b.Len() {
+					t.Errorf("batch has %d columns of values for %d names", len(b.Values), len(b.Columns))
+				}
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.sizes) {
+				t.Errorf("batch sizes %v, want %v", sizes, tt.sizes)
+			}
+			// In order, with the rejected row left out
+			if got := strings.Join(notes, ","); got != "note 0,note 1,note 2,note 3,note 4" {
+				t.Errorf("Notes = %s", got)
+			}
+		})
+	}
+}
+
+func TestBatchTypedColumns(t *testing.T) {
+	var batch *Batch
+	err := TransformBatches(strings.NewReader(testHeader+testRow), 10, func(b *Batch) error {
+		batch = b
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2011, 4, 1, 18, 0, 0, 0, time.UTC)
+	if !batch.Timestamps[0].Equal(want) {
+		t.Errorf("Timestamp = %v, want %v", batch.Timestamps[0], want)
+	}
+	if got := batch.TotalDurations[0]; got != 10565246*time.Millisecond {
+		t.Errorf("TotalDuration = %v", got)
+	}
+	if got := batch.Column("ZIP"); len(got) != 1 || got[0] != "94121" {
+		t.Errorf("ZIP = %q", got)
+	}
+	if batch.Column("Nope") != nil {
+		t.Error("got values for a column that isn't there")
+	}
+}
+
+func TestTransformBatchesEmitError(t *testing.T) {
+	in := testHeader + testRow + testRow + testRow
+	stop := errors.New("stop")
+	calls := 0
+	err := TransformBatches(strings.NewReader(in), 1, func(b *Batch) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("got %v, want the emit error", err)
+	}
+	if calls != 1 {
+		t.Errorf("emit called %d times after failing, want 1", calls)
+	}
+}
@@ -0,0 +1,95 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Values for -bool-output
+const (
+	boolOutputWords  = "words"
+	boolOutputDigits = "digits"
+)
+
+// Values for -bool-invalid
+const (
+	boolInvalidError       = "error"
+	boolInvalidPassthrough = "passthrough"
+)
+
+// The default -bool-true and -bool-false tokens
+var (
+	defaultBoolTrue  = []string{"true", "t", "yes", "y", "1", "on"}
+	defaultBoolFalse = []string{"false", "f", "no", "n", "0", "off"}
+)
+
+// normalizeBool reads value as true or false, going by the tokens, ignoring
+// case and surrounding spaces, and writes it the way output says. Empty stays
+// empty. ok is false if it's neither
+func normalizeBool(value string, truthy, falsey []string, output string) (normalized string, ok bool) {
+	key := strings.TrimSpace(value)
+	if key == "" {
+		return "", true
+	}
+	is := func(tokens []string) bool {
+		for _, token := range tokens {
+			if strings.EqualFold(key, token) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case is(truthy):
+		if output == boolOutputDigits {
+			return "1", true
+		}
+		return "true", true
+	case is(falsey):
+		if output == boolOutputDigits {
+			return "0", true
+		}
+		return "false", true
+	}
+	return value, false
+}
+
+// checkBool checks the -bool-* settings
+func checkBool(c *Config) error {
+	switch c.BoolOutput {
+	case boolOutputWords, boolOutputDigits:
+	default:
+		return fmt.Errorf("unknown -bool-output %q", c.BoolOutput)
+	}
+	switch c.BoolInvalid {
+	case boolInvalidError, boolInvalidPassthrough:
+	default:
+		return fmt.Errorf("unknown -bool-invalid %q", c.BoolInvalid)
+	}
+	for _, name := range c.BoolColumns {
+		if columnIndex(name) >= 0 {
+			return fmt.Errorf("-bool-columns is for input columns we don't already write, not %s", name)
+		}
+	}
+	for _, t := range c.BoolTrue {
+		for _, f := range c.BoolFalse {
+			if strings.EqualFold(strings.TrimSpace(t), strings.TrimSpace(f)) {
+				return fmt.Errorf("%q can't be in both -bool-true and -bool-false", t)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeBools turns each of the -bool-columns, which newRecord copied in
+// from the input, into true or false
+func (r *Record) normalizeBools(cfg *Config) error {
+	for _, name := range cfg.BoolColumns {
+		value, ok := normalizeBool(r.Extra[name], cfg.BoolTrue, cfg.BoolFalse, cfg.BoolOutput)
+		if !ok && cfg.BoolInvalid == boolInvalidError {
+			return &FieldError{Field: name, Value: value, Err: ErrBool}
+		}
+		r.setExtra(name, value)
+	}
+	return nil
+}
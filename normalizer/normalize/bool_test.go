@@ -0,0 +1,82 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBool(t *testing.T) {
+	tests := []struct {
+		in     string
+		output string
+		want   string
+		ok     bool
+	}{
+		{"Y", boolOutputWords, "true", true},
+		{" no ", boolOutputWords, "false", true},
+		{"1", boolOutputWords, "true", true},
+		{"OFF", boolOutputDigits, "0", true},
+		{"yes", boolOutputDigits, "1", true},
+		{"", boolOutputWords, "", true},
+		{"  ", boolOutputDigits, "", true},
+		{"maybe", boolOutputWords, "maybe", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeBool(tt.in, defaultBoolTrue, defaultBoolFalse, tt.output)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeBool(%q, %s) = %q, %v, want %q, %v", tt.in, tt.output, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBoolColumnsFlag(t *testing.T) {
+	header := strings.TrimSuffix(testHeader, "\n") + ",Active\n"
+	row := func(active string) string {
+		return strings.TrimSuffix(testRow, "\n") + "," + active + "\n"
+	}
+	tests := []struct {
+		name   string
+		args   []string
+		active string
+		want   string
+		reject bool
+	}{
+		{"words", nil, "Y", "true", false},
+		{"digits", []string{"-bool-output", "digits"}, "no", "0", false},
+		{"own tokens", []string{"-bool-true", "oui", "-bool-false", "non"}, "OUI", "true", false},
+		{"invalid", nil, "maybe", "", true},
+		{"passthrough", []string{"-bool-invalid", "passthrough"}, "maybe", "maybe", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-bool-columns", "Active"}, tt.args...)
+			in := header + row(tt.active)
+			if tt.reject {
+				entries := readErrorReport(t, in, args...)
+				if len(entries) != 1 || entries[0].Type != "bool" || entries[0].Field != "Active" {
+					t.Errorf("got %+v, want a bool error in Active", entries)
+				}
+				return
+			}
+			records := outputRecords(t, testConfig(t, args...), in)
+			if len(records) != 2 || records[0][8] != "Active" || records[1][8] != tt.want {
+				t.Errorf("got %q, want Active %q", records, tt.want)
+			}
+		})
+	}
+
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-bool-output", "yesno"}, "unknown -bool-output"},
+		{[]string{"-bool-invalid", "drop"}, "unknown -bool-invalid"},
+		{[]string{"-bool-columns", "Notes"}, "not Notes"},
+		{[]string{"-bool-true", "y,n"}, "both -bool-true and -bool-false"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package normalize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// checkpointEvery is how many rows go by between -checkpoint writes. Each
+// one flushes the output, so it's a trade between how much a resume has to
+// redo and how often we stop to write
+const checkpointEvery = 10000
+
+// checkpoint is what a -checkpoint file holds: every input row up to and
+// including Line has been dealt with, and whatever it wrote has gone out,
+// Bytes of output in all
+type checkpoint struct {
+	Input   string `json:"input"`
+	Line    int    `json:"line"`
+	Written int    `json:"written"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// countingWriter passes writes through to w, counting the bytes, so the
+// checkpoint knows how much output goes with it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// checkpointInput is the name the checkpoint knows the input by, so it can't
+// be used to resume some other file
+func checkpointInput(inputs []string) string {
+	if len(inputs) == 0 || inputs[0] == "-" {
+		return "stdin"
+	}
+	return inputs[0]
+}
+
+// loadCheckpoint reads a -checkpoint file for -resume. There not being one
+// yet is fine, and means a run that starts from the top
+func loadCheckpoint(path, input string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read -checkpoint: %w", err)
+	}
+	var saved checkpoint
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, fmt.Errorf("can't read -checkpoint: %w", err)
+	}
+	if saved.Input != input {
+		return nil, fmt.Errorf("-checkpoint is for %s, not %s", saved.Input, input)
+	}
+	return &saved, nil
+}
+
+// writeCheckpoint saves c to path. Like -prom-textfile it goes next to it and
+// is renamed over it, so an interruption can't leave half a checkpoint
+func writeCheckpoint(path string, c checkpoint) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".normalizer-checkpoint-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// On disk before the rename, or a crash could leave an empty one
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// resuming says whether this run picks up after an earlier one, and so adds
+// on to its output rather than starting a new one
+func (c *Config) resuming() bool {
+	return c.resume != nil && c.resume.Line > 0
+}
+
+// openAppendOutput opens an -output to add on to, for -resume. Anything
+// written after the checkpoint, which a resume is about to write again, is
+// cut off first, down to the size the checkpoint says it was. Only files can
+// be done that way; an upload has to start over
+func openAppendOutput(uri string, size int64) (io.WriteCloser, error) {
+	if uri == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	if i := strings.Index(uri, "://"); i >= 0 {
+		if uri[:i] != "file" {
+			return nil, fmt.Errorf("-resume can only add on to files, not %s:// outputs", uri[:i])
+		}
+		uri = uri[i+3:]
+	}
+	f, err := os.OpenFile(uri, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err == nil && info.Size() < size {
+		err = fmt.Errorf("%s is shorter than the -checkpoint says it should be", uri)
+	}
+	if err == nil {
+		err = f.Truncate(size)
+	}
+	if err == nil {
+		_, err = f.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
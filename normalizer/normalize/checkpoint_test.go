@@ -0,0 +1,231 @@
+package normalize
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// numberedRows is a header and n good rows, each with its number in Notes
+func numberedRows(n int) string {
+	var b strings.Builder
+	b.WriteString(testHeader)
+	for i := 0; i < n; i++ {
+		b.WriteString(strings.Replace(testRow, "notes", fmt.Sprint(i), 1))
+	}
+	return b.String()
+}
+
+func TestResumeAfterInterruption(t *testing.T) {
+	in := numberedRows(25000)
+	tests := []struct {
+		name string
+		// Where in the input the first run dies, as a fraction of the way
+		// through
+		cut float64
+		// What the interruption left half written at the end of the output
+		partial string
+	}{
+		{"before the first checkpoint", 0.2, ""},
+		{"after a checkpoint", 0.7, ""},
+		{"with a row cut off halfway", 0.7, "2011-04-01T14:0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			clean := filepath.Join(dir, "clean.csv")
+			if _, stderr, status := runMain(t, in, "-output", clean); status != 0 {
+				t.Fatalf("clean run: exit status %d: %s", status, stderr)
+			}
+
+			// A run that dies part way through
+			resumed := filepath.Join(dir, "resumed.csv")
+			cp := filepath.Join(dir, "checkpoint.json")
+			f, err := os.Create(resumed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cut := int(float64(len(in)) * tt.cut)
+			dying := io.MultiReader(strings.NewReader(in[:cut]), &failingReader{})
+			if err := transform(testConfig(t, "-checkpoint", cp), dying, f, nil); err == nil {
+				t.Fatal("the interrupted run didn't fail")
+			}
+			f.WriteString(tt.partial)
+			f.Close()
+
+			if _, stderr, status := runMain(t, in, "-output", resumed, "-checkpoint", cp, "-resume"); status != 0 {
+				t.Fatalf("resumed run: exit status %d: %s", status, stderr)
+			}
+			want, err := ioutil.ReadFile(clean)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(resumed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(want) {
+				t.Errorf("resumed output is %d bytes and %d lines, want %d and %d", len(got), strings.Count(string(got), "\n"), len(want), strings.Count(string(want), "\n"))
+			}
+
+			// And the checkpoint's at the end now
+			saved, err := loadCheckpoint(cp, "stdin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.Line != 25001 || saved.Written != 25000 || saved.Bytes != int64(len(want)) {
+				t.Errorf("got checkpoint %+v", saved)
+			}
+		})
+	}
+}
+
+func TestResumeWithoutCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.csv")
+	cp := filepath.Join(dir, "checkpoint.json")
+	// Nothing to resume from yet, so it's an ordinary run
+	if _, stderr, status := runMain(t, testHeader+testRow, "-output", out, "-checkpoint", cp, "-resume"); status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if data, _ := ioutil.ReadFile(out); strings.Count(string(data), "\n") != 2 {
+		t.Errorf("got %q, want the header and the row", data)
+	}
+}
+
+func TestResumeWithDuplicateTimestamps(t *testing.T) {
+	// Every row has the same timestamp, and the checkpoint is after the
+	// first of them
+	in := numberedRows(3)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.csv")
+	cp := filepath.Join(dir, "checkpoint.json")
+	first, _ := runTest(t, testConfig(t, "-max-rows", "1"), in)
+	if err := ioutil.WriteFile(out, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCheckpoint(cp, checkpoint{Input: "stdin", Line: 2, Written: 1, Bytes: int64(len(first))}); err != nil {
+		t.Fatal(err)
+	}
+
+	// keep-first would have to remember the row before the checkpoint
+	if _, stderr, status := runMain(t, in, "-output", out, "-checkpoint", cp, "-resume", "-on-duplicate-timestamp", "keep-first"); status == 0 || !strings.Contains(stderr, "keep-first") {
+		t.Errorf("keep-first resume: exit status %d: %s", status, stderr)
+	}
+
+	// keep-all writes each of them once
+	if _, stderr, status := runMain(t, in, "-output", out, "-checkpoint", cp, "-resume"); status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	data, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %q, want the header and three rows", lines)
+	}
+	for i, line := range lines[1:] {
+		if !strings.HasSuffix(line, ","+fmt.Sprint(i)) {
+			t.Errorf("row %d is %q", i, line)
+		}
+	}
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "checkpoint.json")
+	if err := writeCheckpoint(path, checkpoint{Input: "in.csv", Line: 7, Written: 5, Bytes: 300}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		input   string
+		want    *checkpoint
+		wantErr bool
+	}{
+		{"saved", path, "in.csv", &checkpoint{Input: "in.csv", Line: 7, Written: 5, Bytes: 300}, false},
+		{"not there yet", filepath.Join(dir, "missing.json"), "in.csv", nil, false},
+		{"another input", path, "other.csv", nil, true},
+		{"not json", writeTestFile(t, "bad.json", "line 7"), "in.csv", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadCheckpoint(tt.path, tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want one %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("%d files in the directory, want no temporary ones left", len(entries))
+	}
+}
+
+func TestOpenAppendOutput(t *testing.T) {
+	path := writeTestFile(t, "out.csv", "header\nrow 1\nhalf a r")
+	tests := []struct {
+		name    string
+		uri     string
+		size    int64
+		want    string
+		wantErr bool
+	}{
+		{"cut back", path, 13, "header\nrow 1\nrow 2\n", false},
+		{"file uri", "file://" + path, 7, "header\nrow 2\n", false},
+		{"shorter than the checkpoint", path, 1000, "", true},
+		{"not a file", "s3://bucket/key", 0, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte("header\nrow 1\nhalf a r"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			w, err := openAppendOutput(tt.uri, tt.size)
+			if tt.wantErr {
+				if err == nil {
+					w.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, "row 2\n")
+			w.Close()
+			if data, _ := ioutil.ReadFile(path); string(data) != tt.want {
+				t.Errorf("got %q, want %q", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckpointFlags(t *testing.T) {
+	cp := filepath.Join(t.TempDir(), "checkpoint.json")
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-resume"}, "needs a -checkpoint"},
+		{[]string{"-checkpoint", cp, "-input", "a.csv", "-input", "b.csv"}, "only works with one input"},
+		{[]string{"-checkpoint", cp, "-on-duplicate-timestamp", "keep-last"}, "holds rows back"},
+		{[]string{"-checkpoint", cp, "-on-duplicate-timestamp", "keep-first"}, "keep-first"},
+		{[]string{"-checkpoint", cp, "-on-duplicate-timestamp", "keep-first", "-dedupe-window", "1h"}, "keep-first"},
+		{[]string{"-checkpoint", cp, "-output-format", "json"}, "can be added on to"},
+		{[]string{"-checkpoint", cp, "-footer"}, "can be added on to"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
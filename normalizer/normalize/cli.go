@@ -0,0 +1,202 @@
+package normalize
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// exitMaxRows is the exit status when -max-rows cut the run short, so scripts
+// can tell it apart from a failure (1) or bad flags (2)
+const exitMaxRows = 3
+
+// exitTimeLimit is the same for -time-limit
+const exitTimeLimit = 4
+
+// Main is the normalizer command. It parses args, the command line without
+// the program's name, into cfg, which is usually DefaultConfig() with any
+// Outputs a program adds. Then it runs whatever they ask for with os.Stdin
+// and os.Stdout, and returns the exit status
+func Main(cfg *Config, args []string) int {
+	fs := flag.NewFlagSet("normalizer", flag.ExitOnError)
+	RegisterFlags(fs, cfg)
+	listFormats := fs.Bool("list-formats", false, "print the Timestamp layouts we'll try, one per line, and exit")
+	verifyTZ := fs.Bool("verify-tz", false, "check the -source-tz and -dest-tz zones (and a fixed one) load, say where the zone database is, and exit, non-zero if any didn't load")
+	detect := fs.Bool("detect-encoding", false, "look at the start of the (first) input, report on its bytes and guess whether it's UTF-8, Latin-1 or Windows-1252, and exit")
+	detectSample := fs.Int("detect-encoding-sample", defaultDetectSample, "with -detect-encoding, how many `KB` of the input to look at")
+	histogram := fs.Bool("column-count-histogram", false, "read all of the (first) input, report how many rows had each number of columns, and exit")
+	inferPath := fs.String("infer-schema", "", "read a sample of the (first) input, write a -schema `file` with the type each column seems to be, and exit")
+	inferRows := fs.Int("infer-schema-rows", defaultInferRows, "with -infer-schema, how many `rows` to look at")
+	profile := fs.String("profile", "", "apply the settings from the named `profile` in -profile-file; flags given on the command line still win")
+	profileFile := fs.String("profile-file", defaultProfileFile, "JSON `file` of named profiles for -profile")
+	fs.Parse(args)
+	if *profile != "" {
+		if err := applyProfile(fs, *profileFile, *profile); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 2
+		}
+	}
+	if cfg.HashKey == "" {
+		cfg.HashKey = os.Getenv(hashKeyEnv)
+	}
+	if *listFormats {
+		for _, layout := range cfg.TimestampLayouts {
+			fmt.Println(layout)
+		}
+		return 0
+	}
+	if *verifyTZ {
+		if err := verifyZones(os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		return 0
+	}
+	if err := cfg.Check(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 2
+	}
+
+	if cfg.Interactive {
+		if err := interactive(cfg, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		return 0
+	}
+
+	inputs := []input{{r: os.Stdin}}
+	if len(cfg.Inputs) > 0 {
+		inputs = nil
+		for _, path := range cfg.Inputs {
+			if path == "-" {
+				inputs = append(inputs, input{name: "stdin", r: os.Stdin})
+				continue
+			}
+			f, err := openInputPath(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "unable to open -input: ", err.Error())
+				return 1
+			}
+			defer f.Close()
+			inputs = append(inputs, input{name: path, r: f})
+		}
+	}
+
+	if *detect {
+		if *detectSample <= 0 {
+			fmt.Fprintln(os.Stderr, "-detect-encoding-sample must be at least 1")
+			return 2
+		}
+		report, err := detectEncoding(inputs[0].r, *detectSample*1024)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to read input: ", err.Error())
+			return 1
+		}
+		report.Print(os.Stdout)
+		return 0
+	}
+
+	if *histogram {
+		if cfg.InputFormat != inputFormatCSV {
+			fmt.Fprintln(os.Stderr, "-column-count-histogram only works with csv input")
+			return 2
+		}
+		counts, err := countColumns(cfg, inputs[0].r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, inputError(inputs[0].name, err).Error())
+			return 1
+		}
+		counts.Print(os.Stdout)
+		return 0
+	}
+
+	if *inferPath != "" {
+		if *inferRows <= 0 {
+			fmt.Fprintln(os.Stderr, "-infer-schema-rows must be at least 1")
+			return 2
+		}
+		schema, err := inferSchema(cfg, inputs[0].r, *inferRows)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, inputError(inputs[0].name, err).Error())
+			return 1
+		}
+		if err := writeSchema(*inferPath, schema); err != nil {
+			fmt.Fprintln(os.Stderr, "unable to write -infer-schema: ", err.Error())
+			return 1
+		}
+		return 0
+	}
+
+	// With -tee everything we'd write to stdout goes to the file as well.
+	// The sinks flush through to both at the end, and a failed write to
+	// either one comes back out of transform
+	var out io.Writer = os.Stdout
+	var output io.WriteCloser
+	if cfg.Output != "" {
+		var err error
+		if cfg.resuming() {
+			output, err = openAppendOutput(cfg.Output, cfg.resume.Bytes)
+		} else {
+			output, err = openOutput(cfg, cfg.Output)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to open -output: ", err.Error())
+			return 1
+		}
+		out = output
+	}
+	var tee *os.File
+	if cfg.Tee != "" {
+		var err error
+		tee, err = os.Create(cfg.Tee)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unable to open -tee file: ", err.Error())
+			return 1
+		}
+		out = io.MultiWriter(out, tee)
+	}
+
+	var metadata *runMetadata
+	if cfg.RunMetadata != "" {
+		metadata = newRunMetadata(fs, inputs, time.Now())
+	}
+
+	counts, err := transformInputs(cfg, inputs, out, nil)
+	if output != nil {
+		// Uploads happen on Close, so this can fail too
+		if closeErr := output.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("unable to finish writing -output: %w", closeErr)
+		}
+	}
+	if tee != nil {
+		if closeErr := tee.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("unable to finish writing -tee file: %w", closeErr)
+		}
+	}
+	if metadata != nil {
+		// Written even when the run failed, since that's when it's most useful
+		if metaErr := writeRunMetadata(cfg.RunMetadata, metadata, counts, err); metaErr != nil {
+			fmt.Fprintln(os.Stderr, "unable to write run metadata: ", metaErr.Error())
+		}
+	}
+	if cfg.PromTextfile != "" {
+		if promErr := writePromTextfile(cfg.PromTextfile, counts, time.Now(), err); promErr != nil {
+			fmt.Fprintln(os.Stderr, "unable to write -prom-textfile: ", promErr.Error())
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		if errors.Is(err, ErrMaxRows) {
+			return exitMaxRows
+		}
+		if errors.Is(err, ErrTimeLimit) {
+			return exitTimeLimit
+		}
+		return 1
+	}
+	return 0
+}
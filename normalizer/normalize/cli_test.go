@@ -0,0 +1,213 @@
+package normalize
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testHeader is the canonical header, for building test inputs
+const testHeader = "Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes\n"
+
+// testRow is a row that normalizes cleanly, for tests that only care about
+// one column and can swap it in
+const testRow = "4/1/11 11:00:00 AM,123 4th St,94121,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa,notes\n"
+
+// parseTestFlags builds a Config from args the way Main does, without
+// checking it
+func parseTestFlags(t *testing.T, args ...string) *Config {
+	t.Helper()
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("normalizer", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	RegisterFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parsing %v: %v", args, err)
+	}
+	return cfg
+}
+
+// testConfig is parseTestFlags, checked
+func testConfig(t *testing.T, args ...string) *Config {
+	t.Helper()
+	cfg := parseTestFlags(t, args...)
+	if err := cfg.Check(); err != nil {
+		t.Fatalf("checking %v: %v", args, err)
+	}
+	return cfg
+}
+
+// configError is the error Check gives for args, or nil
+func configError(t *testing.T, args ...string) error {
+	t.Helper()
+	return parseTestFlags(t, args...).Check()
+}
+
+// runTest runs in through transform with cfg and returns what it wrote
+func runTest(t *testing.T, cfg *Config, in string) (string, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := transform(cfg, strings.NewReader(in), &out, nil)
+	return out.String(), err
+}
+
+// outputLines runs in through transform and splits what it wrote into
+// lines, failing the test if the run does
+func outputLines(t *testing.T, cfg *Config, in string) []string {
+	t.Helper()
+	out, err := runTest(t, cfg, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+}
+
+// outputRecords is outputLines for csv output, parsed, header first
+func outputRecords(t *testing.T, cfg *Config, in string) [][]string {
+	t.Helper()
+	out, err := runTest(t, cfg, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := csv.NewReader(strings.NewReader(out))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatalf("output isn't CSV: %v\n%s", err, out)
+	}
+	return records
+}
+
+func TestTimestampLayoutFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"defaults", nil, DefaultTimestampLayouts},
+		{"replaced", []string{"-timestamp-layout", "2006-01-02"}, []string{"2006-01-02"}},
+		{
+			"repeated, with commas",
+			[]string{"-timestamp-layout", "Jan 2, 2006", "-timestamp-layout", "2006-01-02"},
+			[]string{"Jan 2, 2006", "2006-01-02"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseTestFlags(t, tt.args...)
+			if strings.Join(cfg.TimestampLayouts, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %q, want %q", cfg.TimestampLayouts, tt.want)
+			}
+		})
+	}
+
+	// Replacing them in one Config can't change the defaults for the next
+	before := strings.Join(DefaultTimestampLayouts, "|")
+	parseTestFlags(t, "-timestamp-layout", "2006-01-02")
+	if got := strings.Join(DefaultTimestampLayouts, "|"); got != before {
+		t.Errorf("DefaultTimestampLayouts changed to %q", got)
+	}
+}
+
+// runMainEnv tells the test binary to be the normalizer instead, for runMain
+const runMainEnv = "NORMALIZER_TEST_MAIN"
+
+// TestMain lets runMain run Main in a process of its own, since it uses the
+// real stdin and stdout, and flags it can't parse exit
+func TestMain(m *testing.M) {
+	if os.Getenv(runMainEnv) != "" {
+		cfg := DefaultConfig()
+		cfg.Outputs = testOutputs
+		os.Exit(Main(cfg, os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the whole command with args and stdin, for the things Main
+// does itself, and returns what it wrote and its exit status
+func runMain(t *testing.T, stdin string, args ...string) (stdout, stderr string, status int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), runMainEnv+"=1")
+	cmd.Stdin = strings.NewReader(stdin)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		status = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), errOut.String(), status
+}
+
+func TestTee(t *testing.T) {
+	dir := t.TempDir()
+	tee := filepath.Join(dir, "tee.csv")
+	output := filepath.Join(dir, "output.csv")
+	tests := []struct {
+		name string
+		args []string
+		// Where the output is besides the -tee file, empty for stdout
+		path string
+	}{
+		{"stdout", []string{"-tee", tee}, ""},
+		{"output", []string{"-tee", tee, "-output", output}, output},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status := runMain(t, testHeader+testRow, tt.args...)
+			if status != 0 {
+				t.Fatalf("exit status %d: %s", status, stderr)
+			}
+			want := stdout
+			if tt.path != "" {
+				data, err := ioutil.ReadFile(tt.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want = string(data)
+			}
+			got, err := ioutil.ReadFile(tee)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want || strings.Count(want, "\n") != 2 {
+				t.Errorf("output:\n%s\n-tee file:\n%s", want, got)
+			}
+		})
+	}
+
+	_, _, status := runMain(t, testHeader+testRow, "-tee", filepath.Join(dir, "missing", "tee.csv"))
+	if status != 1 {
+		t.Errorf("-tee into a missing directory: exit status %d, want 1", status)
+	}
+}
+
+func TestListFormats(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, DefaultTimestampLayouts},
+		{[]string{"-timestamp-layout", "2006-01-02", "-timestamp-layout", "Jan 2, 2006"}, []string{"2006-01-02", "Jan 2, 2006"}},
+	}
+	for _, tt := range tests {
+		stdout, stderr, status := runMain(t, "", append(tt.args, "-list-formats")...)
+		if status != 0 {
+			t.Fatalf("exit status %d: %s", status, stderr)
+		}
+		if want := strings.Join(tt.want, "\n") + "\n"; stdout != want {
+			t.Errorf("%v: got\n%s\nwant\n%s", tt.args, stdout, want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package normalize
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// columnCounts is what -column-count-histogram found: how many rows had
+// each number of fields, and the line the first of them was on
+type columnCounts struct {
+	// Fields in the header, or zero with -no-header
+	Header int
+	Rows   map[int]int
+	First  map[int]int
+	Total  int
+}
+
+// countColumns reads all of in and counts the fields in every row, letting
+// through the odd sized ones a normal run would reject. It reads the CSV
+// the way a run would, with the same delimiter and line ending options, but
+// doesn't need the header to make sense
+func countColumns(cfg *Config, in io.Reader) (columnCounts, error) {
+	counts := columnCounts{Rows: make(map[int]int), First: make(map[int]int)}
+	if cfg.RecordSeparator != 0 {
+		in = newSeparatorTranslator(in, cfg.RecordSeparator)
+	}
+	if cfg.CanonicalizeNewlines {
+		in = &newlineTranslator{src: in}
+	}
+	delimiter := cfg.Delimiter
+	if delimiter == delimiterAuto {
+		buffered := bufio.NewReader(in)
+		delimiter = sniffDelimiter(buffered, len(canonicalHeaders), !cfg.NoHeader)
+		in = buffered
+	}
+	if cfg.QuoteChar != '"' {
+		in = newQuoteTranslator(in, cfg.QuoteChar)
+	}
+	reader := csv.NewReader(in)
+	reader.Comma = delimiter
+	reader.LazyQuotes = cfg.QuoteChar != '"'
+	reader.FieldsPerRecord = -1
+
+	if !cfg.NoHeader {
+		headers, err := reader.Read()
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, fmt.Errorf("unexpected error reading csv header: %w", err)
+		}
+		counts.Header = len(headers)
+	}
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return counts, nil
+		}
+		if err != nil {
+			return counts, fmt.Errorf("unexpected error: %w", err)
+		}
+		n := len(fields)
+		if counts.Rows[n] == 0 {
+			line, _ := reader.FieldPos(0)
+			counts.First[n] = line
+		}
+		counts.Rows[n]++
+		counts.Total++
+	}
+}
+
+// Print writes the histogram out for -column-count-histogram, fewest fields
+// first, e.g.
+//
+//	Header: 8 columns
+//	Rows: 10000
+//	Columns        Rows  Percent  First line
+//	      8        9996    99.96  2
+//	      9           4     0.04  118
+func (c columnCounts) Print(w io.Writer) {
+	if c.Header > 0 {
+		fmt.Fprintf(w, "Header: %d columns\n", c.Header)
+	}
+	fmt.Fprintf(w, "Rows: %d\n", c.Total)
+	if c.Total == 0 {
+		return
+	}
+	widths := make([]int, 0, len(c.Rows))
+	for n := range c.Rows {
+		widths = append(widths, n)
+	}
+	sort.Ints(widths)
+	fmt.Fprintf(w, "%7s  %10s  %7s  %s\n", "Columns", "Rows", "Percent", "First line")
+	for _, n := range widths {
+		fmt.Fprintf(w, "%7d  %10d  %7.2f  %d\n", n, c.Rows[n], float64(c.Rows[n])/float64(c.Total)*100, c.First[n])
+	}
+}
@@ -0,0 +1,105 @@
+package normalize
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		in   string
+		want columnCounts
+	}{
+		{"all good", nil, "a,b,c\n1,2,3\n4,5,6\n",
+			columnCounts{Header: 3, Rows: map[int]int{3: 2}, First: map[int]int{3: 2}, Total: 2}},
+		{"ragged", nil, "a,b,c\n1,2,3\n4,5\n6,7,8,9\n1,2\n",
+			columnCounts{Header: 3, Rows: map[int]int{2: 2, 3: 1, 4: 1}, First: map[int]int{2: 3, 3: 2, 4: 4}, Total: 4}},
+		{"no header", []string{"-no-header"}, "1,2,3\n4,5\n",
+			columnCounts{Rows: map[int]int{2: 1, 3: 1}, First: map[int]int{2: 2, 3: 1}, Total: 2}},
+		{"quoted newline", nil, "a,b\n\"1\n2\",3\n4\n",
+			columnCounts{Header: 2, Rows: map[int]int{1: 1, 2: 1}, First: map[int]int{1: 4, 2: 2}, Total: 2}},
+		{"delimiter", []string{"-delimiter", ";"}, "a;b\n1;2;3\n",
+			columnCounts{Header: 2, Rows: map[int]int{3: 1}, First: map[int]int{3: 2}, Total: 1}},
+		{"record separator", []string{"-record-separator", "|"}, "a,b|1,2|3|",
+			columnCounts{Header: 2, Rows: map[int]int{1: 1, 2: 1}, First: map[int]int{1: 3, 2: 2}, Total: 2}},
+		{"header only", nil, "a,b,c\n",
+			columnCounts{Header: 3, Rows: map[int]int{}, First: map[int]int{}}},
+		{"empty", nil, "",
+			columnCounts{Rows: map[int]int{}, First: map[int]int{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countColumns(testConfig(t, tt.args...), strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountColumnsBadQuote(t *testing.T) {
+	if _, err := countColumns(testConfig(t), strings.NewReader("a,b\n\"1,2\n")); err == nil {
+		t.Error("expected an error for the unterminated quote")
+	}
+}
+
+func TestColumnCountsPrint(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts columnCounts
+		want   string
+	}{
+		{"ragged", columnCounts{Header: 8, Rows: map[int]int{9: 1, 8: 3}, First: map[int]int{9: 5, 8: 2}, Total: 4},
+			"Header: 8 columns\nRows: 4\nColumns        Rows  Percent  First line\n" +
+				"      8           3    75.00  2\n      9           1    25.00  5\n"},
+		{"no header", columnCounts{Rows: map[int]int{2: 1}, First: map[int]int{2: 1}, Total: 1},
+			"Rows: 1\nColumns        Rows  Percent  First line\n      2           1   100.00  1\n"},
+		{"no rows", columnCounts{Header: 8, Rows: map[int]int{}, First: map[int]int{}},
+			"Header: 8 columns\nRows: 0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			tt.counts.Print(&b)
+			if b.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnCountHistogramFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		in         string
+		wantOut    string
+		wantErr    string
+		wantStatus int
+	}{
+		{"ragged", nil, testHeader + testRow + "1,2\n", "Header: 8 columns\nRows: 2\n", "", 0},
+		{"not csv", []string{"-input-format", "fixed", "-fixed-spec", "ZIP:1:5"}, "", "", "only works with csv", 2},
+		{"bad csv", nil, testHeader + "\"1,2\n", "", "unexpected error", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-column-count-histogram"}, tt.args...)
+			stdout, stderr, status := runMain(t, tt.in, args...)
+			if status != tt.wantStatus {
+				t.Fatalf("got exit status %d, want %d: %s", status, tt.wantStatus, stderr)
+			}
+			if !strings.HasPrefix(stdout, tt.wantOut) {
+				t.Errorf("got %q, want it to start %q", stdout, tt.wantOut)
+			}
+			if !strings.Contains(stderr, tt.wantErr) {
+				t.Errorf("got stderr %q, want it to contain %q", stderr, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package normalize
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// columnsDistinct is how many different values -columns-report counts for a
+// column before it stops remembering new ones, so a column of unique ids
+// can't take all the memory
+const columnsDistinct = 10000
+
+// columnsTop is how many of a column's most common values get reported
+const columnsTop = 5
+
+// columnsReport profiles the normalized values of every row written, for
+// -columns-report
+type columnsReport struct {
+	rows    int
+	columns []*columnProfile
+}
+
+// columnProfile is what -columns-report says about one column
+type columnProfile struct {
+	Name  string `json:"name"`
+	Empty int    `json:"empty"`
+	// Distinct stops going up at columnsDistinct, and Capped says it got
+	// there. When it did, Top only counts the values seen before then
+	Distinct int          `json:"distinct"`
+	Capped   bool         `json:"distinct_capped"`
+	Top      []valueCount `json:"top"`
+	// number or time if every value that wasn't empty was one, in which case
+	// Min and Max are the smallest and biggest of them, as written
+	Kind string `json:"kind,omitempty"`
+	Min  string `json:"min,omitempty"`
+	Max  string `json:"max,omitempty"`
+
+	counts map[string]int
+	// Values that weren't empty, counted or not
+	seen int
+	// Whether every value so far could still be a number or a time, and the
+	// smallest and biggest, as parsed and as written
+	number, time           bool
+	minNumber, maxNumber   float64
+	minTime, maxTime       time.Time
+	numberRange, timeRange [2]string
+}
+
+type valueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+func newColumnsReport(columns []string) *columnsReport {
+	c := &columnsReport{}
+	for _, name := range columns {
+		c.columns = append(c.columns, &columnProfile{Name: name, counts: make(map[string]int), number: true, time: true})
+	}
+	return c
+}
+
+// Add takes one written row, in the same order as the columns
+func (c *columnsReport) Add(row []string) {
+	c.rows++
+	for i, value := range row {
+		c.columns[i].add(value)
+	}
+}
+
+func (p *columnProfile) add(value string) {
+	if value == "" {
+		p.Empty++
+		return
+	}
+	if _, ok := p.counts[value]; ok || len(p.counts) < columnsDistinct {
+		p.counts[value]++
+	} else {
+		p.Capped = true
+	}
+
+	p.seen++
+
+	if p.number {
+		n, err := strconv.ParseFloat(value, 64)
+		switch {
+		case err != nil:
+			p.number = false
+		case p.seen == 1:
+			p.minNumber, p.maxNumber, p.numberRange = n, n, [2]string{value, value}
+		case n < p.minNumber:
+			p.minNumber, p.numberRange[0] = n, value
+		case n > p.maxNumber:
+			p.maxNumber, p.numberRange[1] = n, value
+		}
+	}
+	if p.time {
+		t, err := parseReportTime(value)
+		switch {
+		case err != nil:
+			p.time = false
+		case p.seen == 1:
+			p.minTime, p.maxTime, p.timeRange = t, t, [2]string{value, value}
+		case t.Before(p.minTime):
+			p.minTime, p.timeRange[0] = t, value
+		case t.After(p.maxTime):
+			p.maxTime, p.timeRange[1] = t, value
+		}
+	}
+}
+
+// parseReportTime reads a Timestamp the way we write them, with or without
+// -timestamp-date-only
+func parseReportTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02", value)
+	}
+	return t, err
+}
+
+// finish fills in the exported fields from what was counted
+func (p *columnProfile) finish() {
+	p.Distinct = len(p.counts)
+	p.Top = []valueCount{}
+	for value, count := range p.counts {
+		p.Top = append(p.Top, valueCount{value, count})
+	}
+	// Most common first, then alphabetically so ties come out the same
+	// every run
+	sort.Slice(p.Top, func(i, j int) bool {
+		if p.Top[i].Count != p.Top[j].Count {
+			return p.Top[i].Count > p.Top[j].Count
+		}
+		return p.Top[i].Value < p.Top[j].Value
+	})
+	if len(p.Top) > columnsTop {
+		p.Top = p.Top[:columnsTop]
+	}
+	switch {
+	case p.seen == 0:
+		// All empty, so there's nothing to say it's a number or a time
+	case p.number:
+		p.Kind, p.Min, p.Max = "number", p.numberRange[0], p.numberRange[1]
+	case p.time:
+		p.Kind, p.Min, p.Max = "time", p.timeRange[0], p.timeRange[1]
+	}
+}
+
+// write writes the report to path as JSON
+func (c *columnsReport) write(path string) error {
+	for _, p := range c.columns {
+		p.finish()
+	}
+	report := struct {
+		Rows    int              `json:"rows"`
+		Columns []*columnProfile `json:"columns"`
+	}{c.rows, c.columns}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
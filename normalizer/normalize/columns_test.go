@@ -0,0 +1,100 @@
+package normalize
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestColumnProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		empty    int
+		distinct int
+		top      string
+		kind     string
+		min, max string
+	}{
+		{"numbers", []string{"10.5", "2", "", "100", "2"}, 1, 3, "2:2,10.5:1,100:1", "number", "2", "100"},
+		{"times", []string{"2011-04-01T14:00:00-04:00", "2011-04-01T12:00:00-05:00", "2011-04-01"},
+			0, 3, "2011-04-01:1,2011-04-01T12:00:00-05:00:1,2011-04-01T14:00:00-04:00:1", "time", "2011-04-01", "2011-04-01T14:00:00-04:00"},
+		{"text", []string{"b", "a", "b", "10"}, 0, 3, "b:2,10:1,a:1", "", "", ""},
+		{"all empty", []string{"", ""}, 2, 0, "", "", "", ""},
+		// Only the five most common
+		{"top five", []string{"a", "b", "c", "d", "e", "f", "f"}, 0, 6, "f:2,a:1,b:1,c:1,d:1", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := newColumnsReport([]string{"col"})
+			for _, v := range tt.values {
+				report.Add([]string{v})
+			}
+			p := report.columns[0]
+			p.finish()
+			var top []string
+			for _, vc := range p.Top {
+				top = append(top, vc.Value+":"+strconv.Itoa(vc.Count))
+			}
+			if p.Empty != tt.empty || p.Distinct != tt.distinct || strings.Join(top, ",") != tt.top {
+				t.Errorf("got empty %d, distinct %d, top %s, want %d, %d, %s", p.Empty, p.Distinct, strings.Join(top, ","), tt.empty, tt.distinct, tt.top)
+			}
+			if p.Kind != tt.kind || p.Min != tt.min || p.Max != tt.max {
+				t.Errorf("got %s from %s to %s, want %s from %s to %s", p.Kind, p.Min, p.Max, tt.kind, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestColumnsDistinctCap(t *testing.T) {
+	report := newColumnsReport([]string{"id"})
+	for i := 0; i < columnsDistinct+10; i++ {
+		report.Add([]string{strconv.Itoa(i)})
+	}
+	// One already counted still counts once the cap's been hit
+	report.Add([]string{"0"})
+	p := report.columns[0]
+	p.finish()
+	if p.Distinct != columnsDistinct || !p.Capped {
+		t.Errorf("got distinct %d, capped %v, want %d, true", p.Distinct, p.Capped, columnsDistinct)
+	}
+	if p.Top[0].Value != "0" || p.Top[0].Count != 2 {
+		t.Errorf("got top %+v, want 0 twice", p.Top[0])
+	}
+	if p.Min != "0" || p.Max != strconv.Itoa(columnsDistinct+9) {
+		t.Errorf("got range %s to %s, want every value in it", p.Min, p.Max)
+	}
+}
+
+func TestColumnsReportFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "columns.json")
+	bad := strings.Replace(testRow, "1:23:32.123", "nope", 1)
+	cfg := testConfig(t, "-columns-report", path, "-split-name")
+	outputLines(t, cfg, testHeader+testRow+testRow+bad)
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report struct {
+		Rows    int
+		Columns []columnProfile
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	// Only the good rows, with the extra columns included
+	if report.Rows != 2 || len(report.Columns) != len(canonicalHeaders)+2 {
+		t.Fatalf("got %d rows and %d columns, want 2 and %d", report.Rows, len(report.Columns), len(canonicalHeaders)+2)
+	}
+	foo := report.Columns[4]
+	if foo.Name != "FooDuration" || foo.Kind != "number" || foo.Min != "5012.123000" || foo.Distinct != 1 {
+		t.Errorf("got %+v for FooDuration", foo)
+	}
+	if last := report.Columns[9]; last.Name != "LastName" || last.Top[0].Value != "ALBERTO" {
+		t.Errorf("got %+v for LastName", last)
+	}
+}
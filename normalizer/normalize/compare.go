@@ -0,0 +1,117 @@
+package normalize
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// compareSink is the Sink for -compare-to. Instead of writing the records, it
+// holds each one up against the row in the same place in a saved output, and
+// writes a line for each that's different, like
+//
+//	line 2 (expected line 2): ZIP "00501", expected "501"
+//
+// so a change of flags can be checked against what they used to give
+type compareSink struct {
+	f        *os.File
+	expected *csv.Reader
+	w        *bufio.Writer
+	extra    []string
+	// Rows that didn't match, for the run to fail on at the end
+	mismatches int
+	// The output's column names, for saying which field is different
+	columns []string
+}
+
+func newCompareSink(cfg *Config, w io.Writer, columns []string) (*compareSink, error) {
+	f, err := os.Open(cfg.CompareTo)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open -compare-to: %w", err)
+	}
+	expected := csv.NewReader(f)
+	expected.FieldsPerRecord = -1
+	return &compareSink{f: f, expected: expected, w: bufio.NewWriter(w), extra: cfg.ExtraColumns(), columns: columns}, nil
+}
+
+// next reads the next saved row and the line it's on, or nil at the end
+func (s *compareSink) next() ([]string, int, error) {
+	row, err := s.expected.Read()
+	if err == io.EOF {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read -compare-to: %w", err)
+	}
+	line, _ := s.expected.FieldPos(0)
+	return row, line, nil
+}
+
+// compare writes a line about got if it isn't the same as want
+func (s *compareSink) compare(what string, got, want []string, line int) error {
+	if want == nil {
+		s.mismatches++
+		_, err := fmt.Fprintf(s.w, "%s: not in -compare-to\n", what)
+		return err
+	}
+	var parts []string
+	if len(got) != len(want) {
+		parts = append(parts, fmt.Sprintf("%d fields, expected %d", len(got), len(want)))
+	} else {
+		for i := range got {
+			if got[i] != want[i] {
+				parts = append(parts, fmt.Sprintf("%s %q, expected %q", s.columns[i], got[i], want[i]))
+			}
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	s.mismatches++
+	_, err := fmt.Fprintf(s.w, "%s (expected line %d): %s\n", what, line, strings.Join(parts, ", "))
+	return err
+}
+
+func (s *compareSink) WriteHeader(columns []string) error {
+	want, line, err := s.next()
+	if err != nil {
+		return err
+	}
+	return s.compare("header", columns, want, line)
+}
+
+func (s *compareSink) WriteRecord(r *Record) error {
+	want, line, err := s.next()
+	if err != nil {
+		return err
+	}
+	return s.compare(fmt.Sprintf("line %d", r.line), r.Row(s.extra), want, line)
+}
+
+func (s *compareSink) Flush() error {
+	return s.w.Flush()
+}
+
+// Close reports the saved rows nothing was compared with, since the output
+// came up short
+func (s *compareSink) Close() error {
+	defer s.f.Close()
+	for {
+		want, line, err := s.next()
+		if err != nil {
+			s.w.Flush()
+			return err
+		}
+		if want == nil {
+			break
+		}
+		s.mismatches++
+		if _, err := fmt.Fprintf(s.w, "expected line %d: not in the output\n", line); err != nil {
+			return err
+		}
+	}
+	return s.w.Flush()
+}
@@ -0,0 +1,60 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCompareTo(t *testing.T) {
+	good := "2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes\n"
+	tests := []struct {
+		name  string
+		saved string
+		in    string
+		want  string
+	}{
+		{"same", testHeader + good, testHeader + testRow, ""},
+		{"changed field", testHeader + strings.Replace(good, "MONKEY ALBERTO", "BATMAN", 1), testHeader + testRow,
+			"line 2 (expected line 2): FullName \"MONKEY ALBERTO\", expected \"BATMAN\"\n"},
+		{"changed header", strings.Replace(testHeader, "Notes", "Comments", 1) + good, testHeader + testRow,
+			"header (expected line 1): Notes \"Notes\", expected \"Comments\"\n"},
+		{"wrong width", testHeader + "a,b\n", testHeader + testRow, "line 2 (expected line 2): 8 fields, expected 2\n"},
+		{"output longer", testHeader + good, testHeader + testRow + testRow, "line 3: not in -compare-to\n"},
+		{"saved longer", testHeader + good + good, testHeader + testRow, "expected line 3: not in the output\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := writeTestFile(t, "saved.csv", tt.saved)
+			out, err := runTest(t, testConfig(t, "-compare-to", saved), tt.in)
+			if out != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out, tt.want)
+			}
+			if mismatched := tt.want != ""; mismatched != errors.Is(err, ErrMismatch) {
+				t.Errorf("got error %v, want ErrMismatch %v", err, mismatched)
+			}
+		})
+	}
+}
+
+func TestCompareToFlags(t *testing.T) {
+	saved := writeTestFile(t, "saved.csv", testHeader)
+	for _, args := range [][]string{
+		{"-output-format", "json"},
+		{"-diff"},
+		{"-footer"},
+		{"-output-bom"},
+		{"-split-rows", "10"},
+	} {
+		if err := configError(t, append([]string{"-compare-to", saved}, args...)...); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+
+	if _, _, status := runMain(t, testHeader+testRow, "-compare-to", saved); status != 1 {
+		t.Errorf("mismatched run: exit status %d, want 1", status)
+	}
+	if _, _, status := runMain(t, testHeader, "-compare-to", saved); status != 0 {
+		t.Errorf("matching run: exit status %d, want 0", status)
+	}
+}
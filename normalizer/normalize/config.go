@@ -0,0 +1,715 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxRate is the most -rate can be, one record a nanosecond
+const maxRate = 1e9
+
+// Config holds everything the command line lets you change about a run. Main
+// fills it in from flags, and it gets passed down to whatever needs it
+type Config struct {
+	// Match input header names ignoring case
+	CaseInsensitiveHeaders bool
+	// Where to write the JSON error report, empty for none
+	ErrorReport string
+	// Where to write each rejected row as a line of JSON as it happens
+	ErrorStream string
+	// Where to write the JSON profile of the output's columns, empty for none
+	ColumnsReport string
+	// Print rejected rows to stderr at the end, grouped by what was wrong
+	PrettyErrors bool
+	// One of the durationFormat* constants
+	DurationInputFormat string
+	// Only accept durations written exactly HH:MM:SS.mmm
+	StrictDurationFormat bool
+	// Append FooPercent and BarPercent columns
+	AddPercentColumns bool
+	// Decimal places for the percent columns
+	PercentPrecision int
+	// Decimal places for the durations, and one of the durationRounding*
+	// constants for getting them there
+	DurationPrecision int
+	DurationRounding  string
+	// One of the durationOutput* constants
+	DurationOutput string
+	// One of the totalSource* constants, for where TotalDuration comes from
+	TotalSource string
+	// One of the outputFormat* constants
+	OutputFormat string
+	// The input has no header row, so every line is data
+	NoHeader bool
+	// If the header looks like a row of data, read it as one, as if NoHeader
+	// had been given
+	AutoNoHeader bool
+	// Names of the input columns, in order, for input with no header. Empty
+	// means they're in canonical order
+	Columns []string
+	// Write a header even though the input didn't have one
+	WriteHeader bool
+	// Two-digit years below this are 20xx, the rest 19xx
+	YearPivot int
+	// Print TotalDuration statistics to stderr at the end
+	Stats bool
+	// Fix up rows that are narrower or wider than the header, rather than
+	// rejecting them
+	PadShortRows     bool
+	TruncateLongRows bool
+	// Drop one extra, empty field off the end of a row
+	TolerateTrailingComma bool
+	// Join any fields past the header width back onto Notes, which has to be
+	// the last column
+	MergeTrailingIntoNotes bool
+	// One of the unicodeNormalize* constants
+	UnicodeNormalize string
+	// Fold the text columns down to ASCII, with NonASCIIPlaceholder for
+	// anything that won't fold
+	StripDiacritics     bool
+	NonASCIIPlaceholder string
+	// One of the truncate* constants, applied to Timestamp in the
+	// destination zone, or write only its date
+	TimestampTruncate string
+	TimestampDateOnly bool
+	// One of the notesNewlines* constants, and what to replace line breaks
+	// with in replace mode
+	NotesNewlines           string
+	NotesNewlineReplacement string
+	// Trim the text fields and squash runs of whitespace in them to a space
+	CollapseWhitespace bool
+	// One of the controlChars* constants
+	ControlChars string
+	// IANA zone names input timestamps are read in and output ones written in
+	SourceTZ string
+	DestTZ   string
+	// Input column holding each row's destination time zone, empty for none
+	DestTZColumn string
+	// Files to read instead of stdin, "-" meaning stdin, and what to do when
+	// one's header isn't the same as the first one's (a headerMismatch*
+	// constant)
+	Inputs               []string
+	HeaderMismatchPolicy string
+	// Only the first -input has a header, and it goes for the rest too
+	ColumnsFromFirstFile bool
+	// One of the inputFormat* constants, and for fixed width input how to
+	// slice up each line and whether to trim the padding off the fields
+	InputFormat string
+	FixedSpec   FixedSpec
+	FixedTrim   bool
+	// Field separator in the input, and the character fields are quoted with
+	Delimiter rune
+	// What ends each input record, if it isn't a newline. Zero for newlines
+	RecordSeparator rune
+	// Turn \r\n and lone \r in the input into \n before anything reads it
+	CanonicalizeNewlines bool
+	QuoteChar            rune
+	// Most records to write per second, zero for as fast as we can
+	Rate float64
+	// End the output with a row count and checksum line
+	Footer bool
+	// Start the output with a UTF-8 byte order mark
+	OutputBOM bool
+	// Start the output with a comment line saying how it was made, with
+	// CommentPrefix in front of it
+	ProvenanceComment bool
+	CommentPrefix     string
+	// Every so often, save how far through the input we've got to Checkpoint,
+	// and with Resume start from where it says
+	Checkpoint string
+	Resume     bool
+	// Turn individual Normalize steps off. With all of them set, and the
+	// other text options left alone, rows pass through as-is apart from UTF-8
+	// repair and re-quoting
+	NoNormalizeTimestamp bool
+	NoNormalizeDurations bool
+	NoNormalizeZip       bool
+	NoNormalizeName      bool
+	// One of the zipFormat* constants
+	ZipFormat string
+	// One of the zipMode* constants, for -zip-format us
+	ZipMode string
+	// One of the nameCase* constants, and the words title-smart keeps
+	// lowercase
+	NameCase      string
+	NameParticles []string
+	// Add FirstName and LastName columns split out of FullName, and maybe
+	// leave FullName itself out
+	SplitName    bool
+	DropFullName bool
+	// One of the addressCase* constants, and the words smart keeps uppercase
+	AddressCase  string
+	AddressUpper []string
+	// One of the dstPolicy* constants
+	DSTPolicy string
+	// Input columns to build Timestamp from instead of a Timestamp column,
+	// joined with TimestampJoin
+	TimestampColumns []string
+	TimestampJoin    string
+	// time.Parse layouts for Timestamp, tried in order, and whether to tidy
+	// up AM/PM markers before trying them
+	TimestampLayouts []string
+	NormalizeAMPM    bool
+	// Field values that mean there isn't one, like \N
+	NullTokens []string
+	// -replace rules, applied in order before anything else changes the fields
+	Replacements []Replacement
+	// -extract rules, in the order their columns are written, and whether
+	// to lowercase what they find
+	Extracts         []Extraction
+	ExtractLowercase bool
+	// -parse-number columns, and whether a row without a number is rejected
+	ParseNumbers      []NumberRule
+	ParseNumberStrict bool
+	// Indent -output-format json output
+	JSONPretty bool
+	// Write durations as strings in JSON too, so every field is one
+	JSONStrings bool
+	// Also write the output to this file
+	Tee string
+	// Where to write the output instead of stdout: a path, or a URI with
+	// file:// or one of the Outputs schemes
+	Output string
+	// Openers for -output URI schemes beyond file://, by scheme, so a
+	// program can add the ones it needs without this package depending on
+	// their SDKs. They're code rather than settings, so they're left out of
+	// the -provenance-comment hash
+	Outputs map[string]OutputOpener `json:"-"`
+	// Write the output to files of at most SplitRows rows, or one file per
+	// SplitBy column value, named starting with SplitPrefix
+	SplitRows   int
+	SplitBy     string
+	SplitPrefix string
+	// How many SplitBy files can be open at once
+	SplitMaxOpen int
+	// Write a JSON description of the run here when it's done
+	RunMetadata string
+	// And its counts here, for Prometheus
+	PromTextfile string
+	// Every MetricsInterval, add a JSON line of progress to MetricsFile
+	MetricsInterval time.Duration
+	MetricsFile     string
+	// One of the duplicate* constants, for rows with the same normalized
+	// Timestamp as an earlier one
+	OnDuplicateTimestamp string
+	// With keep-first, only remember timestamps this close to the newest
+	// one seen, zero to remember them all
+	DedupeWindow time.Duration
+	// Reject rows whose ZIP is in a different state from the one Address ends
+	// with
+	CheckZipState bool
+	// File of the only ZIPs allowed, one per line. Rows with any other are
+	// rejected
+	ZipAllowlist string
+	// Add a column with the byte offset each row starts at in its input
+	AddOffsetColumn bool
+	// Input columns to copy into the output as true or false, the tokens
+	// that mean each, and the bool* constants for how to write them and what
+	// to do with anything else
+	BoolColumns []string
+	BoolTrue    []string
+	BoolFalse   []string
+	BoolOutput  string
+	BoolInvalid string
+	// Columns to replace with an HMAC of their value under HashKey
+	HashColumns []string
+	HashKey     string
+	// The input's in timestamp order, so -on-duplicate-timestamp only has to
+	// remember the latest timestamp. Checked as we go unless NoVerifySorted
+	AssumeSorted   bool
+	NoVerifySorted bool
+	// One of the invalidUTF8* constants
+	InvalidUTF8 string
+	// Stop after reading this many rows, zero for no limit
+	MaxRows int
+	// Stop once the run's taken this long, zero for no limit
+	TimeLimit time.Duration
+	// Keep reading an input past its end for more, like tail -f, and end an
+	// input once nothing new has turned up for IdleTimeout, zero to wait
+	// forever
+	Follow      bool
+	IdleTimeout time.Duration
+	// Write the canonical columns in the order the input has them
+	PreserveInputOrder bool
+	// Fail the run if it didn't write any good rows
+	FailOnEmpty bool
+	// Stop if more than this fraction of fields need UTF-8 repair, zero for
+	// no limit
+	MaxUTF8ReplacementRate float64
+	// Write rows that fail too, as they came in, with why in an extra column
+	AnnotateErrors bool
+	// Normalize one line at a time as it's typed in, see interactive.go
+	Interactive bool
+	// Write what Normalize changed in each row instead of the rows
+	Diff bool
+	// Instead of the output, write the first Preview rows as a table for
+	// people, with cells cut down to MaxColWidth characters
+	Preview     int
+	MaxColWidth int
+	// Instead of the output, report how it differs from this saved one
+	CompareTo string
+	// Reject rows whose durations can't be right, and the longest any one
+	// duration can plausibly be (zero for no limit)
+	CheckDurationConsistency bool
+	MaxDuration              time.Duration
+
+	// Skip rows whose KeyColumns values are a key in the SkipKeys file. Check
+	// loads the keys, and finds the columns
+	SkipKeys   string
+	KeyColumns []string
+	skipKeys   map[string]bool
+	keyColumns []int
+	// A -schema file, and what we loaded from it in Check
+	Schema string
+	schema *Schema
+	// The ZIPs in ZipAllowlist, normalized
+	zipAllowlist map[string]bool
+	// Where -resume picks up, if there's a checkpoint to pick up from
+	resume *checkpoint
+
+	zones zoneCache
+
+	// Set by TransformBatches, which has no flags of its own
+	batchSize int
+	batchEmit func(*Batch) error
+}
+
+// DefaultConfig is what you get without passing any flags
+func DefaultConfig() *Config {
+	return &Config{
+		CaseInsensitiveHeaders:  true,
+		DurationInputFormat:     durationFormatAuto,
+		PercentPrecision:        2,
+		DurationPrecision:       6,
+		DurationRounding:        durationRoundingHalfEven,
+		DurationOutput:          durationOutputSeconds,
+		TotalSource:             totalSourceCompute,
+		CommentPrefix:           "#",
+		OutputFormat:            outputFormatCSV,
+		YearPivot:               defaultYearPivot,
+		UnicodeNormalize:        unicodeNormalizeOff,
+		NonASCIIPlaceholder:     "?",
+		TimestampTruncate:       truncateOff,
+		NotesNewlines:           notesNewlinesPreserve,
+		ControlChars:            controlCharsKeep,
+		NotesNewlineReplacement: " ",
+		DSTPolicy:               dstPolicyEarliest,
+		SourceTZ:                "US/Pacific",
+		DestTZ:                  "US/Eastern",
+		HeaderMismatchPolicy:    headerMismatchError,
+		OnDuplicateTimestamp:    duplicateKeepAll,
+		BoolTrue:                append([]string(nil), defaultBoolTrue...),
+		BoolFalse:               append([]string(nil), defaultBoolFalse...),
+		BoolOutput:              boolOutputWords,
+		MaxColWidth:             defaultMaxColWidth,
+		BoolInvalid:             boolInvalidError,
+		InputFormat:             inputFormatCSV,
+		ZipFormat:               zipFormatUS,
+		ZipMode:                 zipModePad5,
+		InvalidUTF8:             invalidUTF8Repair,
+		SplitPrefix:             "output",
+		SplitMaxOpen:            defaultSplitMaxOpen,
+		FixedTrim:               true,
+		Delimiter:               ',',
+		QuoteChar:               '"',
+		NameCase:                nameCaseUpper,
+		NameParticles:           append([]string(nil), defaultNameParticles...),
+		AddressCase:             addressCaseOff,
+		AddressUpper:            append([]string(nil), defaultAddressUpper...),
+		TimestampLayouts:        append([]string(nil), DefaultTimestampLayouts...),
+		TimestampJoin:           " ",
+	}
+}
+
+// jsonStrings is whether the JSON sinks have to write the durations as
+// strings, which ISO 8601 ones are, and so are ones passed through as they
+// came, like 1:23:32.123
+func (c *Config) jsonStrings() bool {
+	return c.JSONStrings || c.DurationOutput == durationOutputISO8601 || c.NoNormalizeDurations
+}
+
+// streaming is whether inputs might go quiet without ending, in which case
+// rows are flushed out as soon as they're written
+func (c *Config) streaming() bool {
+	return c.Follow || c.IdleTimeout > 0
+}
+
+// ExtraColumns lists the derived columns we'll append to every row, in
+// output order
+func (c *Config) ExtraColumns() []string {
+	var extra []string
+	if c.AddPercentColumns {
+		extra = append(extra, "FooPercent", "BarPercent")
+	}
+	for _, e := range c.Extracts {
+		extra = append(extra, e.Column)
+	}
+	for _, n := range c.ParseNumbers {
+		extra = append(extra, n.Column)
+	}
+	if c.SplitName {
+		extra = append(extra, firstNameColumn, lastNameColumn)
+	}
+	extra = append(extra, c.BoolColumns...)
+	if c.AddOffsetColumn {
+		extra = append(extra, offsetColumn)
+	}
+	if c.AnnotateErrors {
+		extra = append(extra, annotateErrorsColumn)
+	}
+	return extra
+}
+
+// Check catches flag values that parsed fine but don't mean anything to us
+func (c *Config) Check() error {
+	switch c.DurationInputFormat {
+	case durationFormatAuto, durationFormatColon, durationFormatGo:
+	default:
+		return fmt.Errorf("unknown -duration-input-format %q", c.DurationInputFormat)
+	}
+	switch c.OutputFormat {
+	case outputFormatCSV, outputFormatJSON, outputFormatNDJSON:
+	default:
+		return fmt.Errorf("unknown -output-format %q", c.OutputFormat)
+	}
+	switch c.TimestampTruncate {
+	case truncateOff, truncateDay, truncateHour, truncateMinute, truncateSecond:
+	default:
+		return fmt.Errorf("unknown -timestamp-truncate %q", c.TimestampTruncate)
+	}
+	if c.TimestampDateOnly && c.TimestampTruncate != truncateOff && c.TimestampTruncate != truncateDay {
+		return fmt.Errorf("-timestamp-date-only already truncates to the day, so can't be used with -timestamp-truncate %s", c.TimestampTruncate)
+	}
+	for i := 0; i < len(c.NonASCIIPlaceholder); i++ {
+		if c.NonASCIIPlaceholder[i] >= 0x80 {
+			return fmt.Errorf("-non-ascii-placeholder has to be ASCII itself")
+		}
+	}
+	if _, _, err := unicodeForm(c.UnicodeNormalize); err != nil {
+		return err
+	}
+	switch c.NotesNewlines {
+	case notesNewlinesPreserve, notesNewlinesStripTrailing, notesNewlinesReplace:
+	default:
+		return fmt.Errorf("unknown -notes-newlines %q", c.NotesNewlines)
+	}
+	switch c.ControlChars {
+	case controlCharsKeep, controlCharsStrip, controlCharsEscape:
+	default:
+		return fmt.Errorf("unknown -control-chars %q", c.ControlChars)
+	}
+	switch c.DSTPolicy {
+	case dstPolicyEarliest, dstPolicyLatest, dstPolicyError:
+	default:
+		return fmt.Errorf("unknown -dst-policy %q", c.DSTPolicy)
+	}
+	if c.AutoNoHeader && c.NoHeader {
+		return fmt.Errorf("-auto-no-header is only for inputs that might have a header, it can't be used with -no-header")
+	}
+	if c.ColumnsFromFirstFile && c.NoHeader {
+		return fmt.Errorf("-columns-from-first-file needs the first file to have a header, it can't be used with -no-header")
+	}
+	switch c.HeaderMismatchPolicy {
+	case headerMismatchError, headerMismatchSkipFile, headerMismatchRemap:
+	default:
+		return fmt.Errorf("unknown -header-mismatch-policy %q", c.HeaderMismatchPolicy)
+	}
+	switch c.InvalidUTF8 {
+	case invalidUTF8Repair, invalidUTF8Reject:
+	default:
+		return fmt.Errorf("unknown -invalid-utf8 %q", c.InvalidUTF8)
+	}
+	switch c.ZipFormat {
+	case zipFormatUS, zipFormatCA, zipFormatUK, zipFormatPassthrough:
+	default:
+		return fmt.Errorf("unknown -zip-format %q", c.ZipFormat)
+	}
+	switch c.ZipMode {
+	case zipModePad5, zipModeStrip, zipModePlus4:
+	default:
+		return fmt.Errorf("unknown -zip-mode %q", c.ZipMode)
+	}
+	if c.ZipMode != zipModePad5 && c.ZipFormat != zipFormatUS {
+		return fmt.Errorf("-zip-mode is for -zip-format us only")
+	}
+	switch c.OnDuplicateTimestamp {
+	case duplicateKeepAll, duplicateKeepFirst, duplicateKeepLast:
+	default:
+		return fmt.Errorf("unknown -on-duplicate-timestamp %q", c.OnDuplicateTimestamp)
+	}
+	if c.MergeTrailingIntoNotes && c.TruncateLongRows {
+		return fmt.Errorf("-merge-trailing-into-notes and -truncate-long-rows can't be used together")
+	}
+	if c.MetricsInterval < 0 {
+		return fmt.Errorf("-metrics-interval can't be negative")
+	}
+	if (c.MetricsInterval > 0) != (c.MetricsFile != "") {
+		return fmt.Errorf("-metrics-interval and -metrics-file go together")
+	}
+	if c.DedupeWindow < 0 {
+		return fmt.Errorf("-dedupe-window can't be negative")
+	}
+	if c.DedupeWindow > 0 && c.OnDuplicateTimestamp != duplicateKeepFirst {
+		return fmt.Errorf("-dedupe-window only works with -on-duplicate-timestamp keep-first")
+	}
+	if c.DedupeWindow > 0 && c.NoNormalizeTimestamp {
+		return fmt.Errorf("-dedupe-window needs the timestamps, so can't be used with -no-normalize-timestamp")
+	}
+	if c.AssumeSorted && c.NoNormalizeTimestamp {
+		return fmt.Errorf("-assume-sorted needs the timestamps, so can't be used with -no-normalize-timestamp")
+	}
+	if c.AssumeSorted && c.DedupeWindow > 0 {
+		return fmt.Errorf("-dedupe-window isn't needed with -assume-sorted, which only remembers the latest timestamp")
+	}
+	if err := checkBool(c); err != nil {
+		return err
+	}
+	if len(c.HashColumns) > 0 {
+		if c.HashKey == "" {
+			return fmt.Errorf("-hash-columns needs a key, from -hash-key or $%s", hashKeyEnv)
+		}
+		if err := checkHashColumns(c); err != nil {
+			return err
+		}
+	}
+	if c.NoVerifySorted && !c.AssumeSorted {
+		return fmt.Errorf("-no-verify-sorted only works with -assume-sorted")
+	}
+	switch c.InputFormat {
+	case inputFormatCSV:
+	case inputFormatFixed:
+		if len(c.FixedSpec) == 0 {
+			return fmt.Errorf("-input-format fixed needs a -fixed-spec")
+		}
+	default:
+		return fmt.Errorf("unknown -input-format %q", c.InputFormat)
+	}
+	if c.Schema != "" {
+		schema, err := loadSchema(c.Schema)
+		if err != nil {
+			return err
+		}
+		c.schema = schema
+	}
+	if (c.SkipKeys != "") != (len(c.KeyColumns) > 0) {
+		return fmt.Errorf("-skip-keys and -key-columns go together")
+	}
+	if c.SkipKeys != "" {
+		c.keyColumns = nil
+		for _, name := range c.KeyColumns {
+			i := columnIndex(name)
+			if i < 0 {
+				return fmt.Errorf("-key-columns: unknown column %q", name)
+			}
+			c.keyColumns = append(c.keyColumns, i)
+		}
+		keys, err := loadSkipKeys(c.SkipKeys, c.KeyColumns)
+		if err != nil {
+			return err
+		}
+		c.skipKeys = keys
+	}
+	if c.Resume && c.Checkpoint == "" {
+		return fmt.Errorf("-resume needs a -checkpoint to resume from")
+	}
+	if c.Checkpoint != "" {
+		// Line numbers only mean something within one input, and the
+		// checkpoint is only right if rows go out as soon as they're read
+		if len(c.Inputs) > 1 {
+			return fmt.Errorf("-checkpoint only works with one input")
+		}
+		if c.OnDuplicateTimestamp == duplicateKeepLast {
+			return fmt.Errorf("-checkpoint can't be used with -on-duplicate-timestamp keep-last, which holds rows back until the end")
+		}
+		// keep-first only knows what it's seen in memory, so a resume would
+		// write again the rows it dropped before the checkpoint
+		if c.OnDuplicateTimestamp == duplicateKeepFirst {
+			return fmt.Errorf("-checkpoint can't be used with -on-duplicate-timestamp keep-first, whose timestamps a resume wouldn't remember")
+		}
+		if c.OutputFormat == outputFormatJSON || c.Diff || c.CompareTo != "" || c.Preview > 0 || c.Footer || c.Tee != "" || c.SplitRows > 0 || c.SplitBy != "" {
+			return fmt.Errorf("-checkpoint needs output that can be added on to, so it can't be used with -output-format json, -diff, -compare-to, -preview, -footer, -tee or -split-rows and -split-by")
+		}
+	}
+	if c.Resume {
+		saved, err := loadCheckpoint(c.Checkpoint, checkpointInput(c.Inputs))
+		if err != nil {
+			return err
+		}
+		c.resume = saved
+	}
+	if c.ZipAllowlist != "" {
+		// Compared like for like, so with -no-normalize-zip neither side is
+		format := c.ZipFormat
+		if c.NoNormalizeZip {
+			format = zipFormatPassthrough
+		}
+		allowed, err := loadZipAllowlist(c.ZipAllowlist, format, c.ZipMode)
+		if err != nil {
+			return err
+		}
+		c.zipAllowlist = allowed
+	}
+	if c.CheckZipState && c.ZipFormat != zipFormatUS {
+		return fmt.Errorf("-check-zip-state only works with US zips, -zip-format us")
+	}
+	if c.AddOffsetColumn && (c.RecordSeparator != 0 || c.CanonicalizeNewlines) {
+		// They change where the newlines are, which is what the offsets go
+		// by
+		return fmt.Errorf("-add-offset-column can't be used with -record-separator or -canonicalize-newlines")
+	}
+	if c.RecordSeparator != 0 && (c.RecordSeparator == c.Delimiter || c.RecordSeparator == c.QuoteChar) {
+		return fmt.Errorf("-record-separator can't be the same as -delimiter or -quote-char")
+	}
+	if c.QuoteChar == c.Delimiter {
+		return fmt.Errorf("-quote-char and -delimiter can't be the same")
+	}
+	// Much better to find out now than to reject every single row
+	if _, err := c.zones.load(c.SourceTZ); err != nil {
+		return fmt.Errorf("can't load -source-tz: %w", err)
+	}
+	if _, err := c.zones.load(c.DestTZ); err != nil {
+		return fmt.Errorf("can't load -dest-tz: %w", err)
+	}
+	if err := checkNameCase(c.NameCase); err != nil {
+		return err
+	}
+	if err := checkAddressCase(c.AddressCase); err != nil {
+		return err
+	}
+	if c.YearPivot < 0 || c.YearPivot > 100 {
+		return fmt.Errorf("-year-pivot must be between 0 and 100")
+	}
+	if c.JSONPretty && c.OutputFormat == outputFormatNDJSON {
+		return fmt.Errorf("-json-pretty can't be used with ndjson output, which has to be one object per line")
+	}
+	if c.JSONPretty && c.OutputFormat != outputFormatJSON {
+		return fmt.Errorf("-json-pretty only works with json output")
+	}
+	if c.Interactive && len(c.Inputs) > 0 {
+		return fmt.Errorf("-interactive reads stdin, it can't be used with -input")
+	}
+	if c.Diff && (c.OutputFormat != outputFormatCSV || c.Footer) {
+		return fmt.Errorf("-diff writes its own report, it can't be used with -output-format or -footer")
+	}
+	if c.Preview < 0 {
+		return fmt.Errorf("-preview can't be negative")
+	}
+	if c.Preview > 0 && (c.OutputFormat != outputFormatCSV || c.Diff || c.CompareTo != "" || c.Footer || c.OutputBOM || c.SplitRows > 0 || c.SplitBy != "") {
+		return fmt.Errorf("-preview writes its own table, it can't be used with -output-format, -diff, -compare-to, -footer, -output-bom or -split-rows and -split-by")
+	}
+	if c.MaxColWidth < 2 {
+		return fmt.Errorf("-max-col-width must be at least 2")
+	}
+	if c.CompareTo != "" && (c.OutputFormat != outputFormatCSV || c.Diff || c.Footer || c.OutputBOM || c.Tee != "" || c.SplitRows > 0 || c.SplitBy != "") {
+		return fmt.Errorf("-compare-to writes its own report and compares csv, it can't be used with -output-format, -diff, -footer, -output-bom, -tee or -split-rows and -split-by")
+	}
+	if c.AnnotateErrors && c.OutputFormat != outputFormatCSV {
+		// Bad rows go out as they came in, and JSON needs durations that
+		// are numbers
+		return fmt.Errorf("-annotate-errors only works with csv output")
+	}
+	if c.OutputBOM && (c.OutputFormat != outputFormatCSV || c.Diff) {
+		return fmt.Errorf("-output-bom only works with csv output")
+	}
+	if c.MaxRows < 0 {
+		return fmt.Errorf("-max-rows can't be negative")
+	}
+	if c.TimeLimit < 0 {
+		return fmt.Errorf("-time-limit can't be negative")
+	}
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("-idle-timeout can't be negative")
+	}
+	if c.Follow && len(c.Inputs) > 1 {
+		return fmt.Errorf("-follow only works with one -input, since it never gets to the next")
+	}
+	if c.SplitMaxOpen < 1 {
+		return fmt.Errorf("-split-max-open must be at least 1")
+	}
+	if c.SplitRows < 0 {
+		return fmt.Errorf("-split-rows can't be negative")
+	}
+	if c.SplitRows > 0 || c.SplitBy != "" {
+		if c.SplitRows > 0 && c.SplitBy != "" {
+			return fmt.Errorf("-split-rows and -split-by can't be used together")
+		}
+		if c.Diff || c.Footer || c.Tee != "" || c.OutputBOM || c.Output != "" {
+			return fmt.Errorf("-split-rows and -split-by can't be used with -diff, -footer, -tee, -output-bom or -output")
+		}
+	}
+	if c.SplitBy != "" && columnIndex(c.SplitBy) < 0 {
+		found := false
+		for _, name := range c.ExtraColumns() {
+			found = found || name == c.SplitBy
+		}
+		if !found {
+			return fmt.Errorf("-split-by: no output column %q", c.SplitBy)
+		}
+	}
+	if c.JSONStrings && c.OutputFormat == outputFormatCSV {
+		return fmt.Errorf("-json-strings only works with json or ndjson output")
+	}
+	if c.ProvenanceComment && (c.OutputFormat != outputFormatCSV || c.Diff || c.CompareTo != "" || c.Preview > 0) {
+		return fmt.Errorf("-provenance-comment only works with csv output")
+	}
+	if c.CommentPrefix == "" || strings.ContainsAny(c.CommentPrefix, "\r\n") {
+		return fmt.Errorf("-comment-prefix can't be empty or have a line break in it")
+	}
+	if c.Footer && c.OutputFormat != outputFormatCSV {
+		return fmt.Errorf("-footer only works with csv output")
+	}
+	if c.StrictDurationFormat && c.DurationInputFormat == durationFormatGo {
+		return fmt.Errorf("-strict-duration-format only accepts HH:MM:SS.mmm, it can't be used with -duration-input-format go")
+	}
+	if c.NoNormalizeDurations && c.AddPercentColumns {
+		return fmt.Errorf("-add-percent-columns needs duration normalization, it can't be used with -no-normalize-durations")
+	}
+	if c.NoNormalizeDurations && c.Stats {
+		return fmt.Errorf("-stats needs duration normalization, it can't be used with -no-normalize-durations")
+	}
+	if c.DropFullName && !c.SplitName {
+		return fmt.Errorf("-drop-full-name only works with -split-name")
+	}
+	if c.DropFullName && c.Diff {
+		return fmt.Errorf("-drop-full-name can't be used with -diff, which reports on every column")
+	}
+	seen := make(map[string]bool)
+	for _, name := range c.ExtraColumns() {
+		if seen[name] || columnIndex(name) >= 0 {
+			return fmt.Errorf("column %q would be written twice", name)
+		}
+		seen[name] = true
+	}
+	// Written so NaN fails too, like -rate
+	if !(c.MaxUTF8ReplacementRate >= 0 && c.MaxUTF8ReplacementRate <= 1) {
+		return fmt.Errorf("-max-utf8-replacement-rate must be between 0 and 1")
+	}
+	// Written so NaN fails too. Past a billion a second the ticker's
+	// interval would round down to nothing
+	if !(c.Rate >= 0 && c.Rate <= maxRate) {
+		return fmt.Errorf("-rate has to be between 0 and %g", float64(maxRate))
+	}
+	if c.DurationPrecision < 0 || c.DurationPrecision > 9 {
+		return fmt.Errorf("-duration-precision has to be between 0 and 9")
+	}
+	switch c.DurationRounding {
+	case durationRoundingHalfEven, durationRoundingHalfUp, durationRoundingTruncate:
+	default:
+		return fmt.Errorf("unknown -duration-rounding %q", c.DurationRounding)
+	}
+	switch c.DurationOutput {
+	case durationOutputSeconds, durationOutputISO8601:
+	default:
+		return fmt.Errorf("unknown -duration-output %q", c.DurationOutput)
+	}
+	switch c.TotalSource {
+	case totalSourceCompute, totalSourceInput, totalSourcePreferInput:
+	default:
+		return fmt.Errorf("unknown -total-source %q", c.TotalSource)
+	}
+	if c.PercentPrecision < 0 {
+		return fmt.Errorf("-percent-precision can't be negative")
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package normalize
+
+import "testing"
+
+func TestCheckRate(t *testing.T) {
+	tests := []struct {
+		rate    string
+		wantErr bool
+	}{
+		{"0", false},
+		{"0.5", false},
+		{"1000", false},
+		{"1e9", false},
+		{"2e9", true},
+		{"-1", true},
+		{"NaN", true},
+		{"+Inf", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rate, func(t *testing.T) {
+			err := configError(t, "-rate", tt.rate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package normalize
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// fieldChange is one field Normalize altered
+type fieldChange struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// changes lists the fields that differ from what was read in, in canonical
+// column order. Extra columns aren't in the input, so they never count
+func (r *Record) changes() []fieldChange {
+	var changed []fieldChange
+	after := r.Fields()
+	for i, before := range r.original {
+		if before != after[i] {
+			changed = append(changed, fieldChange{canonicalHeaders[i], before, after[i]})
+		}
+	}
+	return changed
+}
+
+// diffSink is the Sink for -diff. Instead of the records, it writes a line
+// for each one Normalize changed, saying what it changed, like
+//
+//	line 2: ZIP "501" -> "00501", FullName "bob" -> "BOB"
+//
+// Rows that came through untouched aren't mentioned
+type diffSink struct {
+	w *bufio.Writer
+}
+
+func (s *diffSink) WriteHeader(columns []string) error {
+	return nil
+}
+
+func (s *diffSink) WriteRecord(r *Record) error {
+	changed := r.changes()
+	if len(changed) == 0 {
+		return nil
+	}
+	parts := make([]string, len(changed))
+	for i, c := range changed {
+		parts[i] = fmt.Sprintf("%s %q -> %q", c.Field, c.Before, c.After)
+	}
+	_, err := fmt.Fprintf(s.w, "line %d: %s\n", r.line, strings.Join(parts, ", "))
+	return err
+}
+
+func (s *diffSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *diffSink) Close() error {
+	return s.w.Flush()
+}
+
+func newDiffSink(w io.Writer) *diffSink {
+	return &diffSink{w: bufio.NewWriter(w)}
+}
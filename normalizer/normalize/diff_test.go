@@ -0,0 +1,48 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	// Already normalized, so nothing changes
+	clean := "2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes\n"
+	tests := []struct {
+		name string
+		args []string
+		in   string
+		want string
+	}{
+		{"unchanged", []string{"-timestamp-layout", "2006-01-02T15:04:05Z07:00", "-duration-input-format", "go"}, "", ""},
+		{
+			"changed",
+			nil,
+			testHeader + strings.Replace(strings.Replace(testRow, "94121", "501", 1), "Monkey Alberto", "MONKEY ALBERTO", 1),
+			`line 2: Timestamp "4/1/11 11:00:00 AM" -> "2011-04-01T14:00:00-04:00", ZIP "501" -> "00501", FooDuration "1:23:32.123" -> "5012.123000", BarDuration "1:32:33.123" -> "5553.123000", TotalDuration "zzsasdfa" -> "10565.246000"` + "\n",
+		},
+		{
+			"only the fields that changed",
+			[]string{"-no-normalize-timestamp", "-no-normalize-durations"},
+			testHeader + strings.Replace(testRow, "Monkey Alberto", "bob", 1) + testRow,
+			"line 2: FullName \"bob\" -> \"BOB\"\nline 3: FullName \"Monkey Alberto\" -> \"MONKEY ALBERTO\"\n",
+		},
+		{"added columns don't count", []string{"-no-normalize-timestamp", "-no-normalize-durations", "-no-normalize-name", "-extract", "Notes=(.*)->Copy"}, testHeader + testRow, ""},
+		{"rejected rows aren't in it", []string{"-no-normalize-timestamp", "-no-normalize-name"}, testHeader + strings.Replace(testRow, "1:23:32.123", "soon", 1), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in
+			if in == "" {
+				in = testHeader + clean
+			}
+			out, err := runTest(t, testConfig(t, append(tt.args, "-diff")...), in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out, tt.want)
+			}
+		})
+	}
+}
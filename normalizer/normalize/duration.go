@@ -0,0 +1,179 @@
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ways durations can be written in the input
+const (
+	// Pick based on what the value looks like
+	durationFormatAuto = "auto"
+	// HH:MM:SS.MS, which is what the sample uses
+	durationFormatColon = "colon"
+	// Go style, like 1h30m15s, anything time.ParseDuration understands
+	durationFormatGo = "go"
+)
+
+// Values for -duration-rounding, for when -duration-precision leaves a
+// duration with more digits than it has room for
+const (
+	// Halves go to the even digit, like IEEE floats do
+	durationRoundingHalfEven = "half-even"
+	// Halves go up, away from zero
+	durationRoundingHalfUp = "half-up"
+	// The extra digits are just dropped
+	durationRoundingTruncate = "truncate"
+)
+
+// Values for -duration-output
+const (
+	// Seconds, like 5400.000000
+	durationOutputSeconds = "seconds"
+	// ISO 8601 durations, like PT1H30M
+	durationOutputISO8601 = "iso8601"
+)
+
+// Values for -total-source
+const (
+	// FooDuration plus BarDuration, whatever the input said
+	totalSourceCompute = "compute"
+	// The input's own TotalDuration, which has to be there and readable
+	totalSourceInput = "input"
+	// The input's TotalDuration if it isn't empty, otherwise compute it
+	totalSourcePreferInput = "prefer-input"
+)
+
+// formatISODuration writes d as an ISO 8601 duration using hours, minutes and
+// seconds only, so a day and an hour is PT25H: a day isn't always 24 hours
+// once DST gets involved, so P1DT1H would mean something else. Fractions of a
+// second are exact to the nanosecond with no trailing zeros (PT0.5S), zero is
+// PT0S, and since ISO 8601 has no negative durations, those get a leading
+// minus the way most parsers accept
+func formatISODuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	n := int64(d)
+	if n < 0 {
+		b.WriteByte('-')
+		n = -n
+	}
+	b.WriteString("PT")
+	hours := n / int64(time.Hour)
+	minutes := n / int64(time.Minute) % 60
+	nanos := n % int64(time.Minute)
+	if hours > 0 {
+		b.WriteString(strconv.FormatInt(hours, 10))
+		b.WriteByte('H')
+	}
+	if minutes > 0 {
+		b.WriteString(strconv.FormatInt(minutes, 10))
+		b.WriteByte('M')
+	}
+	if nanos > 0 {
+		b.WriteString(strconv.FormatInt(nanos/int64(time.Second), 10))
+		if frac := nanos % int64(time.Second); frac > 0 {
+			digits := strconv.FormatInt(frac+int64(time.Second), 10)[1:]
+			b.WriteByte('.')
+			b.WriteString(strings.TrimRight(digits, "0"))
+		}
+		b.WriteByte('S')
+	}
+	return b.String()
+}
+
+// parseDuration turns an input duration into a time.Duration, in the given
+// durationFormat*. In auto mode anything with a colon is HH:MM:SS.MS and
+// anything with a unit letter is Go style
+func parseDuration(s string, format string) (time.Duration, error) {
+	if format == durationFormatAuto {
+		switch {
+		case strings.Contains(s, ":"):
+			format = durationFormatColon
+		case strings.ContainsAny(s, "hmsuµn"):
+			format = durationFormatGo
+		default:
+			return 0, ErrDuration
+		}
+	}
+
+	if format == durationFormatGo {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, ErrDuration
+		}
+		return d, nil
+	}
+
+	// Go AFAICT doesn't have a good way to handle durations expressed as
+	// HH:MM:SS.MS so we'll just parse this ourselves
+	if d, ok := parseColonDuration(s); ok {
+		return d, nil
+	}
+	return scanColonDuration(s)
+}
+
+// scanColonDuration is the slow path for HH:MM:SS.MS, for anything
+// parseColonDuration won't take
+func scanColonDuration(s string) (time.Duration, error) {
+	var hour, minute, second, msec time.Duration
+	scanned, _ := fmt.Sscanf(s, "%d:%d:%d.%d", &hour, &minute, &second, &msec)
+	if scanned != 4 {
+		return 0, ErrDuration
+	}
+	return (time.Hour * hour) + (time.Minute * minute) + (time.Second * second) + (time.Millisecond * msec), nil
+}
+
+// canonicalDuration is the only shape -strict-duration-format lets through
+var canonicalDuration = regexp.MustCompile(`^[0-9]{2}:[0-9]{2}:[0-9]{2}\.[0-9]{3}$`)
+
+// parseInputDuration parses a duration from the input the way cfg says to
+func parseInputDuration(s string, cfg *Config) (time.Duration, error) {
+	if cfg.StrictDurationFormat && !canonicalDuration.MatchString(s) {
+		return 0, ErrDurationFormat
+	}
+	return parseDuration(s, cfg.DurationInputFormat)
+}
+
+// parseColonDuration is the fast path for HH:MM:SS.MS, the case nearly every
+// row hits. Sscanf allocates and goes through reflection on every call, which
+// showed up in profiles. This only handles plain digits; for anything else (a
+// sign, spaces, a number too big for int64) ok is false and scanColonDuration
+// decides, so the two always agree. Like Sscanf, whatever follows the
+// milliseconds' digits is ignored, and the milliseconds aren't scaled: .4 is
+// 4ms, not 400
+func parseColonDuration(s string) (d time.Duration, ok bool) {
+	var parts [4]int64
+	for i := range parts {
+		end := 0
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			return 0, false
+		}
+		n, err := strconv.ParseInt(s[:end], 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		parts[i] = n
+		s = s[end:]
+		if i < len(parts)-1 {
+			sep := byte(':')
+			if i == 2 {
+				sep = '.'
+			}
+			if len(s) == 0 || s[0] != sep {
+				return 0, false
+			}
+			s = s[1:]
+		}
+	}
+	hour, minute, second, msec := time.Duration(parts[0]), time.Duration(parts[1]), time.Duration(parts[2]), time.Duration(parts[3])
+	return (time.Hour * hour) + (time.Minute * minute) + (time.Second * second) + (time.Millisecond * msec), true
+}
@@ -0,0 +1,364 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in      string
+		format  string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1:23:32.123", durationFormatAuto, time.Hour + 23*time.Minute + 32*time.Second + 123*time.Millisecond, false},
+		{"111:23:32.123", durationFormatAuto, 111*time.Hour + 23*time.Minute + 32*time.Second + 123*time.Millisecond, false},
+		{"0:00:00.000", durationFormatColon, 0, false},
+		{"1h30m", durationFormatAuto, 90 * time.Minute, false},
+		{"1h30m", durationFormatGo, 90 * time.Minute, false},
+		{"1:00:00.000", durationFormatGo, 0, true},
+		{"zzsasdfa", durationFormatAuto, 0, true},
+		{"", durationFormatAuto, 0, true},
+		{"1:2", durationFormatColon, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in+"/"+tt.format, func(t *testing.T) {
+			got, err := parseDuration(tt.in, tt.format)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDurationInputFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		in     string
+		want   string
+	}{
+		{"auto", "1h30m", "5400.000000"},
+		{"auto", "1:30:00.000", "5400.000000"},
+		{"go", "90m0.5s", "5400.500000"},
+		{"colon", "1h30m", ""},
+		{"go", "1:30:00.000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.in, func(t *testing.T) {
+			// Both durations, since the format goes for either
+			row := strings.Replace(testRow, "1:23:32.123", tt.in, 1)
+			row = strings.Replace(row, "1:32:33.123", tt.in, 1)
+			records := outputRecords(t, testConfig(t, "-duration-input-format", tt.format), testHeader+row)
+			if tt.want == "" {
+				if len(records) != 1 {
+					t.Errorf("got %v, want the row rejected", records[1:])
+				}
+				return
+			}
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want 2", len(records))
+			}
+			if got := records[1][4]; got != tt.want {
+				t.Errorf("FooDuration = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := configError(t, "-duration-input-format", "iso"); err == nil {
+		t.Error("-duration-input-format iso: got no error")
+	}
+}
+
+func TestStrictDurationFormat(t *testing.T) {
+	tests := []struct {
+		in     string
+		strict error
+		loose  bool
+	}{
+		{"01:23:32.123", nil, true},
+		{"111:23:32.123", ErrDurationFormat, true},
+		{"1:23:32.123", ErrDurationFormat, true},
+		{"01:23:32.12", ErrDurationFormat, true},
+		{"01:23:32", ErrDurationFormat, false},
+		{"1h30m", ErrDurationFormat, true},
+		{" 01:23:32.123", ErrDurationFormat, true},
+	}
+	strict := testConfig(t, "-strict-duration-format")
+	loose := testConfig(t)
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if _, err := parseInputDuration(tt.in, strict); err != tt.strict {
+				t.Errorf("strict: got %v, want %v", err, tt.strict)
+			}
+			if _, err := parseInputDuration(tt.in, loose); (err == nil) != tt.loose {
+				t.Errorf("loose: got %v", err)
+			}
+		})
+	}
+
+	// The report tells it apart from a duration that didn't parse at all
+	row := strings.Replace(testRow, "1:23:32.123", "01:23:32.123", 1)
+	entries := readErrorReport(t, testHeader+row, "-strict-duration-format")
+	if len(entries) != 1 || entries[0].Field != "BarDuration" || entries[0].Type != "duration_format" {
+		t.Errorf("got %+v, want a duration_format error in BarDuration", entries)
+	}
+}
+
+// The fast path has to agree with Sscanf on everything it takes, and hand
+// everything else over
+func TestParseColonDurationMatchesSscanf(t *testing.T) {
+	inputs := []string{
+		"1:23:32.123",
+		"00:00:00.000",
+		"111:23:32.123",
+		"1:2:3.4",
+		"1:23:32.123abc",
+		"1:23:32.",
+		"1:23:32",
+		"1:23",
+		"",
+		"-1:23:32.123",
+		" 1:23:32.123",
+		"1: 23:32.123",
+		"+1:23:32.123",
+		"99999999999999999999:00:00.000",
+		"1:60:60.1000",
+		"a:b:c.d",
+	}
+	for _, in := range inputs {
+		slow, slowErr := scanColonDuration(in)
+		fast, ok := parseColonDuration(in)
+		if !ok {
+			continue
+		}
+		if slowErr != nil {
+			t.Errorf("%q: fast path took it as %v, Sscanf rejected it", in, fast)
+		} else if fast != slow {
+			t.Errorf("%q: fast path %v, Sscanf %v", in, fast, slow)
+		}
+	}
+}
+
+func TestFormatSeconds(t *testing.T) {
+	tests := []struct {
+		d         time.Duration
+		precision int
+		rounding  string
+		want      string
+	}{
+		{5012123 * time.Millisecond, 6, durationRoundingHalfEven, "5012.123000"},
+		{1500 * time.Millisecond, 0, durationRoundingHalfEven, "2"},
+		{2500 * time.Millisecond, 0, durationRoundingHalfEven, "2"},
+		{2500 * time.Millisecond, 0, durationRoundingHalfUp, "3"},
+		{2999 * time.Millisecond, 0, durationRoundingTruncate, "2"},
+		{-1500 * time.Millisecond, 1, durationRoundingHalfEven, "-1.5"},
+		{-1 * time.Nanosecond, 0, durationRoundingHalfEven, "0"},
+		{time.Nanosecond, 9, durationRoundingHalfEven, "0.000000001"},
+		// The README's examples
+		{3600005 * time.Millisecond, 2, durationRoundingHalfEven, "3600.00"},
+		{3600015 * time.Millisecond, 2, durationRoundingHalfEven, "3600.02"},
+		{3600005 * time.Millisecond, 2, durationRoundingHalfUp, "3600.01"},
+		{3600009 * time.Millisecond, 2, durationRoundingTruncate, "3600.00"},
+		{-2 * time.Millisecond, 2, durationRoundingHalfUp, "0.00"},
+		{5 * time.Millisecond, 3, durationRoundingHalfEven, "0.005"},
+	}
+	for _, tt := range tests {
+		if got := formatSeconds(tt.d, tt.precision, tt.rounding); got != tt.want {
+			t.Errorf("formatSeconds(%v, %d, %s) = %s, want %s", tt.d, tt.precision, tt.rounding, got, tt.want)
+		}
+	}
+}
+
+func TestDurationPrecisionFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+		err  string
+	}{
+		{"default", nil, []string{"5012.123000", "5553.123000", "10565.246000"}, ""},
+		{"precision", []string{"-duration-precision", "2"}, []string{"5012.12", "5553.12", "10565.25"}, ""},
+		{"whole seconds", []string{"-duration-precision", "0"}, []string{"5012", "5553", "10565"}, ""},
+		{"truncate", []string{"-duration-precision", "1", "-duration-rounding", "truncate"}, []string{"5012.1", "5553.1", "10565.2"}, ""},
+		{"too precise", []string{"-duration-precision", "10"}, nil, "between 0 and 9"},
+		{"negative", []string{"-duration-precision", "-1"}, nil, "between 0 and 9"},
+		{"unknown rounding", []string{"-duration-rounding", "up"}, nil, "unknown -duration-rounding"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err != "" {
+				if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			records := outputRecords(t, testConfig(t, tt.args...), testHeader+testRow)
+			if len(records) != 2 || strings.Join(records[1][4:7], ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want durations %q", records, tt.want)
+			}
+		})
+	}
+}
+
+func TestTotalSourceFlag(t *testing.T) {
+	withTotal := func(total string) string {
+		return strings.Replace(testRow, "zzsasdfa", total, 1)
+	}
+	tests := []struct {
+		name   string
+		source string
+		total  string
+		want   string
+		reject bool
+	}{
+		{"compute ignores garbage", "compute", "zzsasdfa", "10565.246000", false},
+		{"compute ignores a real one", "compute", "3:00:00.000", "10565.246000", false},
+		{"input", "input", "3:00:00.000", "10800.000000", false},
+		{"input missing", "input", "", "", true},
+		{"input unreadable", "input", "zzsasdfa", "", true},
+		{"prefer-input", "prefer-input", "3:00:00.000", "10800.000000", false},
+		{"prefer-input missing", "prefer-input", "", "10565.246000", false},
+		{"prefer-input unreadable", "prefer-input", "zzsasdfa", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testHeader + withTotal(tt.total)
+			if tt.reject {
+				entries := readErrorReport(t, in, "-total-source", tt.source)
+				if len(entries) != 1 || entries[0].Field != "TotalDuration" || entries[0].Type != "duration" {
+					t.Errorf("got %+v, want a duration error in TotalDuration", entries)
+				}
+				return
+			}
+			records := outputRecords(t, testConfig(t, "-total-source", tt.source), in)
+			if len(records) != 2 || records[1][6] != tt.want {
+				t.Errorf("got %q, want TotalDuration %s", records, tt.want)
+			}
+		})
+	}
+
+	// The percent columns go by the total that won
+	in := testHeader + withTotal("2:46:05.246")
+	records := outputRecords(t, testConfig(t, "-total-source", "input", "-add-percent-columns"), in)
+	if len(records) != 2 || records[1][8] != "50.30" {
+		t.Errorf("got %q, want FooPercent of the input's total", records)
+	}
+
+	// -strict-duration-format holds the input's total to the same shape as
+	// Foo and Bar
+	canonical := strings.Replace(strings.Replace(testRow, "1:23:32.123", "01:23:32.123", 1), "1:32:33.123", "01:32:33.123", 1)
+	for _, source := range []string{"input", "prefer-input"} {
+		in := testHeader + strings.Replace(canonical, "zzsasdfa", "3:00:00.000", 1)
+		entries := readErrorReport(t, in, "-total-source", source, "-strict-duration-format")
+		if len(entries) != 1 || entries[0].Field != "TotalDuration" || entries[0].Type != "duration_format" {
+			t.Errorf("%s: got %+v, want a duration_format error in TotalDuration", source, entries)
+		}
+		in = testHeader + strings.Replace(canonical, "zzsasdfa", "03:00:00.000", 1)
+		records := outputRecords(t, testConfig(t, "-total-source", source, "-strict-duration-format"), in)
+		if len(records) != 2 || records[1][6] != "10800.000000" {
+			t.Errorf("%s: got %q, want the input's canonical total", source, records)
+		}
+	}
+
+	if err := configError(t, "-total-source", "guess"); err == nil {
+		t.Error("expected an error for -total-source guess")
+	}
+}
+
+func TestFormatISODuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "PT0S"},
+		{90 * time.Minute, "PT1H30M"},
+		{25 * time.Hour, "PT25H"},
+		{500 * time.Millisecond, "PT0.5S"},
+		{-time.Second, "-PT1S"},
+		{time.Hour + 2*time.Second + time.Nanosecond, "PT1H2.000000001S"},
+	}
+	for _, tt := range tests {
+		if got := formatISODuration(tt.d); got != tt.want {
+			t.Errorf("formatISODuration(%v) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestDurationOutputFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"seconds", nil, "5012.123000,5553.123000,10565.246000"},
+		{"iso8601", []string{"-duration-output", "iso8601"}, "PT1H23M32.123S,PT1H32M33.123S,PT2H56M5.246S"},
+		// Precision is for seconds only
+		{"iso8601 ignores precision", []string{"-duration-output", "iso8601", "-duration-precision", "0"}, "PT1H23M32.123S,PT1H32M33.123S,PT2H56M5.246S"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := outputRecords(t, testConfig(t, tt.args...), testHeader+testRow)
+			if len(records) != 2 || strings.Join(records[1][4:7], ",") != tt.want {
+				t.Errorf("got %q, want durations %s", records, tt.want)
+			}
+		})
+	}
+
+	// JSON gets strings
+	out, err := runTest(t, testConfig(t, "-duration-output", "iso8601", "-output-format", "ndjson"), testHeader+testRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `"FooDuration":"PT1H23M32.123S"`) {
+		t.Errorf("got %s, want FooDuration as a string", out)
+	}
+
+	if err := configError(t, "-duration-output", "minutes"); err == nil {
+		t.Error("expected an error for -duration-output minutes")
+	}
+}
+
+func BenchmarkParseDuration(b *testing.B) {
+	b.Run("fast path", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			parseDuration("111:23:32.123", durationFormatAuto)
+		}
+	})
+	// What every row cost before the fast path
+	b.Run("sscanf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			scanColonDuration("111:23:32.123")
+		}
+	})
+}
+
+func BenchmarkFormatSeconds(b *testing.B) {
+	d := 401012123 * time.Millisecond
+	b.Run("strconv", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			formatSeconds(d, 6, durationRoundingHalfEven)
+		}
+	})
+	// What every duration cost before
+	b.Run("sprintf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = fmt.Sprintf("%f", d.Seconds())
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package normalize
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Best guesses -detect-encoding can make
+const (
+	encodingASCII       = "ascii"
+	encodingUTF8        = "utf-8"
+	encodingWindows1252 = "windows-1252"
+	encodingLatin1      = "latin-1"
+)
+
+// defaultDetectSample is how much of the input -detect-encoding looks at
+// unless told otherwise, in KB
+const defaultDetectSample = 64
+
+// encodingReport is what detectEncoding found in the start of an input
+type encodingReport struct {
+	Bytes int
+	// Bytes under 0x80, which read the same in all of them
+	ASCII int
+	// Bytes that were part of a valid multi-byte UTF-8 character, and the
+	// number of those characters
+	UTF8Bytes int
+	UTF8Runes int
+	// Bytes that aren't valid UTF-8
+	Invalid int
+	// Invalid bytes in 0x80-0x9f. Latin-1 has only control characters there,
+	// which nobody puts in a CSV, but Windows-1252 has curly quotes, dashes
+	// and the euro sign, which people do
+	C1 int
+	// C1 bytes that Windows-1252 leaves undefined too
+	Undefined1252 int
+	Guess         string
+}
+
+// undefined1252 are the bytes in 0x80-0x9f Windows-1252 doesn't use
+var undefined1252 = map[byte]bool{0x81: true, 0x8d: true, 0x8f: true, 0x90: true, 0x9d: true}
+
+// detectEncoding reads up to limit bytes of r and guesses what it's encoded
+// in. It's only a heuristic: anything that's valid UTF-8 is taken to be UTF-8,
+// and the only way to tell Latin-1 from Windows-1252 is whether the 0x80-0x9f
+// bytes turn up
+func detectEncoding(r io.Reader, limit int) (encodingReport, error) {
+	buf := make([]byte, limit)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return encodingReport{}, err
+	}
+	buf = buf[:n]
+
+	report := encodingReport{Bytes: n}
+	for i := 0; i < len(buf); {
+		b := buf[i]
+		if b < utf8.RuneSelf {
+			report.ASCII++
+			i++
+			continue
+		}
+		c, size := utf8.DecodeRune(buf[i:])
+		if c == utf8.RuneError && size == 1 {
+			// A character cut off by the end of the sample isn't invalid,
+			// there just wasn't room for the rest of it
+			if n == limit && !utf8.FullRune(buf[i:]) {
+				break
+			}
+			report.Invalid++
+			if b <= 0x9f {
+				report.C1++
+				if undefined1252[b] {
+					report.Undefined1252++
+				}
+			}
+			i++
+			continue
+		}
+		report.UTF8Bytes += size
+		report.UTF8Runes++
+		i += size
+	}
+
+	switch {
+	case report.Invalid == 0 && report.UTF8Runes == 0:
+		report.Guess = encodingASCII
+	case report.Invalid == 0:
+		report.Guess = encodingUTF8
+	case report.C1 > report.Undefined1252:
+		report.Guess = encodingWindows1252
+	default:
+		report.Guess = encodingLatin1
+	}
+	return report, nil
+}
+
+// Print writes the report out for -detect-encoding
+func (e encodingReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Sampled %d bytes\n", e.Bytes)
+	fmt.Fprintf(w, "ASCII bytes: %d\n", e.ASCII)
+	fmt.Fprintf(w, "Valid UTF-8 characters: %d (%d bytes)\n", e.UTF8Runes, e.UTF8Bytes)
+	fmt.Fprintf(w, "Invalid UTF-8 bytes: %d (%d in 0x80-0x9f, %d undefined in Windows-1252)\n", e.Invalid, e.C1, e.Undefined1252)
+	fmt.Fprintf(w, "Best guess: %s\n", e.Guess)
+}
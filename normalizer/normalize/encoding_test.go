@@ -0,0 +1,49 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		limit   int
+		guess   string
+		invalid int
+	}{
+		{"ascii", "plain text", 64, encodingASCII, 0},
+		{"utf-8", "café \U0001F600", 64, encodingUTF8, 0},
+		{"latin-1", "caf\xe9", 64, encodingLatin1, 1},
+		{"windows-1252", "\x93quoted\x94 \x80", 64, encodingWindows1252, 3},
+		{"undefined in windows-1252", "\x81\x8d", 64, encodingLatin1, 2},
+		// The é is cut in half by the end of the sample, which doesn't make
+		// it invalid
+		{"cut off", "café", 4, encodingASCII, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := detectEncoding(strings.NewReader(tt.in), tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Guess != tt.guess || report.Invalid != tt.invalid {
+				t.Errorf("got %+v, want guess %s with %d invalid", report, tt.guess, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestDetectEncodingFlag(t *testing.T) {
+	stdout, stderr, status := runMain(t, testHeader+"caf\xe9\n", "-detect-encoding")
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if !strings.Contains(stdout, "Invalid UTF-8 bytes: 1 ") || !strings.HasSuffix(stdout, "Best guess: latin-1\n") {
+		t.Errorf("got\n%s", stdout)
+	}
+	if _, _, status := runMain(t, "", "-detect-encoding", "-detect-encoding-sample", "0"); status != 2 {
+		t.Errorf("-detect-encoding-sample 0: exit status %d, want 2", status)
+	}
+}
@@ -0,0 +1,119 @@
+package normalize
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for each kind of normalization failure. Normalize wraps
+// these in a FieldError, so callers can use errors.Is to find out what kind of
+// problem a row had without parsing error strings
+var (
+	ErrTimestamp = errors.New("bad format for timestamp")
+	ErrDuration  = errors.New("bad format for duration")
+	ErrTimezone  = errors.New("unknown time zone")
+	// A field that wasn't valid UTF-8, with -invalid-utf8 reject
+	ErrUTF8 = errors.New("invalid UTF-8")
+	// A postal code that isn't one, see -zip-format
+	ErrZip = errors.New("bad format for zip")
+	// Durations that aren't exactly HH:MM:SS.mmm, see -strict-duration-format
+	ErrDurationFormat = errors.New("non-canonical duration")
+	// Durations that parse fine but don't add up, see -check-duration-consistency
+	ErrDurationConsistency = errors.New("implausible duration")
+	// A field that isn't the type the -schema says it is
+	ErrType = errors.New("not a valid")
+	// A column with no number in it, with -parse-number-strict
+	ErrNumber = errors.New("no number")
+	// With -check-zip-state, a ZIP in a different state from the Address
+	ErrZipState = errors.New("zip in a different state from the Address")
+	// A ZIP that isn't in the -zip-allowlist
+	ErrZipNotAllowed = errors.New("zip not in the -zip-allowlist")
+	// A -bool-columns value that isn't one of the tokens
+	ErrBool = errors.New("not a boolean")
+	// Not a FieldError, since it's the whole row that's wrong
+	ErrFieldCount = errors.New("wrong number of fields")
+
+	// The run stopped at -max-rows with input left over. It's not about any
+	// one row, so it's only ever returned from the run as a whole
+	ErrMaxRows = errors.New("hit -max-rows")
+	// The same for -time-limit
+	ErrTimeLimit = errors.New("hit -time-limit")
+
+	// With -assume-sorted, a row's timestamp was before the one ahead of it.
+	// The streaming dedupe has already forgotten what came before, so there's
+	// no carrying on
+	ErrNotSorted = errors.New("input isn't sorted by timestamp")
+
+	// With -fail-on-empty, the run didn't write a single good row. Like
+	// ErrMaxRows, it's about the whole run
+	ErrEmptyOutput = errors.New("no rows written")
+
+	// With -compare-to, the output wasn't the same as the saved one
+	ErrMismatch = errors.New("output doesn't match -compare-to")
+
+	// A Transform hook returns ErrSkip to drop a record. It's not a problem
+	// with the record, so it isn't reported anywhere
+	ErrSkip = errors.New("skip record")
+)
+
+// errSkipKey is how -skip-keys drops a row, so it can be counted apart from
+// the hook's
+var errSkipKey = fmt.Errorf("%w: in -skip-keys", ErrSkip)
+
+// annotateErrorsColumn is the extra column -annotate-errors puts the error in.
+// The underscore keeps it from looking like one of the data columns
+const annotateErrorsColumn = "_error"
+
+// FieldError records which field failed to normalize and what was in it
+type FieldError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%v in %s: %q", e.Err, e.Field, e.Value)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// errorType gives a short, stable name for the kind of error, suitable for
+// machine-readable output
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, ErrTimestamp):
+		return "timestamp"
+	case errors.Is(err, ErrDuration):
+		return "duration"
+	case errors.Is(err, ErrDurationFormat):
+		return "duration_format"
+	case errors.Is(err, ErrDurationConsistency):
+		return "duration_consistency"
+	case errors.Is(err, ErrTimezone):
+		return "timezone"
+	case errors.Is(err, ErrUTF8):
+		return "utf8"
+	case errors.Is(err, ErrZip):
+		return "zip"
+	case errors.Is(err, ErrType):
+		return "type"
+	case errors.Is(err, ErrNumber):
+		return "number"
+	case errors.Is(err, ErrZipState):
+		return "zip_state"
+	case errors.Is(err, ErrZipNotAllowed):
+		return "zip_allowlist"
+	case errors.Is(err, ErrBool):
+		return "bool"
+	case errors.Is(err, ErrFieldCount):
+		return "field_count"
+	default:
+		return "unknown"
+	}
+}
+
+// errorTypes is every name errorType can give, for reports that want a line
+// for each even when it's zero
+var errorTypes = []string{"timestamp", "duration", "duration_format", "duration_consistency", "timezone", "utf8", "zip", "type", "number", "zip_state", "zip_allowlist", "bool", "field_count", "unknown"}
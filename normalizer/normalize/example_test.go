@@ -0,0 +1,71 @@
+package normalize_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"normalizer/normalize"
+)
+
+// These are built from outside the package, the way another program would
+// use it
+
+const exampleInput = `Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes
+4/1/11 11:00:00 AM,123 4th St,94121,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa,notes
+`
+
+func ExampleTransform() {
+	err := normalize.Transform(strings.NewReader(exampleInput), os.Stdout, func(r *normalize.Record) error {
+		r.Notes = strings.ToUpper(r.Notes)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes
+	// 2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,NOTES
+}
+
+func ExampleNewNormalizer() {
+	cfg := normalize.DefaultConfig()
+	cfg.DestTZ = "Asia/Tokyo"
+	cfg.OutputFormat = "ndjson"
+	n, err := normalize.NewNormalizer(cfg)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if err := n.Transform(strings.NewReader(exampleInput), os.Stdout, nil); err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// {"Timestamp":"2011-04-02T03:00:00+09:00","Address":"123 4th St","ZIP":"94121","FullName":"MONKEY ALBERTO","FooDuration":5012.123000,"BarDuration":5553.123000,"TotalDuration":10565.246000,"Notes":"notes"}
+}
+
+func ExampleRecord_Validate() {
+	cfg := normalize.DefaultConfig()
+	r, err := normalize.RecordFromFields([]string{"4/1/11 11:00:00 AM", "123 4th St", "94121", "Monkey Alberto", "soon", "1:32:33.123", "", "notes"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(r.Validate(cfg))
+	fmt.Println(r.FooDuration)
+	// Output:
+	// bad format for duration in FooDuration: "soon"
+	// soon
+}
+
+func ExampleTransformBatches() {
+	err := normalize.TransformBatches(strings.NewReader(exampleInput), 100, func(b *normalize.Batch) error {
+		fmt.Println(b.Len(), b.Column("ZIP"), b.TotalDurations)
+		return nil
+	})
+	if err != nil {
+		fmt.Println(err)
+	}
+	// Output:
+	// 1 [94121] [2h56m5.246s]
+}
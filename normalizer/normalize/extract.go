@@ -0,0 +1,67 @@
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Extraction is one -extract rule: run Pattern over the Source column and put
+// what it finds in a new Column
+type Extraction struct {
+	Source  string
+	Pattern *regexp.Regexp
+	Column  string
+}
+
+// parseExtraction parses Source=regex->Column. The regex can itself contain =
+// or ->, so we split on the first = and the last ->
+func parseExtraction(s string) (Extraction, error) {
+	eq := strings.Index(s, "=")
+	arrow := strings.LastIndex(s, "->")
+	if eq < 1 || arrow < eq || arrow+2 == len(s) {
+		return Extraction{}, fmt.Errorf("expected Column=regex->NewColumn, got %q", s)
+	}
+	source := s[:eq]
+	if columnIndex(source) < 0 {
+		return Extraction{}, fmt.Errorf("unknown column %q", source)
+	}
+	pattern, err := regexp.Compile(s[eq+1 : arrow])
+	if err != nil {
+		return Extraction{}, err
+	}
+	return Extraction{Source: source, Pattern: pattern, Column: s[arrow+2:]}, nil
+}
+
+// extract returns the first capture group of the first match in s, or the
+// whole match if the pattern has no groups. No match gives an empty string
+func (e Extraction) extract(s string) string {
+	match := e.Pattern.FindStringSubmatch(s)
+	switch {
+	case match == nil:
+		return ""
+	case len(match) > 1:
+		return match[1]
+	}
+	return match[0]
+}
+
+// extractionList is the flag.Value behind -extract, which can be repeated
+type extractionList []Extraction
+
+func (l *extractionList) String() string {
+	var rules []string
+	for _, e := range *l {
+		rules = append(rules, e.Source+"="+e.Pattern.String()+"->"+e.Column)
+	}
+	return strings.Join(rules, " ")
+}
+
+func (l *extractionList) Set(s string) error {
+	e, err := parseExtraction(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, e)
+	return nil
+}
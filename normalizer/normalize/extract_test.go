@@ -0,0 +1,75 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseExtraction(t *testing.T) {
+	tests := []struct {
+		in      string
+		source  string
+		pattern string
+		column  string
+		wantErr bool
+	}{
+		{`Notes=(\S+@\S+)->Email`, "Notes", `(\S+@\S+)`, "Email", false},
+		{`Notes=a=b->c->Out`, "Notes", `a=b->c`, "Out", false},
+		{`notes=x->Out`, "notes", `x`, "Out", false},
+		{`Notes=x`, "", "", "", true},
+		{`Notes=x->`, "", "", "", true},
+		{`=x->Out`, "", "", "", true},
+		{`Email=x->Out`, "", "", "", true},
+		{`Notes=(->Out`, "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseExtraction(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Source != tt.source || got.Pattern.String() != tt.pattern || got.Column != tt.column {
+				t.Errorf("got %s, %s, %s", got.Source, got.Pattern, got.Column)
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		pattern string
+		in      string
+		want    string
+	}{
+		{`(\S+@\S+)`, "mail bob@example.com today", "bob@example.com"},
+		{`\d+`, "ticket 42 and 43", "42"},
+		{`#(\d+)`, "no ticket", ""},
+		{`(a)|(b)`, "b", ""},
+	}
+	for _, tt := range tests {
+		e, err := parseExtraction("Notes=" + tt.pattern + "->Out")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := e.extract(tt.in); got != tt.want {
+			t.Errorf("%s on %q = %q, want %q", tt.pattern, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExtractColumns(t *testing.T) {
+	row := strings.Replace(testRow, "notes", "write to Bob@Example.com re #12", 1)
+	records := outputRecords(t, testConfig(t, "-extract", `Notes=(\S+@\S+)->Email`, "-extract", `Notes=#(\d+)->Ticket`, "-extract-lowercase"), testHeader+row)
+	if got := strings.Join(records[0][8:], ","); got != "Email,Ticket" {
+		t.Errorf("extra columns = %s", got)
+	}
+	if got := strings.Join(records[1][8:], ","); got != "bob@example.com,12" {
+		t.Errorf("extracted %s", got)
+	}
+}
@@ -0,0 +1,237 @@
+package normalize
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// commaList is a flag.Value for flags that take a comma separated list
+type commaList []string
+
+func (l *commaList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *commaList) Set(s string) error {
+	*l = strings.Split(s, ",")
+	return nil
+}
+
+// layoutList is a flag.Value for -timestamp-layout. Layouts can have commas
+// in them, so it takes one per flag instead; the first one given replaces the
+// defaults and the rest add to it
+type layoutList struct {
+	layouts  *[]string
+	replaced bool
+}
+
+func (l *layoutList) String() string {
+	if l.layouts == nil {
+		return ""
+	}
+	return strings.Join(*l.layouts, "; ")
+}
+
+func (l *layoutList) Set(s string) error {
+	if !l.replaced {
+		*l.layouts = nil
+		l.replaced = true
+	}
+	*l.layouts = append(*l.layouts, s)
+	return nil
+}
+
+// delimiterFlag is a flag.Value for a single character separator. Since tabs
+// are awkward to type, "tab" and "\t" both mean one, and "auto" asks for it
+// to be worked out from the input
+type delimiterFlag rune
+
+func (d *delimiterFlag) String() string {
+	if rune(*d) == delimiterAuto {
+		return "auto"
+	}
+	return string(rune(*d))
+}
+
+func (d *delimiterFlag) Set(s string) error {
+	if s == "auto" {
+		*d = delimiterFlag(delimiterAuto)
+		return nil
+	}
+	if s == "tab" || s == `\t` {
+		s = "\t"
+	}
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '"' || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+		return fmt.Errorf("delimiter has to be a single character, other than a quote or newline")
+	}
+	*d = delimiterFlag(r[0])
+	return nil
+}
+
+// separatorFlag is a flag.Value for -record-separator. It takes Go escapes,
+// since the characters people want are usually unprintable, so \x1e and
+// \u001e both work
+type separatorFlag rune
+
+func (s *separatorFlag) String() string {
+	if *s == 0 {
+		return ""
+	}
+	return strconv.QuoteRune(rune(*s))
+}
+
+func (s *separatorFlag) Set(value string) error {
+	unquoted, err := strconv.Unquote(`"` + value + `"`)
+	r := []rune(unquoted)
+	if err != nil || len(r) != 1 || r[0] == '"' || r[0] == utf8.RuneError {
+		return fmt.Errorf("record separator has to be a single character other than a quote, like \\x1e")
+	}
+	*s = separatorFlag(r[0])
+	return nil
+}
+
+// quoteFlag is a flag.Value for a single quote character
+type quoteFlag rune
+
+func (q *quoteFlag) String() string {
+	return string(rune(*q))
+}
+
+func (q *quoteFlag) Set(s string) error {
+	r := []rune(s)
+	if len(r) != 1 || r[0] == '\r' || r[0] == '\n' || r[0] == utf8.RuneError {
+		return fmt.Errorf("quote has to be a single character, other than a newline")
+	}
+	*q = quoteFlag(r[0])
+	return nil
+}
+
+// RegisterFlags hooks every command line flag up to its field in cfg. Whatever
+// is already in cfg becomes the flag's default
+func RegisterFlags(fs *flag.FlagSet, cfg *Config) {
+	fs.BoolVar(&cfg.CaseInsensitiveHeaders, "case-insensitive-headers", cfg.CaseInsensitiveHeaders, "match input column names ignoring case (ZIP, Zip and zip are all the same column)")
+	fs.BoolVar(&cfg.PrettyErrors, "pretty-errors", cfg.PrettyErrors, "at the end, print a summary of rejected rows to stderr, counted by field and kind of error with a few example lines each")
+	fs.StringVar(&cfg.ErrorReport, "error-report", cfg.ErrorReport, "write a JSON array describing every rejected row to this `path`")
+	fs.StringVar(&cfg.ErrorStream, "error-stream", cfg.ErrorStream, "write each rejected row to this `path` as one line of JSON, as soon as it's rejected (e.g. /dev/fd/3)")
+	fs.StringVar(&cfg.ColumnsReport, "columns-report", cfg.ColumnsReport, "write a JSON profile of every output column (empty and distinct counts, most common values, min and max) to this `path`")
+	fs.StringVar(&cfg.DurationInputFormat, "duration-input-format", cfg.DurationInputFormat, "how input durations are written: auto, colon (HH:MM:SS.MS) or go (1h30m15s)")
+	fs.BoolVar(&cfg.StrictDurationFormat, "strict-duration-format", cfg.StrictDurationFormat, "reject FooDuration and BarDuration, and TotalDuration when it's read from the input, unless they're exactly HH:MM:SS.mmm, two digits each for hours, minutes and seconds and three for milliseconds")
+	fs.BoolVar(&cfg.AddPercentColumns, "add-percent-columns", cfg.AddPercentColumns, "append FooPercent and BarPercent columns, each duration as a percentage of TotalDuration")
+	fs.StringVar(&cfg.DurationOutput, "duration-output", cfg.DurationOutput, "how to write the durations: seconds, or iso8601 (like PT1H30M)")
+	fs.StringVar(&cfg.TotalSource, "total-source", cfg.TotalSource, "where TotalDuration comes from: compute (FooDuration plus BarDuration), input (the input's own), or prefer-input (the input's unless it's empty)")
+	fs.IntVar(&cfg.DurationPrecision, "duration-precision", cfg.DurationPrecision, "decimal places for the duration seconds, 0 to 9")
+	fs.StringVar(&cfg.DurationRounding, "duration-rounding", cfg.DurationRounding, "how to round durations to -duration-precision: half-even, half-up, or truncate")
+	fs.IntVar(&cfg.PercentPrecision, "percent-precision", cfg.PercentPrecision, "decimal places for the percent columns")
+	fs.StringVar(&cfg.OutputFormat, "output-format", cfg.OutputFormat, "what to write: csv, json (one array) or ndjson (an object per line)")
+	fs.BoolVar(&cfg.NoHeader, "no-header", cfg.NoHeader, "the input has no header row, treat the first line as data")
+	fs.BoolVar(&cfg.AutoNoHeader, "auto-no-header", cfg.AutoNoHeader, "if the header doesn't name the columns but looks like a row of data, read it as data, in -columns or canonical order")
+	fs.Var((*commaList)(&cfg.Columns), "columns", "comma separated input column `names`, in order, for use with -no-header (default is the canonical order)")
+	fs.BoolVar(&cfg.WriteHeader, "write-header", cfg.WriteHeader, "with -no-header, still write the canonical header to the output")
+	fs.IntVar(&cfg.YearPivot, "year-pivot", cfg.YearPivot, "two-digit years below this are in the 2000s, the rest in the 1900s")
+	fs.BoolVar(&cfg.Stats, "stats", cfg.Stats, "print min, max, mean, p50 and p95 of TotalDuration to stderr at the end")
+	fs.BoolVar(&cfg.PadShortRows, "pad-short-rows", cfg.PadShortRows, "pad rows with too few fields out to the header width with empty fields")
+	fs.BoolVar(&cfg.TolerateTrailingComma, "tolerate-trailing-comma", cfg.TolerateTrailingComma, "accept rows with exactly one field too many if that last field is empty, like from a trailing delimiter, and drop it")
+	fs.BoolVar(&cfg.MergeTrailingIntoNotes, "merge-trailing-into-notes", cfg.MergeTrailingIntoNotes, "for rows wider than the header, join the extra fields back onto Notes with the delimiter, for unquoted Notes with delimiters in; Notes has to be the last column")
+	fs.BoolVar(&cfg.TruncateLongRows, "truncate-long-rows", cfg.TruncateLongRows, "drop trailing fields from rows wider than the header")
+	fs.StringVar(&cfg.UnicodeNormalize, "unicode-normalize", cfg.UnicodeNormalize, "Unicode normalization form for Address, FullName and Notes: nfc, nfd or off")
+	fs.BoolVar(&cfg.StripDiacritics, "strip-diacritics", cfg.StripDiacritics, "fold Address, FullName and Notes down to ASCII, taking accents off (José is Jose) and writing -non-ascii-placeholder for anything else")
+	fs.StringVar(&cfg.NonASCIIPlaceholder, "non-ascii-placeholder", cfg.NonASCIIPlaceholder, "with -strip-diacritics, what to write for a character that has no ASCII version, empty to drop it")
+	fs.StringVar(&cfg.NotesNewlines, "notes-newlines", cfg.NotesNewlines, "what to do with line breaks in Notes: preserve, strip-trailing or replace")
+	fs.StringVar(&cfg.NotesNewlineReplacement, "notes-newline-replacement", cfg.NotesNewlineReplacement, "with -notes-newlines replace, the `text` each line break in Notes becomes")
+	fs.BoolVar(&cfg.CollapseWhitespace, "collapse-whitespace", cfg.CollapseWhitespace, "trim Address, FullName and Notes, and turn every run of whitespace in them (line breaks included) into a single space")
+	fs.StringVar(&cfg.ControlChars, "control-chars", cfg.ControlChars, "what to do with control characters other than line breaks in Address, FullName and Notes: keep, strip, or escape (as \\t, \\x00 and so on)")
+	fs.StringVar(&cfg.SourceTZ, "source-tz", cfg.SourceTZ, "IANA time `zone` input timestamps are in")
+	fs.StringVar(&cfg.DestTZ, "dest-tz", cfg.DestTZ, "IANA time `zone` to write timestamps in")
+	fs.Var(&layoutList{layouts: &cfg.TimestampLayouts}, "timestamp-layout", "a Go time `layout` to parse Timestamp with; repeat it for more than one, tried in order, replacing the defaults (see -list-formats). Put MST in a layout to accept zone abbreviations like PST or EDT")
+	fs.StringVar(&cfg.TimestampTruncate, "timestamp-truncate", cfg.TimestampTruncate, "cut Timestamp down to the start of its day, hour, minute or second in -dest-tz (off to leave it)")
+	fs.BoolVar(&cfg.TimestampDateOnly, "timestamp-date-only", cfg.TimestampDateOnly, "write just Timestamp's date in -dest-tz, like 2011-04-01")
+	fs.Var((*commaList)(&cfg.TimestampColumns), "timestamp-columns", "build Timestamp by joining these input `columns` (e.g. Date,Time), for feeds with no Timestamp column")
+	fs.StringVar(&cfg.TimestampJoin, "timestamp-join", cfg.TimestampJoin, "with -timestamp-columns, what to put between the columns' values")
+	fs.BoolVar(&cfg.NormalizeAMPM, "normalize-ampm", cfg.NormalizeAMPM, "accept AM/PM markers written like am, p.m. or 3:04:05PM in Timestamp")
+	fs.StringVar(&cfg.SkipKeys, "skip-keys", cfg.SkipKeys, "CSV `file` of -key-columns keys, one per line; rows whose normalized values match one are skipped")
+	fs.Var((*commaList)(&cfg.KeyColumns), "key-columns", "the `columns` (e.g. FullName,Timestamp) making up a -skip-keys key")
+	fs.StringVar(&cfg.Schema, "schema", cfg.Schema, "JSON `file` declaring column types (string, int, zip, timestamp, duration) to check every row against")
+	fs.Var((*inputList)(&cfg.Inputs), "input", "read this `file` or http(s) URL instead of stdin (- for stdin); repeat it to merge several files into one output")
+	fs.StringVar(&cfg.HeaderMismatchPolicy, "header-mismatch-policy", cfg.HeaderMismatchPolicy, "with several -input files, what to do when one's header isn't the same as the first one's: error, skip-file, or remap (match its columns up by name)")
+	fs.BoolVar(&cfg.ColumnsFromFirstFile, "columns-from-first-file", cfg.ColumnsFromFirstFile, "with several -input files, only the first has a header, and the rest have the same columns in the same order")
+	fs.StringVar(&cfg.InputFormat, "input-format", cfg.InputFormat, "what the input is: csv, or fixed (fixed width, see -fixed-spec)")
+	fs.Var(&cfg.FixedSpec, "fixed-spec", "with -input-format fixed, the columns as `name:start:length,...`, with start counting characters from 1")
+	fs.BoolVar(&cfg.FixedTrim, "fixed-trim", cfg.FixedTrim, "with -input-format fixed, trim padding spaces off each field")
+	fs.Var((*delimiterFlag)(&cfg.Delimiter), "delimiter", "field separator in the input, a single `character` (use tab or \\t for a tab), or auto to work it out from the first line")
+	fs.Var((*separatorFlag)(&cfg.RecordSeparator), "record-separator", "`character` that ends each input record instead of a newline, with Go escapes for unprintable ones like \\x1e")
+	fs.BoolVar(&cfg.CanonicalizeNewlines, "canonicalize-newlines", cfg.CanonicalizeNewlines, "turn \\r\\n and lone \\r line endings in the input into \\n before reading it, for files with a mix of them")
+	fs.Var((*quoteFlag)(&cfg.QuoteChar), "quote-char", "`character` the input quotes fields with, e.g. ' (see the README for the limitations)")
+	fs.StringVar(&cfg.DestTZColumn, "dest-tz-column", cfg.DestTZColumn, "input `column` naming the IANA time zone (like Europe/London) to convert each row's Timestamp to, instead of US/Eastern")
+	fs.Float64Var(&cfg.Rate, "rate", cfg.Rate, "write at most `N` records per second (0 means unthrottled)")
+	fs.BoolVar(&cfg.ProvenanceComment, "provenance-comment", cfg.ProvenanceComment, "start the output with a comment line giving the version, the time and a hash of the settings")
+	fs.StringVar(&cfg.CommentPrefix, "comment-prefix", cfg.CommentPrefix, "what the -provenance-comment line starts with, for consumers that want something other than #")
+	fs.StringVar(&cfg.Checkpoint, "checkpoint", cfg.Checkpoint, "every 10000 rows and at the end, save the last input line finished with to this `path`")
+	fs.BoolVar(&cfg.Resume, "resume", cfg.Resume, "skip the input lines the -checkpoint says are done, and add on to the -output rather than starting it over")
+	fs.BoolVar(&cfg.Footer, "footer", cfg.Footer, "end the output with a \"# rows=N crc32=XXXXXXXX\" line covering the data rows")
+	fs.BoolVar(&cfg.OutputBOM, "output-bom", cfg.OutputBOM, "start the csv output with a UTF-8 byte order mark, so Excel knows it's UTF-8")
+	fs.BoolVar(&cfg.NoNormalizeTimestamp, "no-normalize-timestamp", cfg.NoNormalizeTimestamp, "pass Timestamp through untouched")
+	fs.BoolVar(&cfg.NoNormalizeDurations, "no-normalize-durations", cfg.NoNormalizeDurations, "pass FooDuration, BarDuration and TotalDuration through untouched")
+	fs.BoolVar(&cfg.NoNormalizeZip, "no-normalize-zip", cfg.NoNormalizeZip, "pass ZIP through untouched")
+	fs.StringVar(&cfg.ZipMode, "zip-mode", cfg.ZipMode, "with -zip-format us, how to write ZIP: pad5 (pad to 5 digits), strip (take leading zeroes off, for integer columns), or plus4 (5 digits, or ZIP+4 as 12345-6789)")
+	fs.StringVar(&cfg.ZipFormat, "zip-format", cfg.ZipFormat, "how to normalize ZIP: us (pad to 5 digits), ca or uk (check and write as A1A 1A1 or SW1A 1AA), or passthrough")
+	fs.BoolVar(&cfg.NoNormalizeName, "no-normalize-name", cfg.NoNormalizeName, "pass FullName through untouched")
+	fs.StringVar(&cfg.NameCase, "name-case", cfg.NameCase, "how to case FullName: upper, or title-smart (title case with particles like van and de kept lowercase)")
+	fs.StringVar(&cfg.AddressCase, "address-case", cfg.AddressCase, "how to case Address: off (leave it), or smart (title case, with directions and unit designators like NW and APT uppercase, and numbers left alone)")
+	fs.Var((*commaList)(&cfg.AddressUpper), "address-upper", "comma separated `words` -address-case smart keeps uppercase")
+	fs.BoolVar(&cfg.SplitName, "split-name", cfg.SplitName, "add FirstName and LastName columns, splitting FullName at its last space (keeping suffixes like Jr with the last name)")
+	fs.BoolVar(&cfg.DropFullName, "drop-full-name", cfg.DropFullName, "with -split-name, leave the FullName column out of the output")
+	fs.Var((*commaList)(&cfg.NameParticles), "name-particles", "comma separated `words` -name-case title-smart keeps lowercase unless they start the name")
+	fs.StringVar(&cfg.DSTPolicy, "dst-policy", cfg.DSTPolicy, "which instant a Timestamp means when it happens twice as the clocks go back: earliest, latest or error (which also rejects times skipped when the clocks go forward)")
+	fs.Var((*commaList)(&cfg.NullTokens), "null-tokens", "comma separated `values`, like \\N,NULL, that mean a field is empty; a field that's exactly one of them is emptied before anything else")
+	fs.Var((*replacementList)(&cfg.Replacements), "replace", "find and replace in a column before normalizing it, as `Column:/regex/=replacement` or Column:text=replacement (can be repeated)")
+	fs.Var((*extractionList)(&cfg.Extracts), "extract", "derive a new column, as `Column=regex->NewColumn`, from the first capture group of regex in Column (can be repeated)")
+	fs.Var((*numberRuleList)(&cfg.ParseNumbers), "parse-number", "derive a new column, as `Column->NewColumn`, holding the first number in Column with any currency symbol and thousands separators taken out (can be repeated)")
+	fs.BoolVar(&cfg.ParseNumberStrict, "parse-number-strict", cfg.ParseNumberStrict, "reject rows where a -parse-number column has no number in it, instead of leaving the new column empty")
+	fs.BoolVar(&cfg.ExtractLowercase, "extract-lowercase", cfg.ExtractLowercase, "lowercase the values -extract finds")
+	fs.BoolVar(&cfg.CheckDurationConsistency, "check-duration-consistency", cfg.CheckDurationConsistency, "reject rows where FooDuration or BarDuration is negative, over -max-duration, or longer than the input's TotalDuration")
+	fs.DurationVar(&cfg.MaxDuration, "max-duration", cfg.MaxDuration, "with -check-duration-consistency, the longest a single duration can be, e.g. 48h (0 for no limit)")
+	fs.StringVar(&cfg.OnDuplicateTimestamp, "on-duplicate-timestamp", cfg.OnDuplicateTimestamp, "what to do with rows whose normalized Timestamp is the same as another's: keep-all, keep-first, or keep-last (which holds every row in memory until the end)")
+	fs.StringVar(&cfg.InvalidUTF8, "invalid-utf8", cfg.InvalidUTF8, "what to do with fields that aren't valid UTF-8: repair (replace the bad bytes and carry on) or reject the row")
+	fs.IntVar(&cfg.MaxRows, "max-rows", cfg.MaxRows, "stop after `n` data rows, exiting with status 3 if there were more; 0 for no limit")
+	fs.DurationVar(&cfg.TimeLimit, "time-limit", cfg.TimeLimit, "stop reading once the run has taken this `duration` (e.g. 5m), finish the output, and exit with status 4; 0 for no limit")
+	fs.BoolVar(&cfg.Follow, "follow", cfg.Follow, "at the end of the input, wait for more to be appended, like tail -f")
+	fs.DurationVar(&cfg.IdleTimeout, "idle-timeout", cfg.IdleTimeout, "treat an input as finished once nothing new has come in for this `duration` (e.g. 30s), for FIFOs and -follow; 0 waits forever")
+	fs.BoolVar(&cfg.PreserveInputOrder, "preserve-input-order", cfg.PreserveInputOrder, "write the columns in the order the (first) input has them, instead of the canonical order")
+	fs.BoolVar(&cfg.FailOnEmpty, "fail-on-empty", cfg.FailOnEmpty, "exit with status 1 if no good rows were written, because the input was empty or every row was rejected")
+	fs.Float64Var(&cfg.MaxUTF8ReplacementRate, "max-utf8-replacement-rate", cfg.MaxUTF8ReplacementRate, "stop with an error if more than this `fraction` of fields (e.g. 0.05) have invalid UTF-8, which usually means the input isn't UTF-8 at all; 0 for no limit")
+	fs.BoolVar(&cfg.AnnotateErrors, "annotate-errors", cfg.AnnotateErrors, "write rows that fail to normalize too, unchanged, with the error in an extra _error column (empty for good rows)")
+	fs.BoolVar(&cfg.Interactive, "interactive", cfg.Interactive, "read the header, then normalize each line from stdin as soon as it's entered, printing the result or what went wrong")
+	fs.IntVar(&cfg.Preview, "preview", cfg.Preview, "instead of csv, normalize the first `N` rows and print them as a table lined up for reading")
+	fs.IntVar(&cfg.MaxColWidth, "max-col-width", cfg.MaxColWidth, "with -preview, cut cells down to this many `characters`, ending in …")
+	fs.BoolVar(&cfg.Diff, "diff", cfg.Diff, "instead of the normalized rows, write which fields changed in each row, before and after")
+	fs.StringVar(&cfg.CompareTo, "compare-to", cfg.CompareTo, "instead of writing the output, compare it row by row with a saved csv output at this `path` and report the rows that differ, exiting with status 1 if any do")
+	fs.StringVar(&cfg.PromTextfile, "prom-textfile", cfg.PromTextfile, "when the run's over, write its row counts to this `file` in Prometheus text format, for node_exporter's textfile collector")
+	fs.StringVar(&cfg.RunMetadata, "run-metadata", cfg.RunMetadata, "after the run, write a JSON `file` describing it: version, settings, inputs, row counts and start and end times")
+	fs.IntVar(&cfg.SplitRows, "split-rows", cfg.SplitRows, "instead of stdout, write files of at most `n` rows each, named like output-000.csv")
+	fs.StringVar(&cfg.SplitBy, "split-by", cfg.SplitBy, "instead of stdout, write a file for each value of this output `column`, named like output-94121.csv")
+	fs.StringVar(&cfg.SplitPrefix, "split-prefix", cfg.SplitPrefix, "with -split-rows or -split-by, what the file names start with, directory included")
+	fs.IntVar(&cfg.SplitMaxOpen, "split-max-open", cfg.SplitMaxOpen, "with -split-by, how many `files` to keep open at once; the one written to least recently is closed, and added on to if it's needed again")
+	fs.StringVar(&cfg.Output, "output", cfg.Output, "write the output to this `path` instead of stdout, or to a URI like s3://bucket/key if a writer for the scheme has been registered")
+	fs.StringVar(&cfg.Tee, "tee", cfg.Tee, "also write the output to this `path`, as well as stdout or -output")
+	fs.DurationVar(&cfg.MetricsInterval, "metrics-interval", cfg.MetricsInterval, "every `interval` (e.g. 10s), add a JSON line of rows read, errors and current rate to -metrics-file")
+	fs.StringVar(&cfg.MetricsFile, "metrics-file", cfg.MetricsFile, "`file` for the -metrics-interval lines")
+	fs.DurationVar(&cfg.DedupeWindow, "dedupe-window", cfg.DedupeWindow, "with -on-duplicate-timestamp keep-first, forget timestamps more than this far behind the newest one, e.g. 10m, to bound memory on roughly sorted input (0 remembers everything)")
+	fs.BoolVar(&cfg.CheckZipState, "check-zip-state", cfg.CheckZipState, "reject rows where Address ends with a state, like Springfield, IL, that the ZIP isn't in, going by the ZIP's first three digits")
+	fs.StringVar(&cfg.ZipAllowlist, "zip-allowlist", cfg.ZipAllowlist, "reject rows whose ZIP, once normalized, isn't one of the ones in this `file`, one per line")
+	fs.BoolVar(&cfg.AddOffsetColumn, "add-offset-column", cfg.AddOffsetColumn, "add an "+offsetColumn+" column with the byte offset each row starts at in its input, for seeking back to it later")
+	fs.Var((*commaList)(&cfg.BoolColumns), "bool-columns", "comma separated input `columns`, outside the usual ones, to write out too as true or false")
+	fs.Var((*commaList)(&cfg.BoolTrue), "bool-true", "comma separated `tokens` -bool-columns reads as true, ignoring case")
+	fs.Var((*commaList)(&cfg.BoolFalse), "bool-false", "comma separated `tokens` -bool-columns reads as false, ignoring case")
+	fs.StringVar(&cfg.BoolOutput, "bool-output", cfg.BoolOutput, "how to write -bool-columns: words (true and false) or digits (1 and 0)")
+	fs.StringVar(&cfg.BoolInvalid, "bool-invalid", cfg.BoolInvalid, "what to do with a -bool-columns value that isn't a token: error (reject the row) or passthrough (leave it as it is)")
+	fs.Var((*commaList)(&cfg.HashColumns), "hash-columns", "comma separated `columns` (Address, ZIP, FullName, Notes or derived ones) to replace with a keyed hash of their value, the same for the same value every run")
+	fs.StringVar(&cfg.HashKey, "hash-key", cfg.HashKey, "secret `key` for -hash-columns (default $"+hashKeyEnv+")")
+	fs.BoolVar(&cfg.AssumeSorted, "assume-sorted", cfg.AssumeSorted, "the input is in timestamp order, so -on-duplicate-timestamp only remembers the latest timestamp and keep-last writes as it goes; a row out of order is an error")
+	fs.BoolVar(&cfg.NoVerifySorted, "no-verify-sorted", cfg.NoVerifySorted, "with -assume-sorted, don't check the order, and quietly miss duplicates that aren't next to each other")
+	fs.BoolVar(&cfg.JSONStrings, "json-strings", cfg.JSONStrings, "with json or ndjson output, write the durations as strings too, so every field is a string")
+	fs.BoolVar(&cfg.JSONPretty, "json-pretty", cfg.JSONPretty, "with -output-format json, indent the output for people to read")
+}
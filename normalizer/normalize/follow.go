@@ -0,0 +1,127 @@
+package normalize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// followPoll is how long -follow waits at the end of an input before looking
+// for more
+const followPoll = 250 * time.Millisecond
+
+// followChunk is how much the idleReader asks its input for at a time
+const followChunk = 32 * 1024
+
+// readResult is one Read the idleReader's goroutine did
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// idleReader is for inputs that don't end when the data does: a FIFO whose
+// writer keeps it open, or with follow, a file something is still appending
+// to, which it keeps reading past the end of like tail -f. The reads happen
+// on their own goroutine, so if nothing turns up for timeout it can give up
+// waiting and call it the end of the input. A zero timeout waits forever.
+// Past the deadline, if there is one, Read gives up with ErrTimeLimit instead.
+// Close stops the goroutine once the run's done with the input
+type idleReader struct {
+	name     string
+	timeout  time.Duration
+	deadline time.Time
+	results  chan readResult
+	done     chan struct{}
+	pending  []byte
+	err      error
+}
+
+func newIdleReader(name string, r io.Reader, follow bool, timeout time.Duration) *idleReader {
+	// Room for one result, so once we've stopped listening the goroutine
+	// can still hand over the last one and finish
+	i := &idleReader{name: name, timeout: timeout, results: make(chan readResult, 1), done: make(chan struct{})}
+	go func() {
+		send := func(result readResult) bool {
+			select {
+			case i.results <- result:
+				return true
+			case <-i.done:
+				return false
+			}
+		}
+		for {
+			buf := make([]byte, followChunk)
+			n, err := r.Read(buf)
+			if err == io.EOF && follow {
+				if n > 0 && !send(readResult{buf[:n], nil}) {
+					return
+				}
+				select {
+				case <-time.After(followPoll):
+				case <-i.done:
+					return
+				}
+				continue
+			}
+			if !send(readResult{buf[:n], err}) || err != nil {
+				return
+			}
+		}
+	}()
+	return i
+}
+
+// Close tells the goroutine to stop. One that's in the middle of a Read we
+// can't interrupt still has to wait for it to come back, but then it stops
+// rather than reading on
+func (i *idleReader) Close() error {
+	select {
+	case <-i.done:
+	default:
+		close(i.done)
+	}
+	return nil
+}
+
+func (i *idleReader) Read(p []byte) (int, error) {
+	for len(i.pending) == 0 {
+		if i.err != nil {
+			return 0, i.err
+		}
+		var idle, late <-chan time.Time
+		var timer, lateTimer *time.Timer
+		if i.timeout > 0 {
+			timer = time.NewTimer(i.timeout)
+			idle = timer.C
+		}
+		if !i.deadline.IsZero() {
+			lateTimer = time.NewTimer(time.Until(i.deadline))
+			late = lateTimer.C
+		}
+		select {
+		case result := <-i.results:
+			i.pending, i.err = result.data, result.err
+		case <-late:
+			i.err = ErrTimeLimit
+		case <-idle:
+			// The goroutine's stuck in a Read we can't interrupt, but we're
+			// finished with the input anyway
+			name := i.name
+			if name == "" {
+				name = "stdin"
+			}
+			fmt.Fprintf(os.Stderr, "%s: nothing new for %s, finishing up\n", name, i.timeout)
+			i.err = io.EOF
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if lateTimer != nil {
+			lateTimer.Stop()
+		}
+	}
+	n := copy(p, i.pending)
+	i.pending = i.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,143 @@
+package normalize
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIdleReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		r       func() (io.Reader, func())
+		timeout time.Duration
+		want    string
+		wantErr error
+	}{
+		{"ends normally", func() (io.Reader, func()) {
+			return strings.NewReader("all of it"), func() {}
+		}, time.Second, "all of it", nil},
+		{"goes quiet", func() (io.Reader, func()) {
+			stalled := stalledReader{make(chan struct{})}
+			return io.MultiReader(strings.NewReader("some"), stalled), func() { close(stalled.unblock) }
+		}, 50 * time.Millisecond, "some", nil},
+		{"fails", func() (io.Reader, func()) {
+			return io.MultiReader(strings.NewReader("part"), &failingReader{}), func() {}
+		}, time.Second, "part", errFailingRead},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, cleanup := tt.r()
+			defer cleanup()
+			got, err := ioutil.ReadAll(newIdleReader("test", r, false, tt.timeout))
+			if string(got) != tt.want || !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %q, %v, want %q, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errFailingRead is what a failingReader's Read fails with
+var errFailingRead = errors.New("read failed")
+
+type failingReader struct{}
+
+func (*failingReader) Read(p []byte) (int, error) {
+	return 0, errFailingRead
+}
+
+func TestIdleTimeoutFlag(t *testing.T) {
+	stalled := stalledReader{make(chan struct{})}
+	defer close(stalled.unblock)
+	cfg := testConfig(t, "-idle-timeout", "100ms")
+	in := io.MultiReader(strings.NewReader(testHeader+testRow), stalled)
+
+	done := make(chan []string, 1)
+	go func() {
+		var out strings.Builder
+		if _, err := transformInputs(cfg, []input{{r: in}}, &out, nil); err != nil {
+			t.Error(err)
+		}
+		done <- strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	}()
+	select {
+	case lines := <-done:
+		if len(lines) != 2 {
+			t.Errorf("got %q, want the header and the row", lines)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("still waiting on the input long after -idle-timeout")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "growing.csv")
+	if err := ioutil.WriteFile(path, []byte(testHeader+testRow), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	goroutines := runtime.NumGoroutine()
+	// A row added after we've hit the end still gets read
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		appending, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		appending.WriteString(strings.Replace(testRow, "notes", "later", 1))
+		appending.Close()
+	}()
+
+	cfg := testConfig(t, "-follow", "-idle-timeout", "1s")
+	var out strings.Builder
+	if _, err := transformInputs(cfg, []input{{name: path, r: f}}, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); strings.Count(got, "\n") != 3 || !strings.HasSuffix(got, ",later\n") {
+		t.Errorf("got\n%s\nwant the appended row too", got)
+	}
+
+	// The reading goroutine stops with the run, rather than polling the
+	// file forever. It might be part way through a poll, so give it one
+	waitForGoroutines(t, goroutines)
+}
+
+// waitForGoroutines fails the test if the number of goroutines doesn't get
+// back down to want within a few -follow polls
+func waitForGoroutines(t *testing.T, want int) {
+	t.Helper()
+	deadline := time.Now().Add(4 * followPoll)
+	for runtime.NumGoroutine() > want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines left running, want %d", runtime.NumGoroutine(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestFollowFlags(t *testing.T) {
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-idle-timeout", "-1s"}, "can't be negative"},
+		{[]string{"-follow", "-input", "a.csv", "-input", "b.csv"}, "only works with one -input"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package normalize
+
+import (
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// checksumWriter passes writes through to w, and once started also feeds them
+// to a CRC32 so we can describe exactly what we wrote in the footer
+type checksumWriter struct {
+	w       io.Writer
+	crc     hash.Hash32
+	started bool
+}
+
+func newChecksumWriter(w io.Writer) *checksumWriter {
+	return &checksumWriter{w: w, crc: crc32.NewIEEE()}
+}
+
+func (c *checksumWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	if c.started {
+		c.crc.Write(p[:n])
+	}
+	return n, err
+}
+
+// writeFooter writes the -footer line. It starts with # so readers that know
+// about comments can skip it, e.g. encoding/csv with Comment = '#'
+func writeFooter(w io.Writer, rows int, sum uint32) error {
+	_, err := fmt.Fprintf(w, "# rows=%d crc32=%08x\n", rows, sum)
+	return err
+}
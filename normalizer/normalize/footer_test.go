@@ -0,0 +1,55 @@
+package normalize
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestFooter(t *testing.T) {
+	bad := strings.Replace(testRow, "1:23:32.123", "soon", 1)
+	tests := []struct {
+		name string
+		in   string
+		rows int
+	}{
+		{"no rows", testHeader, 0},
+		{"rows", testHeader + testRow + testRow, 2},
+		{"rejected rows aren't counted", testHeader + testRow + bad + testRow, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := runTest(t, testConfig(t, "-footer"), tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			footerAt := strings.LastIndex(strings.TrimSuffix(out, "\n"), "\n") + 1
+			data := out[len(testHeader):footerAt]
+			want := fmt.Sprintf("# rows=%d crc32=%08x\n", tt.rows, crc32.ChecksumIEEE([]byte(data)))
+			if got := out[footerAt:]; got != want {
+				t.Errorf("footer = %q, want %q", got, want)
+			}
+			if got := strings.Count(data, "\n"); got != tt.rows {
+				t.Errorf("got %d data rows, want %d", got, tt.rows)
+			}
+		})
+	}
+}
+
+func TestFooterChecksumMatchesWithoutFooter(t *testing.T) {
+	// The sum is of exactly the rows we'd have written anyway
+	in := testHeader + testRow + strings.Replace(testRow, "notes", "more notes", 1)
+	plain, err := runTest(t, testConfig(t), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withFooter, err := runTest(t, testConfig(t, "-footer"), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := plain + fmt.Sprintf("# rows=2 crc32=%08x\n", crc32.ChecksumIEEE([]byte(plain[len(testHeader):])))
+	if withFooter != want {
+		t.Errorf("got\n%s\nwant\n%s", withFooter, want)
+	}
+}
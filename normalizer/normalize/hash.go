@@ -0,0 +1,84 @@
+package normalize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// hashKeyEnv is where -hash-key is read from when it isn't given, so the key
+// needn't show up in ps or shell history
+const hashKeyEnv = "NORMALIZER_HASH_KEY"
+
+// hashLength is how many hex digits of the HMAC -hash-columns keeps. 64 bits
+// is plenty to keep different values apart at any size of feed we'd see
+const hashLength = 16
+
+// hashValue is s's -hash-columns token: the start of its HMAC-SHA256 under
+// key, in hex. The same value and key always give the same token, so hashed
+// columns still join. Empty stays empty
+func hashValue(s string, key []byte) string {
+	if s == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// hashableField is the canonical column called name, if it's one
+// -hash-columns can hash. Timestamp and the durations have to stay what they
+// are, since later steps and the JSON output rely on it
+func (r *Record) hashableField(name string) *string {
+	switch columnIndex(name) {
+	case columnIndex("Address"):
+		return &r.Address
+	case columnIndex("ZIP"):
+		return &r.Zip
+	case columnIndex("FullName"):
+		return &r.FullName
+	case columnIndex("Notes"):
+		return &r.Notes
+	}
+	return nil
+}
+
+// checkHashColumns makes sure -hash-columns only names columns that can hold
+// a token: the text ones, ZIP, or a derived column
+func checkHashColumns(c *Config) error {
+	var r Record
+	for _, name := range c.HashColumns {
+		switch {
+		case r.hashableField(name) != nil:
+		case columnIndex(name) >= 0:
+			return fmt.Errorf("-hash-columns: can't hash %s", canonicalHeaders[columnIndex(name)])
+		case !isExtraColumn(c, name) || name == annotateErrorsColumn:
+			return fmt.Errorf("-hash-columns: unknown column %q", name)
+		}
+	}
+	return nil
+}
+
+func isExtraColumn(c *Config, name string) bool {
+	for _, extra := range c.ExtraColumns() {
+		if extra == name {
+			return true
+		}
+	}
+	return false
+}
+
+// hashColumns replaces each of the -hash-columns with its token. It's the last
+// thing Normalize does, so the derived columns are worked out from the real
+// values first
+func (r *Record) hashColumns(cfg *Config) {
+	key := []byte(cfg.HashKey)
+	for _, name := range cfg.HashColumns {
+		if field := r.hashableField(name); field != nil {
+			*field = hashValue(*field, key)
+		} else {
+			r.setExtra(name, hashValue(r.Extra[name], key))
+		}
+	}
+}
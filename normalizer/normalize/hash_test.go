@@ -0,0 +1,77 @@
+package normalize
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestHashValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		key  string
+		want string
+	}{
+		{"MONKEY ALBERTO", "secret", "0563630d37449c12"},
+		{"notes", "secret", "783a82811ad3b820"},
+		{"", "secret", ""},
+	}
+	for _, tt := range tests {
+		if got := hashValue(tt.in, []byte(tt.key)); got != tt.want {
+			t.Errorf("hashValue(%q, %q) = %s, want %s", tt.in, tt.key, got, tt.want)
+		}
+	}
+	if hashValue("notes", []byte("secret")) == hashValue("notes", []byte("other")) {
+		t.Error("different keys gave the same token")
+	}
+}
+
+func TestHashColumnsFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"name and notes", []string{"-hash-columns", "FullName,Notes"},
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,0563630d37449c12,5012.123000,5553.123000,10565.246000,783a82811ad3b820"},
+		// Derived columns are worked out from the real name first
+		{"derived", []string{"-split-name", "-hash-columns", "FirstName"},
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes,8e37b768ef43c9fa,ALBERTO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-hash-key", "secret"}, tt.args...)
+			got := outputLines(t, testConfig(t, args...), testHeader+testRow)
+			if got[len(got)-1] != tt.want {
+				t.Errorf("got %q, want %q", got[len(got)-1], tt.want)
+			}
+		})
+	}
+
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-hash-columns", "FullName"}, "needs a key"},
+		{[]string{"-hash-columns", "Timestamp", "-hash-key", "k"}, "can't hash Timestamp"},
+		{[]string{"-hash-columns", "Nope", "-hash-key", "k"}, "unknown column"},
+		{[]string{"-hash-columns", "FirstName", "-hash-key", "k"}, "unknown column"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
+
+func TestHashKeyEnv(t *testing.T) {
+	os.Setenv(hashKeyEnv, "secret")
+	defer os.Unsetenv(hashKeyEnv)
+	stdout, stderr, status := runMain(t, testHeader+testRow, "-hash-columns", "Notes")
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if !strings.HasSuffix(stdout, ",783a82811ad3b820\n") {
+		t.Errorf("got %q, want Notes hashed with the key from $%s", stdout, hashKeyEnv)
+	}
+}
@@ -0,0 +1,344 @@
+package normalize
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values for -input-format
+const (
+	inputFormatCSV   = "csv"
+	inputFormatFixed = "fixed"
+)
+
+// Values for -header-mismatch-policy
+const (
+	headerMismatchError    = "error"
+	headerMismatchSkipFile = "skip-file"
+	headerMismatchRemap    = "remap"
+)
+
+// delimiterAuto is the -delimiter that has openMapped work the delimiter out
+// from each input's first line, with sniffDelimiter
+const delimiterAuto rune = 0
+
+// The delimiters -delimiter auto chooses between, comma first since that's
+// what it falls back to
+var delimiterCandidates = []rune{',', '\t', ';', '|'}
+
+// sniffDelimiter looks at the first line of in, without reading past it, and
+// picks the candidate delimiter that splits it into the number of columns we
+// need: exactly want of them, or with atLeast, want or more, since a header
+// can have columns we ignore. If no candidate does, or more than one does,
+// it's a comma
+func sniffDelimiter(in *bufio.Reader, want int, atLeast bool) rune {
+	// Peeking a byte at a time only waits for more input when we need it,
+	// so a line typed in at -interactive isn't held up
+	var line []byte
+	for n := 1; n <= in.Size(); n++ {
+		peeked, err := in.Peek(n)
+		line = peeked
+		if err != nil || peeked[n-1] == '\n' {
+			break
+		}
+	}
+
+	found, matches := ',', 0
+	for _, candidate := range delimiterCandidates {
+		reader := csv.NewReader(strings.NewReader(string(line)))
+		reader.Comma = candidate
+		reader.LazyQuotes = true
+		fields, err := reader.Read()
+		if err != nil {
+			continue
+		}
+		if len(fields) == want || (atLeast && len(fields) > want) {
+			found = candidate
+			matches++
+		}
+	}
+	if matches != 1 {
+		return ','
+	}
+	return found
+}
+
+// inputList is the flag.Value behind -input, which can be repeated
+type inputList []string
+
+func (l *inputList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *inputList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// openInputPath opens an -input, which is a file or an http:// or https://
+// URL. A URL is streamed straight from the response rather than downloaded
+// first
+func openInputPath(path string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		return os.Open(path)
+	}
+	resp, err := http.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("fetching %s: %s", path, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// rowReader is where transform gets its rows from, whatever the input format
+type rowReader interface {
+	// Read returns the next row's fields, or io.EOF at the end
+	Read() ([]string, error)
+	// Line is the 1-based input line the row Read just returned started on
+	Line() int
+}
+
+// openInput sets up the rowReader for cfg.InputFormat and works out the
+// input's column names, reading the header if there is one. If known isn't
+// nil the input has no header and those are its columns, as with
+// -columns-from-first-file
+func openInput(cfg *Config, in io.Reader, known []string, delimiter rune) (rowReader, []string, error) {
+	if cfg.InputFormat == inputFormatFixed {
+		// The spec names the columns, so there's never a header line
+		return newFixedRows(in, cfg.FixedSpec, cfg.FixedTrim), cfg.FixedSpec.names(), nil
+	}
+
+	// I'm using Go's CSV package, which is part of its standard library.
+	if cfg.QuoteChar != '"' {
+		in = newQuoteTranslator(in, cfg.QuoteChar)
+	}
+	reader := csv.NewReader(in)
+	reader.Comma = delimiter
+	// The translator can leave plain " in unquoted fields, see quote.go
+	reader.LazyQuotes = cfg.QuoteChar != '"'
+	// Unless I missed it, we expect the number of fields to be consistent
+	// for each row. fitRow holds every row to the header's field count, so a
+	// row that's the wrong width is rejected like any other bad row rather
+	// than ending the run, and the reader lets them all through.
+	reader.FieldsPerRecord = -1
+
+	// Consume the first line, which contains the headers. Vendors don't agree
+	// on column order or casing, so we use it to work out where each column is
+	// and always write out the canonical names instead. Headerless feeds tell
+	// us the column order with -columns, or we assume canonical order
+	headers := canonicalHeaders
+	// With -auto-no-header, the row that turned out not to be a header
+	var first []string
+	if known != nil {
+		headers = known
+	} else if cfg.NoHeader {
+		if len(cfg.Columns) > 0 {
+			headers = cfg.Columns
+		}
+	} else {
+		var err error
+		headers, err = reader.Read()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unexpected error reading csv header: %w", err)
+		}
+		if columns, ok := headerIsData(headers, cfg); ok {
+			if !cfg.AutoNoHeader {
+				fmt.Fprintln(os.Stderr, "the header looks like a row of data; if the input doesn't have one, use -no-header or -auto-no-header")
+			} else {
+				fmt.Fprintln(os.Stderr, "the header looks like a row of data, reading it as one")
+				first, headers = headers, columns
+			}
+		}
+	}
+	return &csvRows{reader: reader, first: first}, headers, nil
+}
+
+// csvRows is the rowReader for CSV input. encoding/csv doesn't count records
+// for us, but FieldPos does know the physical line each field started on, so
+// a record with line breaks in its quoted fields reports the line it starts
+// on, and blank lines the reader skipped are still counted. With
+// -quote-char the translator in front of the reader never adds or removes
+// line breaks, so the numbers still match the file
+type csvRows struct {
+	reader *csv.Reader
+	// A row -auto-no-header found where the header should be, which Read
+	// gives back before any others
+	first []string
+	// Whether the row Read last returned was first
+	onFirst bool
+}
+
+func (c *csvRows) Read() ([]string, error) {
+	if c.first != nil {
+		fields := c.first
+		c.first, c.onFirst = nil, true
+		return fields, nil
+	}
+	c.onFirst = false
+	return c.reader.Read()
+}
+
+func (c *csvRows) Line() int {
+	if c.onFirst {
+		return 1
+	}
+	line, _ := c.reader.FieldPos(0)
+	return line
+}
+
+// headerIsData is whether a header that doesn't name the columns we need
+// looks like it's really the first row of a headerless input: it has the
+// right number of fields, and the Timestamp and durations are where -columns,
+// or else the canonical order, would put them, and they parse. If so it also
+// returns the columns the input must have. It's only a guess, so
+// -timestamp-columns and other ways of building the header turn it off
+func headerIsData(headers []string, cfg *Config) ([]string, bool) {
+	if len(cfg.TimestampColumns) > 0 {
+		return nil, false
+	}
+	if _, err := mapHeaders(headers, cfg.CaseInsensitiveHeaders); err == nil {
+		return nil, false
+	}
+	columns := canonicalHeaders
+	if len(cfg.Columns) > 0 {
+		columns = cfg.Columns
+	}
+	mapping, err := mapHeaders(columns, cfg.CaseInsensitiveHeaders)
+	if err != nil || len(headers) != len(columns) {
+		return nil, false
+	}
+	record := newRecord(headers, mapping)
+	if _, err := parseTimestamp(record.Timestamp, cfg); err != nil {
+		return nil, false
+	}
+	for _, value := range []string{record.FooDuration, record.BarDuration} {
+		if _, err := parseInputDuration(value, cfg); err != nil {
+			return nil, false
+		}
+	}
+	return columns, true
+}
+
+// FixedColumn is one column of a -fixed-spec: Length characters starting at
+// the 1-based character position Start
+type FixedColumn struct {
+	Name   string
+	Start  int
+	Length int
+}
+
+// FixedSpec describes how to slice fixed width lines into fields
+type FixedSpec []FixedColumn
+
+func (s FixedSpec) names() []string {
+	names := make([]string, len(s))
+	for i, c := range s {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func (s *FixedSpec) String() string {
+	var cols []string
+	for _, c := range *s {
+		cols = append(cols, fmt.Sprintf("%s:%d:%d", c.Name, c.Start, c.Length))
+	}
+	return strings.Join(cols, ",")
+}
+
+// Set parses name:start:len,name:start:len,...
+func (s *FixedSpec) Set(value string) error {
+	var spec FixedSpec
+	for _, col := range strings.Split(value, ",") {
+		parts := strings.Split(col, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("expected name:start:length, got %q", col)
+		}
+		start, err := strconv.Atoi(parts[1])
+		if err != nil || start < 1 {
+			return fmt.Errorf("bad start in %q, positions start at 1", col)
+		}
+		length, err := strconv.Atoi(parts[2])
+		if err != nil || length < 1 {
+			return fmt.Errorf("bad length in %q", col)
+		}
+		spec = append(spec, FixedColumn{Name: parts[0], Start: start, Length: length})
+	}
+	*s = spec
+	return nil
+}
+
+// maxFixedLine is the longest line fixedRows will read. bufio.Scanner stops
+// at 64KB by default, which a wide enough spec could get near
+const maxFixedLine = 16 << 20
+
+// fixedRows reads fixed width lines. Positions are in characters rather than
+// bytes, so a name with an accent in it doesn't push every later column over
+type fixedRows struct {
+	scanner *bufio.Scanner
+	spec    FixedSpec
+	trim    bool
+	line    int
+}
+
+func newFixedRows(r io.Reader, spec FixedSpec, trim bool) *fixedRows {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxFixedLine)
+	return &fixedRows{scanner: scanner, spec: spec, trim: trim}
+}
+
+func (f *fixedRows) Read() ([]string, error) {
+	for f.scanner.Scan() {
+		f.line++
+		text := strings.TrimSuffix(f.scanner.Text(), "\r")
+		if text == "" {
+			continue
+		}
+		fields := make([]string, len(f.spec))
+		for i, c := range f.spec {
+			// Sliced out of the line as it is, so invalid UTF-8 gets to
+			// -invalid-utf8 the same as in a csv. Lines that stop short just
+			// give empty or partial fields
+			start := charOffset(text, 0, c.Start-1)
+			end := charOffset(text, start, c.Length)
+			fields[i] = text[start:end]
+			if f.trim {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		}
+		return fields, nil
+	}
+	if err := f.scanner.Err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return nil, fmt.Errorf("line %d is longer than %d bytes: %w", f.line+1, maxFixedLine, err)
+		}
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// charOffset is the byte offset n characters on from from in s, or the end
+// of s if it runs out first. An invalid byte counts as one character, like
+// the U+FFFD it'll be repaired to
+func charOffset(s string, from, n int) int {
+	i := from
+	for ; n > 0 && i < len(s); n-- {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return i
+}
+
+func (f *fixedRows) Line() int {
+	return f.line
+}
@@ -0,0 +1,407 @@
+package normalize
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFixedSpecSet(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"ZIP:1:5", "ZIP:1:5", false},
+		{"A:1:3,B:4:10", "A:1:3,B:4:10", false},
+		{"ZIP:1", "", true},
+		{"ZIP:0:5", "", true},
+		{"ZIP:x:5", "", true},
+		{"ZIP:1:0", "", true},
+		{"A:1:3,B:4", "", true},
+	}
+	for _, tt := range tests {
+		var spec FixedSpec
+		err := spec.Set(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %s, want an error", tt.in, spec.String())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		} else if got := spec.String(); got != tt.want {
+			t.Errorf("%q: got %s", tt.in, got)
+		}
+	}
+}
+
+func TestFixedRows(t *testing.T) {
+	spec := FixedSpec{{"A", 1, 3}, {"B", 4, 4}}
+	tests := []struct {
+		name  string
+		in    string
+		trim  bool
+		want  []string
+		lines []int
+	}{
+		{"trimmed", "ab cd  \n", true, []string{"ab|cd"}, []int{1}},
+		{"untrimmed", "ab cd  \n", false, []string{"ab |cd  "}, []int{1}},
+		{"characters, not bytes", "éé xyz\n", true, []string{"éé|xyz"}, []int{1}},
+		{"short line", "abcde\nx\n", true, []string{"abc|de", "x|"}, []int{1, 2}},
+		{"blank lines", "\nabc\r\n\n123\n", true, []string{"abc|", "123|"}, []int{2, 4}},
+		// Left for -invalid-utf8, one character a byte
+		{"invalid bytes", "\xffb cd\xe9\n", true, []string{"\xffb|cd\xe9"}, []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := newFixedRows(strings.NewReader(tt.in), spec, tt.trim)
+			var got []string
+			var lines []int
+			for {
+				fields, err := rows.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, strings.Join(fields, "|"))
+				lines = append(lines, rows.Line())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			for i := range tt.lines {
+				if i >= len(lines) || lines[i] != tt.lines[i] {
+					t.Errorf("got lines %v, want %v", lines, tt.lines)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFixedInput(t *testing.T) {
+	spec := "Timestamp:1:18,Address:19:10,ZIP:29:5,FullName:34:14,FooDuration:48:11,BarDuration:59:11,TotalDuration:70:8,Notes:78:10"
+	line := "4/1/11 11:00:00 AM123 4th St94121Monkey Alberto1:23:32.1231:32:33.123zzsasdfanotes\n"
+	records := outputRecords(t, testConfig(t, "-input-format", "fixed", "-fixed-spec", spec), line)
+	want := "2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes"
+	if len(records) != 2 || strings.Join(records[0], ",")+"\n" != testHeader || strings.Join(records[1], ",") != want {
+		t.Errorf("got %q", records)
+	}
+
+	_, err := runTest(t, testConfig(t, "-input-format", "fixed", "-fixed-spec", "ZIP:1:5"), line)
+	if err == nil || !strings.Contains(err.Error(), "missing column") {
+		t.Errorf("a spec missing columns: got %v", err)
+	}
+}
+
+func TestFixedInputInvalidUTF8(t *testing.T) {
+	spec := []string{"-input-format", "fixed", "-fixed-spec", "Timestamp:1:18,Address:19:10,ZIP:29:5,FullName:34:14,FooDuration:48:11,BarDuration:59:11,TotalDuration:70:8,Notes:78:10"}
+	line := "4/1/11 11:00:00 AM123 4th St94121Monkey Alberto1:23:32.1231:32:33.123zzsasdfacaf\xe9\n"
+	tests := []struct {
+		name    string
+		args    []string
+		notes   string
+		entries []ReportEntry
+		err     string
+	}{
+		{"repaired", nil, "caf\ufffd", nil, ""},
+		{"rejected", []string{"-invalid-utf8", "reject"}, "", []ReportEntry{{Line: 1, Type: "utf8", Field: "Notes", RawValue: `caf\xe9`}}, ""},
+		{"too many repairs", []string{"-max-utf8-replacement-rate", "0.01"}, "", nil, "-max-utf8-replacement-rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append(append([]string{}, spec...), tt.args...)
+			out, err := runTest(t, testConfig(t, args...), line)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.notes != "" && !strings.HasSuffix(out, ","+tt.notes+"\n") {
+				t.Errorf("got %q, want Notes %q", out, tt.notes)
+			}
+			entries := readErrorReport(t, line, args...)
+			if len(entries) != len(tt.entries) {
+				t.Fatalf("got %+v, want %+v", entries, tt.entries)
+			}
+			for i, e := range entries {
+				want := tt.entries[i]
+				if e.Line != want.Line || e.Type != want.Type || e.Field != want.Field || e.RawValue != want.RawValue {
+					t.Errorf("got %+v, want %+v", e, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFixedRowsLongLine(t *testing.T) {
+	spec := FixedSpec{{"A", 1, 3}}
+	tests := []struct {
+		name    string
+		length  int
+		wantErr bool
+	}{
+		{"past the scanner's default", 100 << 10, false},
+		{"too long", maxFixedLine + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := newFixedRows(strings.NewReader("abc\n"+strings.Repeat("x", tt.length)+"\n"), spec, false)
+			if _, err := rows.Read(); err != nil {
+				t.Fatal(err)
+			}
+			fields, err := rows.Read()
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "line 2") {
+					t.Errorf("got error %v, want one naming line 2", err)
+				}
+				return
+			}
+			if err != nil || fields[0] != "xxx" {
+				t.Errorf("got %q, %v", fields, err)
+			}
+		})
+	}
+}
+
+func TestCSVRowsLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		in    string
+		lines []int
+	}{
+		{"plain", "a,b\nc,d\ne,f\n", []int{1, 2, 3}},
+		{"quoted line breaks", "a,\"b\nb\nb\"\nc,d\n\"e\r\ne\",f\n", []int{1, 4, 5}},
+		{"blank lines", "a,b\n\n\nc,d\n", []int{1, 4}},
+		{"no newline at the end", "a,b\nc,d", []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := &csvRows{reader: csv.NewReader(strings.NewReader(tt.in))}
+			var lines []int
+			for {
+				_, err := rows.Read()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines = append(lines, rows.Line())
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(tt.lines) {
+				t.Errorf("got lines %v, want %v", lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestErrorReportLineAfterMultilineNotes(t *testing.T) {
+	multiline := strings.Replace(testRow, "notes", "\"one\ntwo\nthree\"", 1)
+	bad := strings.Replace(testRow, "1:23:32.123", "soon", 1)
+	entries := readErrorReport(t, testHeader+multiline+"\n"+bad)
+	if len(entries) != 1 || entries[0].Line != 6 {
+		t.Errorf("got %+v, want the bad row on line 6", entries)
+	}
+}
+
+func TestOpenInputPathURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/feed.csv":
+			io.WriteString(w, testHeader+testRow)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		url  string
+		err  string
+	}{
+		{"found", server.URL + "/feed.csv", ""},
+		{"not found", server.URL + "/missing.csv", "404 Not Found"},
+		{"no server", "http://127.0.0.1:1/feed.csv", "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := openInputPath(tt.url)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got %v, want an error like %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer body.Close()
+			records := outputRecords(t, testConfig(t), readAll(t, body))
+			if len(records) != 2 || records[1][2] != "94121" {
+				t.Errorf("got %v", records)
+			}
+		})
+	}
+}
+
+func TestURLInput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, testHeader+testRow)
+	}))
+	defer server.Close()
+	stdout, stderr, status := runMain(t, "", "-input", server.URL+"/feed.csv")
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if !strings.HasPrefix(stdout, testHeader+"2011-04-01T14:00:00-04:00,") {
+		t.Errorf("got %q", stdout)
+	}
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+	t.Helper()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    int
+		atLeast bool
+		delim   rune
+	}{
+		{"comma", "a,b,c\nx;y;z\n", 3, false, ','},
+		{"tab", "a\tb\tc\n", 3, false, '\t'},
+		{"semicolon", "a;b;c", 3, false, ';'},
+		{"pipe", "a|b|c\n", 3, false, '|'},
+		{"quoted comma in tabs", "\"a,b\"\tc\td\n", 3, false, '\t'},
+		{"extra columns", "a|b|c|d|e\n", 3, true, '|'},
+		{"extra columns not allowed", "a|b|c|d|e\n", 3, false, ','},
+		{"nothing fits", "a b c\n", 3, false, ','},
+		{"two fit", "a,b;c,d;e\n", 3, false, ','},
+		{"empty", "", 3, false, ','},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := bufio.NewReader(strings.NewReader(tt.line))
+			if got := sniffDelimiter(in, tt.want, tt.atLeast); got != tt.delim {
+				t.Errorf("got %q, want %q", got, tt.delim)
+			}
+			// It only peeks, so everything's still there to read
+			if rest, _ := ioutil.ReadAll(in); string(rest) != tt.line {
+				t.Errorf("left %q to read, want %q", rest, tt.line)
+			}
+		})
+	}
+}
+
+func TestDelimiterAuto(t *testing.T) {
+	want := "2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes"
+	split := func(s, delim string) string { return strings.Replace(s, ",", delim, -1) }
+	tests := []struct {
+		name string
+		args []string
+		in   string
+	}{
+		{"comma", nil, testHeader + testRow},
+		{"tab", nil, split(testHeader+testRow, "\t")},
+		{"no header", []string{"-no-header"}, split(testRow, ";")},
+		{"extra column", nil, split(strings.Replace(testHeader, "\n", ",Extra\n", 1)+strings.Replace(testRow, "\n", ",x\n", 1), "|")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-delimiter", "auto"}, tt.args...)
+			got := outputLines(t, testConfig(t, args...), tt.in)
+			if got[len(got)-1] != want {
+				t.Errorf("got %q, want %q last", got, want)
+			}
+		})
+	}
+}
+
+func TestHeaderIsData(t *testing.T) {
+	row := strings.Split(strings.TrimSuffix(testRow, "\n"), ",")
+	reordered := []string{row[7], row[0], row[1], row[2], row[3], row[4], row[5], row[6]}
+	// row with field i changed to value
+	with := func(i int, value string) []string {
+		changed := append([]string(nil), row...)
+		changed[i] = value
+		return changed
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		headers []string
+		want    bool
+	}{
+		{"a real header", nil, canonicalHeaders, false},
+		{"a row", nil, row, true},
+		{"too short", nil, row[:7], false},
+		{"bad timestamp", nil, with(0, "When"), false},
+		{"bad duration", nil, with(4, "Foo"), false},
+		{"in -columns order", []string{"-columns", "Notes,Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration"}, reordered, true},
+		{"not in -columns order", []string{"-columns", "Notes,Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration"}, row, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			columns, ok := headerIsData(tt.headers, testConfig(t, tt.args...))
+			if ok != tt.want {
+				t.Fatalf("got %v, want %v", ok, tt.want)
+			}
+			if ok && len(columns) != len(tt.headers) {
+				t.Errorf("got columns %q for %d fields", columns, len(tt.headers))
+			}
+		})
+	}
+}
+
+func TestAutoNoHeader(t *testing.T) {
+	first := strings.Replace(testRow, "94121", "9412x", 1)
+	in := first + testRow
+
+	// Not a header, so without the flag it's the usual missing columns
+	if _, err := runTest(t, testConfig(t), in); err == nil || !strings.Contains(err.Error(), "unusable csv header") {
+		t.Errorf("without -auto-no-header: got error %v, want an unusable header", err)
+	}
+
+	// The first row's read as data, is line 1, and we still write a header
+	cfg := testConfig(t, "-auto-no-header", "-zip-mode", "strip")
+	got := outputLines(t, cfg, in)
+	if len(got) != 2 || got[0] != strings.TrimSuffix(testHeader, "\n") {
+		t.Errorf("got %q, want the header and one good row", got)
+	}
+	entries := readErrorReport(t, in, "-auto-no-header", "-zip-mode", "strip")
+	if len(entries) != 1 || entries[0].Line != 1 {
+		t.Errorf("got %+v, want the first row rejected on line 1", entries)
+	}
+
+	// A real header's left alone
+	if got := outputLines(t, cfg, testHeader+testRow); len(got) != 2 {
+		t.Errorf("got %q for a real header", got)
+	}
+
+	if err := configError(t, "-auto-no-header", "-no-header"); err == nil {
+		t.Error("expected an error for -auto-no-header with -no-header")
+	}
+}
@@ -0,0 +1,52 @@
+package normalize
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// interactive is -interactive: after the header, it normalizes each line as
+// soon as it's read and writes the result straight away, along with what went
+// wrong if it didn't normalize, so you can paste lines in one at a time and
+// see what happens to them. Unlike a normal run, errors go to out and bad
+// lines never stop it
+func interactive(cfg *Config, in io.Reader, out io.Writer) error {
+	opened, err := openMapped(cfg, in, nil)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(out)
+	extra := cfg.ExtraColumns()
+	writer.Write(append(canonicalHeaders, extra...))
+	writer.Flush()
+
+	for {
+		fields, err := opened.rows.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return fmt.Errorf("unexpected error: %w", err)
+		}
+		if err == nil {
+			fields, err = fitRow(fields, opened.width, opened.delimiter, cfg)
+		}
+		var record *Record
+		if err == nil {
+			record = opened.newRecord(fields, cfg)
+			err = record.Normalize(cfg)
+		}
+		if err != nil {
+			fmt.Fprintln(out, "error:", err.Error())
+			continue
+		}
+		writer.Write(record.Row(extra))
+		writer.Flush()
+		if err := writer.Error(); err != nil {
+			return fmt.Errorf("unexpected error writing output: %w", err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package normalize
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInteractive(t *testing.T) {
+	clean := "2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes"
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{"good line", testHeader + testRow, []string{clean}},
+		{
+			"bad lines don't stop it",
+			testHeader + strings.Replace(testRow, "1:23:32.123", "soon", 1) + "a,b\n" + testRow,
+			[]string{
+				`error: bad format for duration in FooDuration: "soon"`,
+				`error: wrong number of fields: expected 8, got 2 in row: "a,b"`,
+				clean,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := interactive(testConfig(t, "-interactive"), strings.NewReader(tt.in), &out); err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			if lines[0]+"\n" != testHeader {
+				t.Errorf("header = %q", lines[0])
+			}
+			if got := strings.Join(lines[1:], "\n"); got != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", got, strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestInteractiveAnswersEachLine(t *testing.T) {
+	// Each line gets its answer before the next one is typed
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- interactive(testConfig(t, "-interactive"), inR, outW)
+		outW.Close()
+	}()
+	answers := bufio.NewReader(outR)
+	readLine := func() string {
+		got := make(chan string, 1)
+		go func() {
+			line, _ := answers.ReadString('\n')
+			got <- line
+		}()
+		select {
+		case line := <-got:
+			return line
+		case <-time.After(2 * time.Second):
+			t.Fatal("no answer while waiting for the next line")
+			return ""
+		}
+	}
+
+	inW.Write([]byte(testHeader))
+	if got := readLine(); got != testHeader {
+		t.Errorf("header = %q", got)
+	}
+	for i := 0; i < 2; i++ {
+		inW.Write([]byte(testRow))
+		if got := readLine(); !strings.HasPrefix(got, "2011-04-01T14:00:00-04:00,") {
+			t.Errorf("got %q", got)
+		}
+	}
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
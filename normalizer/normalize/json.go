@@ -0,0 +1,149 @@
+package normalize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// In JSON a Record is a flat object keyed by the canonical header names.
+// Durations are numbers of seconds, everything else is a string (ZIP in
+// particular, so leading zeros survive). Any Extra columns follow as strings,
+// sorted by name. For example:
+//
+//	{"Timestamp":"2011-04-01T14:00:00-04:00","Address":"123 4th St","ZIP":"00501",
+//	 "FullName":"MONKEY ALBERTO","FooDuration":5012.123,"BarDuration":5553.123,
+//	 "TotalDuration":10565.246,"Notes":"","FooPercent":"47.44"}
+//
+// This only makes sense for a normalized record, so marshalling one whose
+// durations aren't numbers yet is an error
+
+// Which of the canonical columns are numbers in JSON
+var jsonNumberColumns = map[string]bool{
+	"FooDuration":   true,
+	"BarDuration":   true,
+	"TotalDuration": true,
+}
+
+func (r *Record) MarshalJSON() ([]byte, error) {
+	return r.marshalJSON(false)
+}
+
+// marshalJSON is MarshalJSON, optionally writing the durations as strings
+// like everything else, for -json-strings
+func (r *Record) marshalJSON(allStrings bool) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	names := outputHeaders(r.order)
+	for i, value := range r.outputFields() {
+		name := names[i]
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		if err := writeJSONField(&buf, name, value, jsonNumberColumns[name] && !allStrings); err != nil {
+			return nil, err
+		}
+	}
+
+	names = make([]string, 0, len(r.Extra))
+	for name := range r.Extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteByte(',')
+		if err := writeJSONField(&buf, name, r.Extra[name], false); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonStrings marshals a Record with every field a string
+type jsonStrings struct {
+	r *Record
+}
+
+func (s jsonStrings) MarshalJSON() ([]byte, error) {
+	return s.r.marshalJSON(true)
+}
+
+// jsonValue is what the JSON sinks actually encode for r
+func jsonValue(r *Record, allStrings bool) json.Marshaler {
+	if allStrings {
+		return jsonStrings{r}
+	}
+	return r
+}
+
+func writeJSONField(buf *bytes.Buffer, name, value string, number bool) error {
+	key, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	buf.Write(key)
+	buf.WriteByte(':')
+
+	if number {
+		// The value is already formatted the way we want it, so write it
+		// straight through once we know it really is a number
+		// ParseFloat takes NaN and Inf too, which JSON doesn't have
+		if f, err := strconv.ParseFloat(value, 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%s is not a number: %q", name, value)
+		}
+		buf.WriteString(value)
+		return nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(encoded)
+	return nil
+}
+
+// UnmarshalJSON accepts what MarshalJSON produces. Keys that aren't canonical
+// columns end up in Extra
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	values := make([]string, len(canonicalHeaders))
+	for i, name := range canonicalHeaders {
+		msg, ok := raw[name]
+		if !ok {
+			continue
+		}
+		delete(raw, name)
+		if jsonNumberColumns[name] {
+			var n json.Number
+			if err := json.Unmarshal(msg, &n); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			values[i] = n.String()
+		} else if err := json.Unmarshal(msg, &values[i]); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	parsed, err := RecordFromFields(values)
+	if err != nil {
+		return err
+	}
+	for name, msg := range raw {
+		var value string
+		if err := json.Unmarshal(msg, &value); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		parsed.setExtra(name, value)
+	}
+	*r = *parsed
+	return nil
+}
@@ -0,0 +1,210 @@
+package normalize
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// jsonRows decodes json or ndjson output into one map per row
+func jsonRows(t *testing.T, format, out string) []map[string]interface{} {
+	t.Helper()
+	var rows []map[string]interface{}
+	if format == outputFormatJSON {
+		if err := json.Unmarshal([]byte(out), &rows); err != nil {
+			t.Fatalf("%v in %s", err, out)
+		}
+		return rows
+	}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var row map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			t.Fatalf("%v in %s", err, line)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestJSONOutputWithoutDurationNormalization(t *testing.T) {
+	for _, format := range []string{outputFormatJSON, outputFormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			cfg := testConfig(t, "-output-format", format, "-no-normalize-durations")
+			out, err := runTest(t, cfg, testHeader+testRow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := jsonRows(t, format, out)
+			if len(rows) != 1 {
+				t.Fatalf("got %d rows, want 1: %s", len(rows), out)
+			}
+			if got := rows[0]["FooDuration"]; got != "1:23:32.123" {
+				t.Errorf("FooDuration = %#v, want the input as a string", got)
+			}
+			if got := rows[0]["TotalDuration"]; got != "zzsasdfa" {
+				t.Errorf("TotalDuration = %#v, want the input as a string", got)
+			}
+		})
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	for _, format := range []string{outputFormatJSON, outputFormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			out, err := runTest(t, testConfig(t, "-output-format", format), testHeader+testRow+testRow)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := jsonRows(t, format, out)
+			if len(rows) != 2 {
+				t.Fatalf("got %d rows, want 2: %s", len(rows), out)
+			}
+			if got := rows[0]["FooDuration"]; got != 5012.123 {
+				t.Errorf("FooDuration = %#v, want a number", got)
+			}
+			if got := rows[0]["FullName"]; got != "MONKEY ALBERTO" {
+				t.Errorf("FullName = %#v", got)
+			}
+		})
+	}
+}
+
+func TestWriteJSONField(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		number  bool
+		want    string
+		wantErr bool
+	}{
+		{"string", `say "hi"`, false, `"x":"say \"hi\""`, false},
+		{"number", "5012.123000", true, `"x":5012.123000`, false},
+		{"negative number", "-1.5", true, `"x":-1.5`, false},
+		{"not a number", "1:23:32.123", true, "", true},
+		{"NaN", "NaN", true, "", true},
+		{"Inf", "Inf", true, "", true},
+		{"-Infinity", "-Infinity", true, "", true},
+		{"NaN as a string is fine", "NaN", false, `"x":"NaN"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeJSONField(&buf, "x", tt.value, tt.number)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("wrote %s, want an error", buf.String())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got %s, want %s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordJSONRoundTrip(t *testing.T) {
+	cfg := testConfig(t)
+	out, err := runTest(t, testConfig(t, "-output-format", outputFormatNDJSON), testHeader+testRow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r Record
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &r); err != nil {
+		t.Fatal(err)
+	}
+	want := outputLines(t, cfg, testHeader+testRow)[1]
+	if got := strings.Join(r.Fields(), ","); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestJSONPretty(t *testing.T) {
+	in := testHeader + testRow + strings.Replace(testRow, "notes", "more", 1)
+	compact, err := runTest(t, testConfig(t, "-output-format", "json"), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pretty, err := runTest(t, testConfig(t, "-output-format", "json", "-json-pretty"), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same data either way
+	var a, b []map[string]interface{}
+	if err := json.Unmarshal([]byte(compact), &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(pretty), &b); err != nil {
+		t.Fatal(err)
+	}
+	ac, _ := json.Marshal(a)
+	bc, _ := json.Marshal(b)
+	if !bytes.Equal(ac, bc) {
+		t.Errorf("compact and pretty differ:\n%s\n%s", compact, pretty)
+	}
+
+	// Compact is an object a line, pretty a field a line
+	if got := strings.Count(compact, "\n"); got != 4 {
+		t.Errorf("compact output has %d lines, want 4:\n%s", got, compact)
+	}
+	lines := strings.Split(strings.TrimSuffix(pretty, "\n"), "\n")
+	if len(lines) != 2+2*(len(canonicalHeaders)+2) {
+		t.Errorf("pretty output has %d lines:\n%s", len(lines), pretty)
+	}
+	if lines[1] != "  {" || lines[2] != `    "Timestamp": "2011-04-01T14:00:00-04:00",` {
+		t.Errorf("pretty output isn't indented:\n%s", pretty)
+	}
+}
+
+func TestJSONPrettyOnlyForJSON(t *testing.T) {
+	for _, format := range []string{outputFormatCSV, outputFormatNDJSON} {
+		if err := configError(t, "-output-format", format, "-json-pretty"); err == nil {
+			t.Errorf("-json-pretty with %s: got no error", format)
+		}
+	}
+}
+
+func TestJSONFieldTypes(t *testing.T) {
+	in := testHeader + strings.Replace(testRow, "94121", "501", 1)
+	tests := []struct {
+		name   string
+		args   []string
+		number bool
+	}{
+		{"durations are numbers", nil, true},
+		{"-json-strings", []string{"-json-strings"}, false},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{outputFormatJSON, outputFormatNDJSON} {
+			t.Run(tt.name+"/"+format, func(t *testing.T) {
+				out, err := runTest(t, testConfig(t, append(tt.args, "-output-format", format)...), in)
+				if err != nil {
+					t.Fatal(err)
+				}
+				row := jsonRows(t, format, out)[0]
+				// The ZIP keeps its leading zeroes either way
+				if row["ZIP"] != "00501" {
+					t.Errorf("ZIP = %#v", row["ZIP"])
+				}
+				for _, name := range []string{"FooDuration", "BarDuration", "TotalDuration"} {
+					_, isNumber := row[name].(float64)
+					_, isString := row[name].(string)
+					if isNumber != tt.number || isString == tt.number {
+						t.Errorf("%s = %#v", name, row[name])
+					}
+				}
+				if row["TotalDuration"] != 10565.246 && row["TotalDuration"] != "10565.246000" {
+					t.Errorf("TotalDuration = %#v", row["TotalDuration"])
+				}
+			})
+		}
+	}
+	if err := configError(t, "-json-strings"); err == nil {
+		t.Error("-json-strings with csv: got no error")
+	}
+}
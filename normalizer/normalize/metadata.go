@@ -0,0 +1,73 @@
+package normalize
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"time"
+)
+
+// version is stamped in at build time with
+// -ldflags "-X normalizer/normalize.version=...", and is dev otherwise
+var version = "dev"
+
+// redacted stands in for secrets in -run-metadata
+const redacted = "<redacted>"
+
+// runMetadata is what -run-metadata writes: enough to tell how a given
+// output was produced
+type runMetadata struct {
+	Version  string    `json:"version"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Inputs   []string  `json:"inputs"`
+	// Every flag's effective value, after any -profile, keyed by flag name,
+	// with -hash-key redacted
+	Config map[string]string `json:"config"`
+	Rows   runCounts         `json:"rows"`
+	// Why the run stopped early, if it did
+	Error string `json:"error,omitempty"`
+}
+
+func newRunMetadata(fs *flag.FlagSet, inputs []input, started time.Time) *runMetadata {
+	m := &runMetadata{
+		Version: version,
+		Started: started,
+		Config:  make(map[string]string),
+	}
+	for _, in := range inputs {
+		name := in.name
+		if name == "" {
+			name = "stdin"
+		}
+		m.Inputs = append(m.Inputs, name)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		m.Config[f.Name] = f.Value.String()
+	})
+	// Like configHash, leave the key out: it's a secret, and the file isn't
+	if m.Config["hash-key"] != "" {
+		m.Config["hash-key"] = redacted
+	}
+	return m
+}
+
+// writeRunMetadata finishes off m with how the run went and writes it to path
+func writeRunMetadata(path string, m *runMetadata, counts runCounts, runErr error) error {
+	m.Finished = time.Now()
+	m.Rows = counts
+	if runErr != nil {
+		m.Error = runErr.Error()
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
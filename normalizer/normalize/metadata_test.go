@@ -0,0 +1,115 @@
+package normalize
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunMetadataRedactsHashKey(t *testing.T) {
+	const secret = "supersecret"
+	tests := []struct {
+		name string
+		args []string
+		env  bool
+	}{
+		{"from the flag", []string{"-hash-columns", "FullName", "-hash-key", secret}, false},
+		{"from the environment", []string{"-hash-columns", "FullName"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			fs := flag.NewFlagSet("normalizer", flag.ContinueOnError)
+			RegisterFlags(fs, cfg)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if tt.env {
+				// The way Main fills it in
+				cfg.HashKey = secret
+			}
+
+			path := filepath.Join(t.TempDir(), "meta.json")
+			m := newRunMetadata(fs, []input{{r: os.Stdin}}, time.Now())
+			if err := writeRunMetadata(path, m, runCounts{}, nil); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), secret) {
+				t.Errorf("the key is in the metadata:\n%s", data)
+			}
+			var got runMetadata
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Config["hash-key"] != redacted {
+				t.Errorf("hash-key = %q, want %q", got.Config["hash-key"], redacted)
+			}
+		})
+	}
+}
+
+func TestRunMetadataNoHashKey(t *testing.T) {
+	// Nothing to hide, so it says so rather than claiming a key
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("normalizer", flag.ContinueOnError)
+	RegisterFlags(fs, cfg)
+	m := newRunMetadata(fs, nil, time.Now())
+	if got := m.Config["hash-key"]; got != "" {
+		t.Errorf("hash-key = %q, want empty", got)
+	}
+}
+
+func TestRunMetadata(t *testing.T) {
+	bad := strings.Replace(testRow, "1:23:32.123", "soon", 1)
+	in := testHeader + testRow + bad + testRow
+	tests := []struct {
+		name    string
+		args    []string
+		written int
+		err     string
+	}{
+		{"finished", nil, 2, ""},
+		{"stopped early", []string{"-max-rows", "1"}, 1, "hit -max-rows"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "meta.json")
+			_, stderr, _ := runMain(t, in, append(tt.args, "-run-metadata", path, "-zip-format", "us")...)
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v: %s", err, stderr)
+			}
+			var m runMetadata
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if m.Version != version || len(m.Inputs) != 1 || m.Inputs[0] != "stdin" {
+				t.Errorf("version %q, inputs %q", m.Version, m.Inputs)
+			}
+			if m.Finished.Before(m.Started) {
+				t.Errorf("finished %v before it started at %v", m.Finished, m.Started)
+			}
+			if m.Config["zip-format"] != "us" || m.Config["run-metadata"] != path {
+				t.Errorf("config = %v", m.Config)
+			}
+			if m.Rows.Written != tt.written {
+				t.Errorf("written = %d, want %d", m.Rows.Written, tt.written)
+			}
+			if tt.err == "" && (m.Rows.Read != 3 || m.Rows.Rejected != 1 || m.Rows.RejectedByType["duration"] != 1) {
+				t.Errorf("rows = %+v", m.Rows)
+			}
+			if !strings.HasPrefix(m.Error, tt.err) || (tt.err == "") != (m.Error == "") {
+				t.Errorf("error = %q, want %q", m.Error, tt.err)
+			}
+		})
+	}
+}
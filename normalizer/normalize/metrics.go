@@ -0,0 +1,105 @@
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// metricsLine is one line of -metrics-file
+type metricsLine struct {
+	Time           string  `json:"time"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	Rows           int64   `json:"rows"`
+	Errors         int64   `json:"errors"`
+	// Rows per second since the line before
+	Rate float64 `json:"rate"`
+}
+
+// metricsLogger writes a metricsLine to a file every interval, for
+// dashboards to scrape. The row loop only ever bumps the counters, and the
+// writing happens on its own goroutine, so a slow disk can't hold the run up
+type metricsLogger struct {
+	// Only touched with sync/atomic. First in the struct so they're 64-bit
+	// aligned on 32-bit platforms
+	rows   int64
+	errors int64
+
+	f        *os.File
+	started  time.Time
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newMetricsLogger(path string, interval time.Duration) (*metricsLogger, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open -metrics-file: %w", err)
+	}
+	m := &metricsLogger{
+		f:       f,
+		started: time.Now(),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go m.run(interval)
+	return m, nil
+}
+
+// AddRow counts a row read
+func (m *metricsLogger) AddRow() {
+	atomic.AddInt64(&m.rows, 1)
+}
+
+// AddError counts a row rejected
+func (m *metricsLogger) AddError() {
+	atomic.AddInt64(&m.errors, 1)
+}
+
+func (m *metricsLogger) run(interval time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last, lastRows := m.started, int64(0)
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-ticker.C:
+			line := metricsLine{
+				Time:           now.Format(time.RFC3339),
+				ElapsedSeconds: now.Sub(m.started).Seconds(),
+				Rows:           atomic.LoadInt64(&m.rows),
+				Errors:         atomic.LoadInt64(&m.errors),
+			}
+			line.Rate = float64(line.Rows-lastRows) / now.Sub(last).Seconds()
+			last, lastRows = now, line.Rows
+
+			encoded, err := json.Marshal(line)
+			if err == nil {
+				_, err = m.f.Write(append(encoded, '\n'))
+			}
+			if err != nil {
+				// Not worth failing the run over, but no point carrying on
+				fmt.Fprintln(os.Stderr, "unable to write -metrics-file: ", err.Error())
+				return
+			}
+		}
+	}
+}
+
+// Close stops the logger and closes the file. It's fine to call more than once
+func (m *metricsLogger) Close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		<-m.done
+		if err := m.f.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "unable to write -metrics-file: ", err.Error())
+		}
+	})
+}
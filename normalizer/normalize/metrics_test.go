@@ -0,0 +1,91 @@
+package normalize
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"off", nil, ""},
+		{"both", []string{"-metrics-interval", "1s", "-metrics-file", "m.json"}, ""},
+		{"negative", []string{"-metrics-interval", "-1s", "-metrics-file", "m.json"}, "can't be negative"},
+		{"no file", []string{"-metrics-interval", "1s"}, "go together"},
+		{"no interval", []string{"-metrics-file", "m.json"}, "go together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := configError(t, tt.args...)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMetricsLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	m, err := newMetricsLogger(path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		m.AddRow()
+	}
+	m.AddError()
+	time.Sleep(50 * time.Millisecond)
+	m.Close()
+	// Twice is fine
+	m.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var lines []metricsLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line metricsLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		t.Fatal("no lines written")
+	}
+	last := lines[len(lines)-1]
+	if last.Rows != 5 || last.Errors != 1 {
+		t.Errorf("got %+v, want 5 rows and 1 error", last)
+	}
+	if lines[0].Rate <= 0 {
+		t.Errorf("first line rate %v, want it positive", lines[0].Rate)
+	}
+}
+
+func TestMetricsFileWrittenByRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	cfg := testConfig(t, "-metrics-interval", "1h", "-metrics-file", path)
+	outputLines(t, cfg, testHeader+testRow)
+	// An hour never passes, so the file is there but empty
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != 0 {
+		t.Errorf("metrics file is %d bytes, want 0", info.Size())
+	}
+}
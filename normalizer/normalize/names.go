@@ -0,0 +1,98 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Values for -name-case
+const (
+	// Everything uppercase, what the spec asks for
+	nameCaseUpper = "upper"
+	// Title case, but with particles like "van" and "de" kept lowercase
+	nameCaseTitleSmart = "title-smart"
+)
+
+// The particles title-smart keeps lowercase by default
+var defaultNameParticles = []string{"de", "van", "der", "la", "del"}
+
+// caseName applies one of the nameCase* modes to a FullName
+func caseName(name, mode string, particles []string) string {
+	if mode == nameCaseTitleSmart {
+		return titleSmart(name, particles)
+	}
+	return strings.ToUpper(name)
+}
+
+// titleSmart title-cases each word of name, except any particles after the
+// first word, which are lowercased. Casing names properly is hard, and this
+// doesn't try to know about things like McDonald; it capitalizes the start of
+// each part of a hyphenated or apostrophed word (Jean-Luc, O'Brien), so
+// initials like "j." come out as "J."
+func titleSmart(name string, particles []string) string {
+	isParticle := make(map[string]bool, len(particles))
+	for _, p := range particles {
+		isParticle[strings.ToLower(p)] = true
+	}
+
+	words := strings.Fields(name)
+	for i, word := range words {
+		lower := strings.ToLower(word)
+		if i > 0 && isParticle[lower] {
+			words[i] = lower
+			continue
+		}
+		words[i] = titleWord(lower)
+	}
+	return strings.Join(words, " ")
+}
+
+// titleWord uppercases the first letter of word, and any letter following a
+// hyphen or apostrophe. word should already be lowercase
+func titleWord(word string) string {
+	var b strings.Builder
+	upperNext := true
+	for _, r := range word {
+		if upperNext && unicode.IsLetter(r) {
+			b.WriteRune(unicode.ToTitle(r))
+			upperNext = false
+			continue
+		}
+		b.WriteRune(r)
+		if r == '-' || r == '\'' || r == '’' {
+			upperNext = true
+		}
+	}
+	return b.String()
+}
+
+// The words splitName keeps with the last name when they end a name, compared
+// lowercase without dots or commas
+var nameSuffixes = map[string]bool{"jr": true, "sr": true, "ii": true, "iii": true, "iv": true, "v": true, "md": true, "phd": true, "esq": true}
+
+// splitName splits a FullName at its last space, for -split-name: everything
+// before it is the first name, with any middle names or initials, and the
+// last word is the last name. A suffix like Jr or III on the end goes with the
+// word before it, so "john smith jr" is "john" and "smith jr". A single word
+// is all first name. It doesn't know about particles or names written last
+// name first, so "ludwig van beethoven" gets a first name of "ludwig van"
+func splitName(name string) (first, last string) {
+	words := strings.Fields(name)
+	end := len(words) - 1
+	for end > 1 && nameSuffixes[strings.ToLower(strings.Trim(words[end], ".,"))] {
+		end--
+	}
+	if end < 1 {
+		return strings.Join(words, " "), ""
+	}
+	return strings.Join(words[:end], " "), strings.Join(words[end:], " ")
+}
+
+func checkNameCase(mode string) error {
+	switch mode {
+	case nameCaseUpper, nameCaseTitleSmart:
+		return nil
+	}
+	return fmt.Errorf("unknown -name-case %q", mode)
+}
@@ -0,0 +1,116 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCaseName(t *testing.T) {
+	tests := []struct {
+		in        string
+		mode      string
+		particles []string
+		want      string
+	}{
+		{"monkey alberto", nameCaseUpper, nil, "MONKEY ALBERTO"},
+		{"Superman übertan", nameCaseUpper, nil, "SUPERMAN ÜBERTAN"},
+		{"LUDWIG VAN BEETHOVEN", nameCaseTitleSmart, defaultNameParticles, "Ludwig van Beethoven"},
+		{"maria de la cruz", nameCaseTitleSmart, defaultNameParticles, "Maria de la Cruz"},
+		{"van morrison", nameCaseTitleSmart, defaultNameParticles, "Van Morrison"},
+		{"jean-luc o'brien", nameCaseTitleSmart, defaultNameParticles, "Jean-Luc O'Brien"},
+		{"j.  r.  r. tolkien", nameCaseTitleSmart, defaultNameParticles, "J. R. R. Tolkien"},
+		{"omar bin laden", nameCaseTitleSmart, []string{"BIN"}, "Omar bin Laden"},
+		{"ludwig van beethoven", nameCaseTitleSmart, nil, "Ludwig Van Beethoven"},
+		{"élodie du pont", nameCaseTitleSmart, []string{"du"}, "Élodie du Pont"},
+	}
+	for _, tt := range tests {
+		if got := caseName(tt.in, tt.mode, tt.particles); got != tt.want {
+			t.Errorf("caseName(%q, %s, %v) = %q, want %q", tt.in, tt.mode, tt.particles, got, tt.want)
+		}
+	}
+}
+
+func TestNameCaseFlags(t *testing.T) {
+	row := strings.Replace(testRow, "Monkey Alberto", "anne van der berg", 1)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "ANNE VAN DER BERG"},
+		{[]string{"-name-case", "title-smart"}, "Anne van der Berg"},
+		{[]string{"-name-case", "title-smart", "-name-particles", "van"}, "Anne van Der Berg"},
+	}
+	for _, tt := range tests {
+		records := outputRecords(t, testConfig(t, tt.args...), testHeader+row)
+		if got := records[1][3]; got != tt.want {
+			t.Errorf("%v: FullName = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+	if err := configError(t, "-name-case", "lower"); err == nil {
+		t.Error("-name-case lower: got no error")
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	tests := []struct {
+		in    string
+		first string
+		last  string
+	}{
+		{"john q public", "john q", "public"},
+		{"john smith jr.", "john", "smith jr."},
+		{"JOHN SMITH, III", "JOHN", "SMITH, III"},
+		{"jane doe md phd", "jane", "doe md phd"},
+		{"ludwig van beethoven", "ludwig van", "beethoven"},
+		{"cher", "cher", ""},
+		// Two words are a first and last name, even if the last looks like a
+		// suffix
+		{"john jr", "john", "jr"},
+		{"  spaced   out  ", "spaced", "out"},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		first, last := splitName(tt.in)
+		if first != tt.first || last != tt.last {
+			t.Errorf("splitName(%q) = %q, %q, want %q, %q", tt.in, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestSplitNameFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"split", []string{"-split-name"}, []string{
+			"Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes,FirstName,LastName",
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,notes,MONKEY,ALBERTO",
+		}},
+		{"dropped", []string{"-split-name", "-drop-full-name"}, []string{
+			"Timestamp,Address,ZIP,FooDuration,BarDuration,TotalDuration,Notes,FirstName,LastName",
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,5012.123000,5553.123000,10565.246000,notes,MONKEY,ALBERTO",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outputLines(t, testConfig(t, tt.args...), testHeader+testRow)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-drop-full-name"}, "only works with -split-name"},
+		{[]string{"-split-name", "-drop-full-name", "-diff"}, "can't be used with -diff"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package normalize
+
+import "io"
+
+// Normalizer is Transform with settings of your own. The package-level
+// variables are lookup tables that are only ever read, and version, which is
+// set when it's built. Everything else is on the Config: time zones are
+// cached there with a lock, and -output schemes are its Outputs. Whatever
+// else a run needs is made fresh for it. So any number of
+// Normalizers, with the same Config or different ones, can run at once from
+// different goroutines, and so can several Transforms on one Normalizer.
+// Settings that write files, like ErrorReport, still shouldn't point two of
+// them at the same file
+type Normalizer struct {
+	cfg *Config
+}
+
+// NewNormalizer checks cfg, which usually starts out as DefaultConfig(), and
+// returns a Normalizer that uses it. cfg mustn't be changed afterwards
+func NewNormalizer(cfg *Config) (*Normalizer, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+	return &Normalizer{cfg: cfg}, nil
+}
+
+// Transform is the package Transform, with the Normalizer's settings
+func (n *Normalizer) Transform(r io.Reader, w io.Writer, hook func(*Record) error) error {
+	return transform(n.cfg, r, w, hook)
+}
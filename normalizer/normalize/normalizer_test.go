@@ -0,0 +1,86 @@
+package normalize
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNormalizerConcurrentTransforms(t *testing.T) {
+	// A zone per row, so every Transform with -dest-tz-column is in and out of
+	// its zone cache the whole time
+	zones := []string{"Europe/London", "Asia/Tokyo", "America/Chicago", "Australia/Sydney", "UTC"}
+	var in strings.Builder
+	in.WriteString(strings.TrimSuffix(testHeader, "\n") + ",Zone\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&in, "4/1/11 11:%02d:00 AM,123 4th St,%d,Monkey van Alberto,1:23:32.123,1:32:33.123,zzsasdfa,note %d,%s\n", i%60, 94000+i%10, i, zones[i%len(zones)])
+	}
+	allow := writeTestFile(t, "allow.txt", "94000\n94002\n94004\n94006\n94008\n")
+
+	// Every Normalizer gets a different Config, so each one's own state (its
+	// zone cache, allowlist, output writer and the rest) is being used at the
+	// same time as the others'
+	configs := [][]string{
+		{"-dest-tz-column", "Zone", "-add-percent-columns"},
+		{"-dest-tz", "Asia/Tokyo", "-name-case", "title-smart"},
+		{"-dest-tz", "Europe/London", "-output-format", "ndjson"},
+		{"-dest-tz-column", "Zone", "-output-format", "json", "-duration-output", "iso8601"},
+		{"-duration-output", "iso8601", "-name-case", "title-smart"},
+		{"-zip-allowlist", allow, "-dest-tz", "America/Chicago"},
+		{"-zip-allowlist", allow, "-dest-tz-column", "Zone", "-output-format", "ndjson"},
+	}
+	normalizers := make([]*Normalizer, len(configs))
+	wants := make([]string, len(configs))
+	for i, args := range configs {
+		n, err := NewNormalizer(parseTestFlags(t, args...))
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		normalizers[i] = n
+		var want bytes.Buffer
+		if err := n.Transform(strings.NewReader(in.String()), &want, nil); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		wants[i] = strings.ReplaceAll(want.String(), "note ", "NOTE ")
+	}
+
+	// A few Transforms per Normalizer, all at once
+	const perNormalizer = 3
+	outs := make([]bytes.Buffer, len(configs)*perNormalizer)
+	errs := make([]error, len(outs))
+	var wg sync.WaitGroup
+	for i := range outs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			n := normalizers[i/perNormalizer]
+			errs[i] = n.Transform(strings.NewReader(in.String()), &outs[i], func(r *Record) error {
+				// Hooks run on the Transform's own goroutine, and only see
+				// its own records
+				r.Notes = strings.ToUpper(r.Notes)
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range outs {
+		args := configs[i/perNormalizer]
+		if errs[i] != nil {
+			t.Errorf("%v, worker %d: %v", args, i, errs[i])
+			continue
+		}
+		if outs[i].String() != wants[i/perNormalizer] {
+			t.Errorf("%v, worker %d wrote something different from a Transform on its own", args, i)
+		}
+	}
+}
+
+func TestNewNormalizerChecksConfig(t *testing.T) {
+	cfg := parseTestFlags(t, "-dest-tz", "Not/AZone")
+	if _, err := NewNormalizer(cfg); err == nil {
+		t.Error("NewNormalizer took a Config that doesn't check")
+	}
+}
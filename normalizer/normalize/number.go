@@ -0,0 +1,81 @@
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// NumberRule is one -parse-number rule: find the first number in the Source
+// column and put it in a new Column as a plain decimal
+type NumberRule struct {
+	Source string
+	Column string
+}
+
+// numberToken is a number the way people write amounts: maybe a minus sign,
+// maybe a currency symbol (either side of the sign), digits with or without
+// comma thousands separators, and maybe a decimal point. It takes digits and
+// commas however they're grouped, so parseNumber can tell 12,3456 is wrong
+// rather than quietly reading it as 12,345
+var numberToken = regexp.MustCompile(`(-)?[$€£¥]?(-)?([0-9]+(?:,[0-9]+)*)(\.[0-9]+)?`)
+
+// parseNumberRule parses Column->NewColumn
+func parseNumberRule(s string) (NumberRule, error) {
+	arrow := strings.Index(s, "->")
+	if arrow < 1 || arrow+2 == len(s) {
+		return NumberRule{}, fmt.Errorf("expected Column->NewColumn, got %q", s)
+	}
+	source := s[:arrow]
+	if columnIndex(source) < 0 {
+		return NumberRule{}, fmt.Errorf("unknown column %q", source)
+	}
+	return NumberRule{Source: source, Column: s[arrow+2:]}, nil
+}
+
+// parseNumber finds the first number in s and writes it plainly: no currency
+// symbol, no thousands separators and no leading zeroes, with the decimals
+// as they were, so "$1,234.50" is "1234.50". It's ErrNumber if there isn't
+// one, or if its thousands separators aren't every three digits
+func parseNumber(s string) (string, error) {
+	match := numberToken.FindStringSubmatch(s)
+	if match == nil {
+		return "", ErrNumber
+	}
+	if groups := strings.Split(match[3], ","); len(groups) > 1 {
+		for i, group := range groups {
+			if len(group) != 3 && (i > 0 || len(group) > 3) {
+				return "", fmt.Errorf("%w: bad thousands grouping in %s", ErrNumber, match[0])
+			}
+		}
+	}
+	digits := strings.TrimLeft(strings.ReplaceAll(match[3], ",", ""), "0")
+	if digits == "" {
+		digits = "0"
+	}
+	number := digits + match[4]
+	if match[1] != "" || match[2] != "" {
+		number = "-" + number
+	}
+	return number, nil
+}
+
+// numberRuleList is the flag.Value behind -parse-number, which can be repeated
+type numberRuleList []NumberRule
+
+func (l *numberRuleList) String() string {
+	var rules []string
+	for _, n := range *l {
+		rules = append(rules, n.Source+"->"+n.Column)
+	}
+	return strings.Join(rules, " ")
+}
+
+func (l *numberRuleList) Set(s string) error {
+	n, err := parseNumberRule(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, n)
+	return nil
+}
@@ -0,0 +1,101 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"$1,234.50", "1234.50", false},
+		{"paid $1,234.50 today", "1234.50", false},
+		{"€12", "12", false},
+		{"-$5.25", "-5.25", false},
+		{"$-5.25", "-5.25", false},
+		{"1,234,567", "1234567", false},
+		{"007", "7", false},
+		{"0.50", "0.50", false},
+		{"12345", "12345", false},
+		{"1:11:11.123", "1", false},
+		{"12,3456", "", true},
+		{"1,23", "", true},
+		{"1234,567", "", true},
+		{"1,234,56.7", "", true},
+		{"no number here", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseNumber(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrNumber) {
+					t.Errorf("got %q, %v, want ErrNumber", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumberRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    NumberRule
+		wantErr bool
+	}{
+		{"Notes->Amount", NumberRule{"Notes", "Amount"}, false},
+		{"Notes->", NumberRule{}, true},
+		{"->Amount", NumberRule{}, true},
+		{"Nope->Amount", NumberRule{}, true},
+		{"Notes", NumberRule{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseNumberRule(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseNumberRule(%q) = %v, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestParseNumberColumn(t *testing.T) {
+	tests := []struct {
+		name   string
+		notes  string
+		strict bool
+		want   string
+		lines  int
+	}{
+		{"amount", "paid $1,234.50", false, "1234.50", 2},
+		{"no number", "nothing", false, "", 2},
+		{"no number, strict", "nothing", true, "", 1},
+		{"bad grouping", "12,3456", false, "", 2},
+		{"bad grouping, strict", "12,3456", true, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{"-parse-number", "Notes->Amount"}
+			if tt.strict {
+				args = append(args, "-parse-number-strict")
+			}
+			row := "4/1/11 11:00:00 AM,123 4th St,94121,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa,\"" + tt.notes + "\"\n"
+			lines := outputLines(t, testConfig(t, args...), testHeader+row)
+			if len(lines) != tt.lines {
+				t.Fatalf("got %d lines, want %d: %v", len(lines), tt.lines, lines)
+			}
+			if tt.lines == 2 && !strings.HasSuffix(lines[1], ","+tt.want) {
+				t.Errorf("row %q doesn't end with Amount %q", lines[1], tt.want)
+			}
+		})
+	}
+}
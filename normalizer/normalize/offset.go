@@ -0,0 +1,57 @@
+package normalize
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// offsetColumn is the extra column -add-offset-column writes each row's
+// starting byte offset in
+const offsetColumn = "_offset"
+
+// lineOffsets sits between an input and everything that reads it and notes
+// where each line starts, so a row's byte offset can be found from the line
+// the reader says it started on. The csv reader reads ahead of the row it's
+// on, so counting bytes alone wouldn't do. Only the lines from the one last
+// asked about onwards are kept, since rows are asked about in order
+type lineOffsets struct {
+	r    io.Reader
+	read int64
+	// starts[i] is the offset line first+i starts at
+	first  int
+	starts []int64
+}
+
+func newLineOffsets(r io.Reader) *lineOffsets {
+	return &lineOffsets{r: r, first: 1, starts: []int64{0}}
+}
+
+func (l *lineOffsets) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	for i := 0; i < n; {
+		nl := bytes.IndexByte(p[i:n], '\n')
+		if nl < 0 {
+			break
+		}
+		i += nl + 1
+		l.starts = append(l.starts, l.read+int64(i))
+	}
+	l.read += int64(n)
+	return n, err
+}
+
+// at is the byte offset line starts at, in the input as it was read
+func (l *lineOffsets) at(line int) int64 {
+	if drop := line - l.first; drop > 0 && drop < len(l.starts) {
+		l.starts = l.starts[drop:]
+		l.first = line
+	}
+	return l.starts[line-l.first]
+}
+
+// setOffset fills in the -add-offset-column column for the row that starts
+// on line
+func (o *openedInput) setOffset(record *Record, line int) {
+	record.setExtra(offsetColumn, strconv.FormatInt(o.offsets.at(line), 10))
+}
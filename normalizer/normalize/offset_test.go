@@ -0,0 +1,71 @@
+package normalize
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestLineOffsets(t *testing.T) {
+	in := "header\nrow one\n\nrow three\r\nlast"
+	tests := []struct {
+		name  string
+		lines []int
+		want  []int64
+	}{
+		{"every line", []int{1, 2, 3, 4, 5}, []int64{0, 7, 15, 16, 27}},
+		// Asked in order, skipping some
+		{"skipping", []int{2, 5}, []int64{7, 27}},
+		{"the same one twice", []int{4, 4}, []int64{16, 16}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A byte at a time, so lines are split across reads
+			offsets := newLineOffsets(iotest.OneByteReader(strings.NewReader(in)))
+			if _, err := ioutil.ReadAll(offsets); err != nil {
+				t.Fatal(err)
+			}
+			for i, line := range tt.lines {
+				if got := offsets.at(line); got != tt.want[i] {
+					t.Errorf("line %d at %d, want %d", line, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAddOffsetColumn(t *testing.T) {
+	multiline := strings.Replace(testRow, "notes", "\"two\nlines\"", 1)
+	bad := strings.Replace(testRow, "1:23:32.123", "nope", 1)
+	crlf := strings.Replace(testRow, "\n", "\r\n", 1)
+	in := testHeader + testRow + multiline + bad + crlf
+
+	records := outputRecords(t, testConfig(t, "-add-offset-column", "-annotate-errors"), in)
+	if len(records) != 5 || records[0][8] != offsetColumn || records[0][9] != annotateErrorsColumn {
+		t.Fatalf("got %q, want four rows with _offset before _error", records)
+	}
+	last := -1
+	for _, record := range records[1:] {
+		offset, err := strconv.Atoi(record[8])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset <= last {
+			t.Errorf("offset %d comes after %d", offset, last)
+		}
+		last = offset
+		// Seeking there gets back to the start of the row
+		if !strings.HasPrefix(in[offset:], "4/1/11 11:00:00 AM,") || (offset > 0 && in[offset-1] != '\n') {
+			t.Errorf("offset %d isn't the start of a row: %q", offset, in[offset:])
+		}
+	}
+	if records[1][8] != strconv.Itoa(len(testHeader)) {
+		t.Errorf("first row at %s, want the header's length %d", records[1][8], len(testHeader))
+	}
+
+	if err := configError(t, "-add-offset-column", "-record-separator", ";"); err == nil {
+		t.Error("expected an error with -record-separator")
+	}
+}
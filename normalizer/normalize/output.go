@@ -0,0 +1,47 @@
+package normalize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// OutputOpener opens somewhere for -output to write to, given the whole URI.
+// Everything's written before Close is called, so a writer that uploads can
+// do it then, and a Close error fails the run
+type OutputOpener func(uri string) (io.WriteCloser, error)
+
+// openOutput opens an -output: - for stdout, file://... or scheme://... for
+// one of cfg.Outputs, and anything else is a file path
+func openOutput(cfg *Config, uri string) (io.WriteCloser, error) {
+	if uri == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	i := strings.Index(uri, "://")
+	if i < 0 {
+		return os.Create(uri)
+	}
+	open, ok := cfg.Outputs[uri[:i]]
+	if !ok && uri[:i] == "file" {
+		open, ok = openFileOutput, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("nothing registered to write %s:// outputs", uri[:i])
+	}
+	return open(uri)
+}
+
+func openFileOutput(uri string) (io.WriteCloser, error) {
+	return os.Create(strings.TrimPrefix(uri, "file://"))
+}
+
+// nopWriteCloser is stdout as an -output, which mustn't be closed under
+// everything else that writes to it
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,119 @@
+package normalize
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testOutputs are the test schemes. TestMain gives them to Main, so they're
+// there in the runMain processes too
+var testOutputs = map[string]OutputOpener{
+	"test-upload": func(uri string) (io.WriteCloser, error) {
+		f, err := os.Create(strings.TrimPrefix(uri, "test-upload://"))
+		return uploadingFile{f}, err
+	},
+	"test-failing": func(uri string) (io.WriteCloser, error) {
+		return failingUpload{}, nil
+	},
+}
+
+// uploadingFile only writes its marker on Close, the way an uploader only
+// finishes then
+type uploadingFile struct {
+	*os.File
+}
+
+func (u uploadingFile) Close() error {
+	u.WriteString("uploaded\n")
+	return u.File.Close()
+}
+
+// failingUpload takes everything and then fails on Close
+type failingUpload struct{}
+
+func (failingUpload) Write(p []byte) (int, error) { return len(p), nil }
+func (failingUpload) Close() error                { return errors.New("upload failed") }
+
+func TestOpenOutput(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		uri     string
+		path    string
+		wantErr bool
+	}{
+		{"path", filepath.Join(dir, "plain.csv"), filepath.Join(dir, "plain.csv"), false},
+		{"file uri", "file://" + filepath.Join(dir, "file.csv"), filepath.Join(dir, "file.csv"), false},
+		{"registered", "test-upload://" + filepath.Join(dir, "up.csv"), filepath.Join(dir, "up.csv"), false},
+		{"unknown scheme", "s3://bucket/key", "", true},
+		{"missing directory", filepath.Join(dir, "missing", "out.csv"), "", true},
+	}
+	cfg := testConfig(t)
+	cfg.Outputs = testOutputs
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := openOutput(cfg, tt.uri)
+			if tt.wantErr {
+				if err == nil {
+					w.Close()
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.WriteString(w, "data\n")
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if data, err := ioutil.ReadFile(tt.path); err != nil || !strings.HasPrefix(string(data), "data\n") {
+				t.Errorf("got %q, %v in %s", data, err, tt.path)
+			}
+		})
+	}
+
+	// stdout isn't really closed
+	w, err := openOutput(cfg, "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := w.(nopWriteCloser); !ok {
+		t.Errorf("got %T for -", w)
+	}
+}
+
+func TestOutputFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.csv")
+	stdout, stderr, status := runMain(t, testHeader+testRow, "-output", "test-upload://"+path)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("wrote %q to stdout", stdout)
+	}
+	// Everything was written before Close
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(string(data), "\n"); len(lines) != 4 || lines[2] != "uploaded" {
+		t.Errorf("got\n%s", data)
+	}
+
+	_, stderr, status = runMain(t, testHeader+testRow, "-output", "test-failing://x")
+	if status != 1 || !strings.Contains(stderr, "upload failed") {
+		t.Errorf("failing Close: exit status %d, stderr %q, want 1 and the error", status, stderr)
+	}
+	if _, _, status := runMain(t, testHeader+testRow, "-output", "s3://bucket/key"); status != 1 {
+		t.Errorf("unknown scheme: exit status %d, want 1", status)
+	}
+	if err := configError(t, "-output", path, "-split-rows", "10"); err == nil {
+		t.Error("expected an error with -split-rows")
+	}
+}
@@ -0,0 +1,105 @@
+package normalize
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// defaultMaxColWidth is how wide a -preview column can get unless told
+// otherwise, in characters
+const defaultMaxColWidth = 30
+
+// previewSink is the Sink for -preview. It keeps the rows, which there are
+// only a few of, and writes them out at the end as a table lined up for
+// people, like
+//
+//	ZIP   | FullName
+//	------+---------------
+//	00501 | MONKEY ALBERTO
+//
+// Widths go by characters, so wide characters like CJK and emoji can still
+// push a column out of line
+type previewSink struct {
+	w        *bufio.Writer
+	columns  []string
+	extra    []string
+	maxWidth int
+	rows     [][]string
+}
+
+func newPreviewSink(cfg *Config, w io.Writer, columns []string) *previewSink {
+	return &previewSink{w: bufio.NewWriter(w), columns: columns, extra: cfg.ExtraColumns(), maxWidth: cfg.MaxColWidth}
+}
+
+// The header always goes in the table, whatever the flags say about writing one
+func (s *previewSink) WriteHeader(columns []string) error {
+	return nil
+}
+
+func (s *previewSink) WriteRecord(r *Record) error {
+	s.rows = append(s.rows, r.Row(s.extra))
+	return nil
+}
+
+func (s *previewSink) Flush() error {
+	return nil
+}
+
+// cell fits value on one line and into the column width, with an ellipsis
+// if it had to be shortened
+func (s *previewSink) cell(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, value)
+	if utf8.RuneCountInString(value) <= s.maxWidth {
+		return value
+	}
+	return string([]rune(value)[:s.maxWidth-1]) + "…"
+}
+
+func (s *previewSink) Close() error {
+	table := append([][]string{s.columns}, s.rows...)
+	widths := make([]int, len(s.columns))
+	for i, row := range table {
+		// A copy, since the header is the caller's
+		cells := make([]string, len(row))
+		for j, value := range row {
+			cells[j] = s.cell(value)
+			if n := utf8.RuneCountInString(cells[j]); n > widths[j] {
+				widths[j] = n
+			}
+		}
+		table[i] = cells
+	}
+	line := func(row []string) {
+		for j, value := range row {
+			if j > 0 {
+				s.w.WriteString(" | ")
+			}
+			s.w.WriteString(value)
+			// No trailing spaces after the last column
+			if j < len(row)-1 {
+				s.w.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(value)))
+			}
+		}
+		s.w.WriteByte('\n')
+	}
+	line(table[0])
+	for j, width := range widths {
+		if j > 0 {
+			s.w.WriteString("-+-")
+		}
+		s.w.WriteString(strings.Repeat("-", width))
+	}
+	s.w.WriteByte('\n')
+	for _, row := range table[1:] {
+		line(row)
+	}
+	return s.w.Flush()
+}
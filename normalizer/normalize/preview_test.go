@@ -0,0 +1,69 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreviewCell(t *testing.T) {
+	s := &previewSink{maxWidth: 5}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"short", "short"},
+		{"longer", "long…"},
+		{"two\nlines", "two …"},
+		{"tab\there", "tab …"},
+		{"héllo", "héllo"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := s.cell(tt.in); got != tt.want {
+			t.Errorf("cell(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPreview(t *testing.T) {
+	bad := strings.Replace(testRow, "1:23:32.123", "nope", 1)
+	second := strings.Replace(testRow, "notes", "second", 1)
+	var rest strings.Builder
+	for i := 0; i < 1000; i++ {
+		rest.WriteString(testRow)
+	}
+	in := &countingReader{r: strings.NewReader(testHeader + bad + testRow + second + rest.String())}
+
+	cfg := testConfig(t, "-preview", "2", "-max-col-width", "12")
+	var out strings.Builder
+	if err := transform(cfg, in, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "" +
+		"Timestamp    | Address    | ZIP   | FullName     | FooDuration | BarDuration | TotalDurati… | Notes\n" +
+		"-------------+------------+-------+--------------+-------------+-------------+--------------+-------\n" +
+		"2011-04-01T… | 123 4th St | 94121 | MONKEY ALBE… | 5012.123000 | 5553.123000 | 10565.246000 | notes\n" +
+		"2011-04-01T… | 123 4th St | 94121 | MONKEY ALBE… | 5012.123000 | 5553.123000 | 10565.246000 | second\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out.String(), want)
+	}
+	// It stopped once it had its rows
+	if in.n >= len(testHeader)+1000*len(testRow) {
+		t.Errorf("read %d bytes, want it to stop early", in.n)
+	}
+
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-preview", "-1"}, "can't be negative"},
+		{[]string{"-preview", "5", "-output-format", "json"}, "writes its own table"},
+		{[]string{"-preview", "5", "-diff"}, "writes its own table"},
+		{[]string{"-max-col-width", "1"}, "at least 2"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
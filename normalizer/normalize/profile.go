@@ -0,0 +1,119 @@
+package normalize
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const defaultProfileFile = "normalizer-profiles.json"
+
+// A profile file is a JSON object of named profiles, each an object of flag
+// names (without the dash) to values, e.g.
+//
+//	{
+//	  "eastern-daily": {
+//	    "source-tz": "US/Pacific",
+//	    "delimiter": ";",
+//	    "no-normalize-name": true,
+//	    "extract": ["Notes=(\\S+@\\S+)->Email"]
+//	  }
+//	}
+//
+// Values can be strings, numbers or booleans. An array sets a repeatable flag
+// once per element
+type profileFile map[string]map[string]json.RawMessage
+
+// applyProfile sets fs's flags from the named profile in path, skipping any
+// the user already gave on the command line so those still win. It goes
+// through fs.Set, so profile values get exactly the same checks as flags do
+func applyProfile(fs *flag.FlagSet, path, name string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("can't read profiles: %w", err)
+	}
+	var profiles profileFile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return fmt.Errorf("can't parse profiles in %s: %w", path, err)
+	}
+	settings, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("no profile %q in %s", name, path)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	// Sorted, so a bad profile always fails on the same flag
+	names := make([]string, 0, len(settings))
+	for flagName := range settings {
+		names = append(names, flagName)
+	}
+	sort.Strings(names)
+
+	for _, flagName := range names {
+		raw := settings[flagName]
+		if flagName == "profile" || flagName == "profile-file" {
+			return fmt.Errorf("profile %q can't set -%s", name, flagName)
+		}
+		if fs.Lookup(flagName) == nil {
+			return fmt.Errorf("profile %q sets unknown flag -%s", name, flagName)
+		}
+		if explicit[flagName] {
+			continue
+		}
+		values, err := profileValues(raw)
+		if err != nil {
+			return fmt.Errorf("profile %q, -%s: %w", name, flagName, err)
+		}
+		for _, value := range values {
+			if err := fs.Set(flagName, value); err != nil {
+				return fmt.Errorf("profile %q, -%s: %w", name, flagName, err)
+			}
+		}
+	}
+	return nil
+}
+
+// profileValues turns one profile value into the string(s) we'd have typed
+// on the command line
+func profileValues(raw json.RawMessage) ([]string, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		var values []string
+		for _, item := range list {
+			value, err := profileValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+	value, err := profileValue(raw)
+	if err != nil {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+func profileValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	switch v.(type) {
+	case bool, float64:
+		// The JSON text is already what we'd type, 1.5 or true
+		return string(raw), nil
+	}
+	return "", fmt.Errorf("expected a string, number, boolean or array, got %s", raw)
+}
@@ -0,0 +1,83 @@
+package normalize
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testProfiles = `{
+  "eastern": {
+    "source-tz": "US/Eastern",
+    "no-normalize-name": true,
+    "rate": 2.5,
+    "extract": ["Notes=(\\S+@\\S+)->Email", "Notes=#(\\d+)->Ticket"]
+  },
+  "unknown": {"no-such-flag": 1},
+  "bad-value": {"rate": "fast"},
+  "nested": {"profile": "eastern"},
+  "object": {"rate": {"per": "second"}}
+}`
+
+// profileConfig parses args, then applies the named profile from
+// testProfiles the way Main does
+func profileConfig(t *testing.T, name string, args ...string) (*Config, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "profiles.json")
+	if err := ioutil.WriteFile(path, []byte(testProfiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	fs := flag.NewFlagSet("normalizer", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	RegisterFlags(fs, cfg)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, applyProfile(fs, path, name)
+}
+
+func TestApplyProfile(t *testing.T) {
+	cfg, err := profileConfig(t, "eastern")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SourceTZ != "US/Eastern" || !cfg.NoNormalizeName || cfg.Rate != 2.5 {
+		t.Errorf("got source-tz %q, no-normalize-name %v, rate %v", cfg.SourceTZ, cfg.NoNormalizeName, cfg.Rate)
+	}
+	if len(cfg.Extracts) != 2 || cfg.Extracts[1].Column != "Ticket" {
+		t.Errorf("got extracts %v", cfg.Extracts)
+	}
+
+	// The command line wins
+	cfg, err = profileConfig(t, "eastern", "-source-tz", "UTC", "-rate", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.SourceTZ != "UTC" || cfg.Rate != 0 || !cfg.NoNormalizeName {
+		t.Errorf("got source-tz %q, rate %v, no-normalize-name %v", cfg.SourceTZ, cfg.Rate, cfg.NoNormalizeName)
+	}
+}
+
+func TestApplyProfileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"missing", `no profile "missing"`},
+		{"unknown", "unknown flag -no-such-flag"},
+		{"bad-value", "-rate"},
+		{"nested", "can't set -profile"},
+		{"object", "expected a string, number, boolean or array"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := profileConfig(t, tt.name)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error like %q", err, tt.want)
+			}
+		})
+	}
+}
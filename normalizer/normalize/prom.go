@@ -0,0 +1,67 @@
+package normalize
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// skipReasons is every skipReason*, so -prom-textfile has a line for each
+var skipReasons = []string{skipReasonHook, skipReasonSkipKeys, skipReasonDuplicate}
+
+// writePromTextfile writes the run's counts to path in the Prometheus text
+// exposition format, for node_exporter's textfile collector. They're all
+// gauges, since each file describes one run rather than counting up across
+// them. The collector can read the file at any moment, so it's written next
+// to path and renamed over it, and never seen half written
+func writePromTextfile(path string, counts runCounts, finished time.Time, runErr error) error {
+	var buf bytes.Buffer
+	gauge := func(name, help string) {
+		fmt.Fprintf(&buf, "# HELP %[1]s %[2]s\n# TYPE %[1]s gauge\n", name, help)
+	}
+
+	gauge("normalizer_rows_total", "Data rows read in the last run.")
+	fmt.Fprintf(&buf, "normalizer_rows_total %d\n", counts.Read)
+	gauge("normalizer_rows_written", "Rows written in the last run.")
+	fmt.Fprintf(&buf, "normalizer_rows_written %d\n", counts.Written)
+	gauge("normalizer_rows_rejected", "Rows rejected in the last run, by error type.")
+	for _, typ := range errorTypes {
+		fmt.Fprintf(&buf, "normalizer_rows_rejected{type=%q} %d\n", typ, counts.RejectedByType[typ])
+	}
+	gauge("normalizer_rows_skipped", "Rows skipped on purpose in the last run, by reason.")
+	for _, reason := range skipReasons {
+		fmt.Fprintf(&buf, "normalizer_rows_skipped{reason=%q} %d\n", reason, counts.SkippedByReason[reason])
+	}
+	success := 1
+	if runErr != nil {
+		success = 0
+	}
+	gauge("normalizer_last_run_success", "1 if the last run finished without an error, 0 if not.")
+	fmt.Fprintf(&buf, "normalizer_last_run_success %d\n", success)
+	gauge("normalizer_last_run_timestamp_seconds", "When the last run finished, in Unix time.")
+	fmt.Fprintf(&buf, "normalizer_last_run_timestamp_seconds %d\n", finished.Unix())
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".normalizer-prom-*")
+	if err != nil {
+		return err
+	}
+	// CreateTemp makes it private, but the collector usually runs as
+	// someone else
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
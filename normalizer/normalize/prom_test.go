@@ -0,0 +1,87 @@
+package normalize
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWritePromTextfile(t *testing.T) {
+	counts := runCounts{
+		Read:            5,
+		Written:         2,
+		Rejected:        2,
+		Skipped:         1,
+		RejectedByType:  map[string]int{"zip": 2},
+		SkippedByReason: map[string]int{skipReasonHook: 1},
+	}
+	finished := time.Unix(1300000000, 0)
+	tests := []struct {
+		name   string
+		runErr error
+		want   []string
+	}{
+		{"success", nil, []string{
+			"# TYPE normalizer_rows_total gauge\nnormalizer_rows_total 5\n",
+			"normalizer_rows_written 2\n",
+			"normalizer_rows_rejected{type=\"zip\"} 2\n",
+			// Every type gets a line, even at zero
+			"normalizer_rows_rejected{type=\"timestamp\"} 0\n",
+			"normalizer_rows_skipped{reason=\"hook\"} 1\n",
+			"normalizer_rows_skipped{reason=\"duplicate\"} 0\n",
+			"normalizer_last_run_success 1\n",
+			"normalizer_last_run_timestamp_seconds 1300000000\n",
+		}},
+		{"failure", errors.New("boom"), []string{"normalizer_last_run_success 0\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "normalizer.prom")
+			if err := writePromTextfile(path, counts, finished, tt.runErr); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("missing %q in\n%s", want, data)
+				}
+			}
+			// Readable by the collector, and no temporary file left behind
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0644 {
+				t.Errorf("mode %v, want 0644", info.Mode().Perm())
+			}
+			if entries, _ := ioutil.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("%d files in the directory, want 1", len(entries))
+			}
+		})
+	}
+}
+
+func TestPromTextfileFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "normalizer.prom")
+	bad := strings.Replace(testRow, "1:23:32.123", "nope", 1)
+	if _, stderr, status := runMain(t, testHeader+testRow+bad, "-prom-textfile", path); status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"normalizer_rows_total 2\n", "normalizer_rows_written 1\n", "normalizer_rows_rejected{type=\"duration\"} 1\n"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in\n%s", want, data)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package normalize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// configHash is a short fingerprint of every setting in cfg, so two outputs
+// with the same one were made the same way. The -hash-key is left out: it
+// isn't how the output looks, and it's a secret
+func configHash(cfg *Config) (string, error) {
+	// Through a map, since copying a Config to blank the key would copy its
+	// locks too. Maps marshal with their keys sorted, so it's stable
+	var settings map[string]interface{}
+	encoded, err := json.Marshal(cfg)
+	if err == nil {
+		err = json.Unmarshal(encoded, &settings)
+	}
+	if err != nil {
+		// Check keeps NaN and the like out of the floats, but a Config built
+		// in code doesn't go through it
+		return "", err
+	}
+	delete(settings, "HashKey")
+	encoded, _ = json.Marshal(settings)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])[:hashLength], nil
+}
+
+// writeProvenance writes the -provenance-comment line, e.g.
+//
+//	# normalizer version=dev time=2026-10-14T09:30:00Z config=1f0c5a2b9e8d7c6b
+func writeProvenance(w io.Writer, cfg *Config, now time.Time) error {
+	hash, err := configHash(cfg)
+	if err != nil {
+		return fmt.Errorf("can't hash the settings for -provenance-comment: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "%s normalizer version=%s time=%s config=%s\n", cfg.CommentPrefix, version, now.UTC().Format(time.RFC3339), hash); err != nil {
+		return fmt.Errorf("unexpected error writing output: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package normalize
+
+import (
+	"bytes"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustConfigHash is configHash for configs that are known to hash
+func mustConfigHash(t *testing.T, cfg *Config) string {
+	t.Helper()
+	hash, err := configHash(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestConfigHash(t *testing.T) {
+	base := mustConfigHash(t, testConfig(t))
+	if len(base) != hashLength {
+		t.Errorf("got %q, want %d hex digits", base, hashLength)
+	}
+	tests := []struct {
+		name string
+		args []string
+		same bool
+	}{
+		{"same settings", nil, true},
+		{"a different setting", []string{"-zip-format", "uk"}, false},
+		{"another", []string{"-duration-precision", "2"}, false},
+		// The key's a secret, and doesn't change how the output looks
+		{"only the key", []string{"-hash-key", "secret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mustConfigHash(t, testConfig(t, tt.args...)); (got == base) != tt.same {
+				t.Errorf("got %s against %s, want the same %v", got, base, tt.same)
+			}
+		})
+	}
+	// Output openers are code, which can't be hashed and isn't a setting
+	withOutputs := testConfig(t)
+	withOutputs.Outputs = testOutputs
+	if got := mustConfigHash(t, withOutputs); got != base {
+		t.Errorf("with Outputs, got %s against %s", got, base)
+	}
+}
+
+func TestWriteProvenance(t *testing.T) {
+	cfg := testConfig(t, "-comment-prefix", "//")
+	var out bytes.Buffer
+	now := time.Date(2026, 10, 14, 5, 30, 0, 0, time.FixedZone("EDT", -4*3600))
+	if err := writeProvenance(&out, cfg, now); err != nil {
+		t.Fatal(err)
+	}
+	want := "// normalizer version=" + version + " time=2026-10-14T09:30:00Z config=" + mustConfigHash(t, cfg) + "\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestProvenanceUnhashable(t *testing.T) {
+	// Check would turn this down, but code building its own Config skips it
+	cfg := testConfig(t, "-provenance-comment")
+	cfg.MaxUTF8ReplacementRate = math.NaN()
+	if _, err := configHash(cfg); err == nil {
+		t.Error("configHash: expected an error for NaN")
+	}
+	_, err := runTest(t, cfg, testHeader+testRow)
+	if err == nil || !strings.Contains(err.Error(), "-provenance-comment") {
+		t.Errorf("got error %v, want one about -provenance-comment", err)
+	}
+}
+
+func TestProvenanceCommentFlag(t *testing.T) {
+	comment := regexp.MustCompile(`^# normalizer version=\S+ time=\S+Z config=[0-9a-f]{16}$`)
+	tests := []struct {
+		name string
+		args []string
+		bom  string
+	}{
+		{"csv", nil, ""},
+		{"after the BOM", []string{"-output-bom"}, "\ufeff"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-provenance-comment"}, tt.args...)
+			got := outputLines(t, testConfig(t, args...), testHeader+testRow)
+			if len(got) != 3 || !strings.HasPrefix(got[0], tt.bom+"#") || !comment.MatchString(strings.TrimPrefix(got[0], tt.bom)) || !strings.HasPrefix(got[1], "Timestamp,") {
+				t.Errorf("got %q, want the comment before the header", got)
+			}
+		})
+	}
+
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-provenance-comment", "-output-format", "json"}, "only works with csv"},
+		{[]string{"-provenance-comment", "-preview", "3"}, "only works with csv"},
+		{[]string{"-comment-prefix", ""}, "can't be empty"},
+		{[]string{"-comment-prefix", "#\n"}, "line break"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
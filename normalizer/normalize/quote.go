@@ -0,0 +1,82 @@
+package normalize
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// encoding/csv only knows about double quotes. quoteTranslator rewrites
+// input that quotes with some other character into input that quotes with
+// double quotes, so the stdlib reader can take it from there: quote
+// characters that open or close a field become ", a doubled quote character
+// inside quotes becomes a single ordinary one, and any " that was ordinary
+// text inside a quoted field becomes "" so it stays ordinary text.
+//
+// It's a byte-level rewrite that only tracks whether we're inside quotes, so
+// it has the same limits the custom quote has in the source data: a quote
+// character in an unquoted field will be taken as the start of quoting. A "
+// in an unquoted field is passed through as-is, which needs the reader's
+// LazyQuotes to be on
+type quoteTranslator struct {
+	src      *bufio.Reader
+	quote    rune
+	inQuotes bool
+	// A quote character inside quotes has been read, and whether it closes
+	// the field or is the first of a doubled one depends on what's next
+	sawQuote bool
+	pending  bytes.Buffer
+}
+
+func newQuoteTranslator(r io.Reader, quote rune) *quoteTranslator {
+	return &quoteTranslator{src: bufio.NewReader(r), quote: quote}
+}
+
+func (t *quoteTranslator) Read(p []byte) (int, error) {
+	for t.pending.Len() < len(p) {
+		// Give back what we have rather than wait on the source for the
+		// rest, or -interactive and -follow would sit on a line that's
+		// already arrived. That includes waiting to see what follows a
+		// quote character: it's held back until the next character, which
+		// for the end of a line is the newline, comes in
+		if t.pending.Len() > 0 && t.src.Buffered() == 0 {
+			break
+		}
+		r, size, err := t.src.ReadRune()
+		if t.sawQuote {
+			t.sawQuote = false
+			// Inside quotes a doubled quote character is an escaped one,
+			// which after translation is just an ordinary character
+			if err == nil && r == t.quote {
+				t.pending.WriteRune(t.quote)
+				continue
+			}
+			t.inQuotes = false
+			t.pending.WriteByte('"')
+		}
+		if err != nil {
+			if t.pending.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+		switch {
+		case r == t.quote && t.inQuotes:
+			t.sawQuote = true
+		case r == t.quote:
+			t.inQuotes = true
+			t.pending.WriteByte('"')
+		case r == '"' && t.inQuotes:
+			t.pending.WriteString(`""`)
+		case r == utf8.RuneError && size == 1:
+			// Keep invalid bytes exactly as they were, UTF-8 repair happens later
+			t.src.UnreadRune()
+			b, _ := t.src.ReadByte()
+			t.pending.WriteByte(b)
+		default:
+			t.pending.WriteRune(r)
+		}
+	}
+	return t.pending.Read(p)
+}
@@ -0,0 +1,94 @@
+package normalize
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuoteTranslator(t *testing.T) {
+	tests := []struct {
+		name  string
+		quote rune
+		in    string
+		want  string
+	}{
+		{"plain", '\'', "a,b\n", "a,b\n"},
+		{"quoted field", '\'', "a,'b, c'\n", "a,\"b, c\"\n"},
+		{"doubled quote character", '\'', "'it''s'\n", "\"it's\"\n"},
+		{"double quote inside quotes", '\'', `'say "hi"'` + "\n", `"say ""hi"""` + "\n"},
+		{"double quote outside quotes", '\'', `a"b` + "\n", `a"b` + "\n"},
+		{"invalid UTF-8 kept", '|', "|\xff|\n", "\"\xff\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ioutil.ReadAll(newQuoteTranslator(strings.NewReader(tt.in), tt.quote))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// readsWithoutWaiting checks that a Read on r gives back the line already
+// written to w, without waiting for whatever comes after
+func readsWithoutWaiting(t *testing.T, r io.Reader, w io.Writer, line, want string) {
+	t.Helper()
+	go w.Write([]byte(line))
+	got := make(chan string, 1)
+	go func() {
+		buf := make([]byte, 4096)
+		n, _ := r.Read(buf)
+		got <- string(buf[:n])
+	}()
+	select {
+	case s := <-got:
+		if s != want {
+			t.Errorf("read %q, want %q", s, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Read waited for more input")
+	}
+}
+
+func TestQuoteTranslatorDoesntWait(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	readsWithoutWaiting(t, newQuoteTranslator(pr, '\''), pw, "a,'b'\n", "a,\"b\"\n")
+}
+
+func TestQuoteTranslatorLineEndingInQuote(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		reads  []string
+	}{
+		// What comes before the quote character isn't held up by it, and
+		// the quote goes out with the newline
+		{"closing quote", []string{"a,'b'", "\n"}, []string{"a,\"b", "\"\n"}},
+		{"doubled quote split up", []string{"'it'", "'s'\n"}, []string{"\"it", "'s\"\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			translator := newQuoteTranslator(pr, '\'')
+			for i := range tt.writes {
+				readsWithoutWaiting(t, translator, pw, tt.writes[i], tt.reads[i])
+			}
+			// And the end of the input closes the field too
+			go pw.Write([]byte("'c'"))
+			buf := make([]byte, 16)
+			n, _ := translator.Read(buf)
+			pw.Close()
+			rest, err := ioutil.ReadAll(translator)
+			if got := string(buf[:n]) + string(rest); got != `"c"` || err != nil {
+				t.Errorf("at the end of the input, got %q, %v, want %q", got, err, `"c"`)
+			}
+		})
+	}
+}
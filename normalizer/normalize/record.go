@@ -0,0 +1,462 @@
+package normalize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// The csv lib parses for us just fine, but it gives us back []string slices
+// that are tedious to work with. We'll marshal these into a data structure instead
+type Record struct {
+	Timestamp     string
+	Address       string
+	Zip           string
+	FullName      string
+	FooDuration   string
+	BarDuration   string
+	TotalDuration string
+	Notes         string
+
+	// Columns we derive that aren't in the input, keyed by output header name.
+	// Which ones get written, and in what order, is up to Config.ExtraColumns.
+	// Map iteration order is random, so anything that writes these out has to
+	// go through a list (ExtraColumns, or sorted names like MarshalJSON does)
+	// to keep the output byte-for-byte repeatable
+	Extra map[string]string
+
+	// What Normalize parsed, so later steps don't have to parse the
+	// formatted strings back again
+	timestamp     time.Time
+	totalDuration time.Duration
+	// Which of the timestamp layouts matched
+	timestampLayout string
+
+	// Zone name from the -dest-tz-column column, if there is one
+	destZone string
+
+	// The fields as read, in canonical order, and the input line they came
+	// from, so -diff can say what Normalize changed
+	original []string
+	line     int
+
+	// How many fields the row had, and how many of them needed UTF-8 repair
+	fieldCount int
+	repaired   int
+
+	// Canonical column indexes in the order to write them out, for
+	// -preserve-input-order. nil is canonical order
+	order []int
+}
+
+// Values for -invalid-utf8
+const (
+	invalidUTF8Repair = "repair"
+	invalidUTF8Reject = "reject"
+)
+
+func validateUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	// This is new in go 1.13 as a convenience. Were it not there I would
+	// convert the string to []rune and walk it, checking each rune with
+	// utf8.ValidRune() and replacing failed runes with RuneError
+	return strings.ToValidUTF8(s, string(utf8.RuneError))
+}
+
+// mapping comes from mapHeaders and tells us where each column lives in fields
+func newRecord(fields []string, mapping []int) *Record {
+	original := make([]string, len(mapping))
+	for i, j := range mapping {
+		original[i] = fields[j]
+	}
+	repaired := 0
+	for i := range fields {
+		if !utf8.ValidString(fields[i]) {
+			repaired++
+		}
+		fields[i] = validateUTF8(fields[i])
+	}
+	return &Record{
+		original:      original,
+		fieldCount:    len(fields),
+		repaired:      repaired,
+		Timestamp:     fields[mapping[0]],
+		Address:       fields[mapping[1]],
+		Zip:           fields[mapping[2]],
+		FullName:      fields[mapping[3]],
+		FooDuration:   fields[mapping[4]],
+		BarDuration:   fields[mapping[5]],
+		TotalDuration: fields[mapping[6]],
+		Notes:         fields[mapping[7]],
+	}
+}
+
+// RecordFromFields builds a Record from fields in canonical column order,
+// the same order Fields returns them in
+func RecordFromFields(fields []string) (*Record, error) {
+	if len(fields) != len(canonicalHeaders) {
+		return nil, fmt.Errorf("expected %d fields, got %d", len(canonicalHeaders), len(fields))
+	}
+	mapping := make([]int, len(canonicalHeaders))
+	for i := range mapping {
+		mapping[i] = i
+	}
+	return newRecord(append([]string(nil), fields...), mapping), nil
+}
+
+// Normalize does our laundry list of changes to the input record in-place
+// If it fails we'll have a partially normalized record that should be skipped.
+// Errors are always a *FieldError wrapping one of the Err* sentinels
+//
+// UTF-8 repair always comes first, when the record is built, so every later
+// check sees valid text. With -invalid-utf8 reject, a field that needed it
+// fails here before anything else is looked at; otherwise the repaired value
+// carries on, and any later error about it is reported against the repaired
+// value, with the original bytes alongside in the error report
+func (r *Record) Normalize(cfg *Config) error {
+	if cfg.InvalidUTF8 == invalidUTF8Reject {
+		for i, raw := range r.original {
+			if !utf8.ValidString(raw) {
+				return &FieldError{Field: canonicalHeaders[i], Value: validateUTF8(raw), Err: ErrUTF8}
+			}
+		}
+	}
+
+	// Database dumps write missing values as \N or NULL. They're made empty
+	// before anything else looks at them, so empty values get handled the
+	// same whichever way they came
+	if len(cfg.NullTokens) > 0 {
+		for _, field := range r.fieldPointers() {
+			for _, token := range cfg.NullTokens {
+				if *field == token {
+					*field = ""
+					break
+				}
+			}
+		}
+	}
+
+	// Check declared types against the values as they came in, before any
+	// step has had a chance to change them
+	if cfg.schema != nil {
+		if err := r.checkTypes(cfg); err != nil {
+			return err
+		}
+	}
+
+	// -replace cleanups come before anything's parsed, so they can fix up
+	// values that wouldn't otherwise. The text columns have theirs with their
+	// other steps below
+	if len(cfg.Replacements) > 0 {
+		fields := r.fieldPointers()
+		for _, rule := range cfg.Replacements {
+			if c := columnIndex(rule.Column); !isTextColumn(c) {
+				*fields[c] = rule.replace(*fields[c])
+			}
+		}
+	}
+
+	if !cfg.NoNormalizeTimestamp {
+		if err := r.normalizeTimestamp(cfg); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.NoNormalizeDurations {
+		if err := r.normalizeDurations(cfg); err != nil {
+			return err
+		}
+	}
+
+	if !cfg.NoNormalizeZip {
+		zip, err := normalizeZip(r.Zip, cfg.ZipFormat, cfg.ZipMode)
+		if err != nil {
+			return &FieldError{Field: "ZIP", Value: r.Zip, Err: err}
+		}
+		r.Zip = zip
+	}
+
+	// The text columns each go through their steps, in the default order
+	// or the one the -schema gives them
+	form, useForm, _ := unicodeForm(cfg.UnicodeNormalize)
+	for i, field := range r.textFields() {
+		r.runTextSteps(textColumns[i], field, cfg, form, useForm)
+	}
+
+	if cfg.CheckZipState {
+		if err := r.checkZipState(); err != nil {
+			return err
+		}
+	}
+	if cfg.zipAllowlist != nil {
+		if err := r.checkZipAllowed(cfg.zipAllowlist); err != nil {
+			return err
+		}
+	}
+
+	// Derived columns come last, so they see the normalized values
+	for _, e := range cfg.Extracts {
+		value := e.extract(r.Fields()[columnIndex(e.Source)])
+		if cfg.ExtractLowercase {
+			value = strings.ToLower(value)
+		}
+		r.setExtra(e.Column, value)
+	}
+	for _, n := range cfg.ParseNumbers {
+		value, err := parseNumber(r.Fields()[columnIndex(n.Source)])
+		if err != nil && cfg.ParseNumberStrict {
+			return &FieldError{Field: n.Source, Value: r.Fields()[columnIndex(n.Source)], Err: err}
+		}
+		r.setExtra(n.Column, value)
+	}
+	if err := r.normalizeBools(cfg); err != nil {
+		return err
+	}
+	if cfg.SplitName {
+		first, last := splitName(r.FullName)
+		r.setExtra(firstNameColumn, first)
+		r.setExtra(lastNameColumn, last)
+	}
+	if len(cfg.HashColumns) > 0 {
+		r.hashColumns(cfg)
+	}
+	return nil
+}
+
+// Validate reports the error Normalize would return for r under cfg, without
+// changing r. It's Normalize run on a copy, so the two can't disagree
+func (r *Record) Validate(cfg *Config) error {
+	scratch := *r
+	if r.Extra != nil {
+		scratch.Extra = make(map[string]string, len(r.Extra))
+		for name, value := range r.Extra {
+			scratch.Extra[name] = value
+		}
+	}
+	return scratch.Normalize(cfg)
+}
+
+func (r *Record) normalizeTimestamp(cfg *Config) error {
+	t, layout, err := parseTimestampLayout(r.Timestamp, cfg)
+	if err != nil {
+		return &FieldError{Field: "Timestamp", Value: r.Timestamp, Err: err}
+	}
+	r.timestamp = t
+	r.timestampLayout = layout
+	// Convert to Eastern Time (or -dest-tz) before rendering as RFC3339,
+	// unless this row says where it wants to be. Rows that leave it blank get
+	// the usual zone too
+	destZone := cfg.DestTZ
+	field := "-dest-tz"
+	if r.destZone != "" {
+		destZone = r.destZone
+		field = cfg.DestTZColumn
+	}
+	dest, err := cfg.zones.load(destZone)
+	if err != nil {
+		return &FieldError{Field: field, Value: destZone, Err: ErrTimezone}
+	}
+	local := t.In(dest)
+	if cfg.TimestampDateOnly {
+		r.timestamp = truncateTime(local, truncateDay)
+		r.Timestamp = r.timestamp.Format("2006-01-02")
+		return nil
+	}
+	// Truncating after the zone change, so it's the destination's day
+	r.timestamp = truncateTime(local, cfg.TimestampTruncate)
+	r.Timestamp = r.timestamp.Format(time.RFC3339)
+	return nil
+}
+
+func (r *Record) normalizeDurations(cfg *Config) error {
+	fooDuration, err := parseInputDuration(r.FooDuration, cfg)
+	if err != nil {
+		return &FieldError{Field: "FooDuration", Value: r.FooDuration, Err: err}
+	}
+	barDuration, err := parseInputDuration(r.BarDuration, cfg)
+	if err != nil {
+		return &FieldError{Field: "BarDuration", Value: r.BarDuration, Err: err}
+	}
+
+	if cfg.CheckDurationConsistency {
+		if err := r.checkDurations(fooDuration, barDuration, cfg); err != nil {
+			return err
+		}
+	}
+
+	totalDuration := fooDuration + barDuration
+	// Some feeds round Foo and Bar but not the total, so trust theirs instead
+	// when asked to
+	if cfg.TotalSource == totalSourceInput || (cfg.TotalSource == totalSourcePreferInput && r.TotalDuration != "") {
+		totalDuration, err = parseInputDuration(r.TotalDuration, cfg)
+		if err != nil {
+			return &FieldError{Field: "TotalDuration", Value: r.TotalDuration, Err: err}
+		}
+	}
+	r.totalDuration = totalDuration
+
+	if cfg.DurationOutput == durationOutputISO8601 {
+		r.FooDuration = formatISODuration(fooDuration)
+		r.BarDuration = formatISODuration(barDuration)
+		r.TotalDuration = formatISODuration(totalDuration)
+	} else {
+		r.FooDuration = formatSeconds(fooDuration, cfg.DurationPrecision, cfg.DurationRounding)
+		r.BarDuration = formatSeconds(barDuration, cfg.DurationPrecision, cfg.DurationRounding)
+		r.TotalDuration = formatSeconds(totalDuration, cfg.DurationPrecision, cfg.DurationRounding)
+	}
+
+	if cfg.AddPercentColumns {
+		r.setExtra("FooPercent", formatPercent(fooDuration, totalDuration, cfg.PercentPrecision))
+		r.setExtra("BarPercent", formatPercent(barDuration, totalDuration, cfg.PercentPrecision))
+	}
+	return nil
+}
+
+// checkDurations looks for durations that parsed but can't be right: negative
+// ones, ones over -max-duration, and ones longer than the TotalDuration the
+// input gave us (when it gave us one we can read, which the sample doesn't)
+func (r *Record) checkDurations(foo, bar time.Duration, cfg *Config) error {
+	for _, d := range []struct {
+		field string
+		value string
+		d     time.Duration
+	}{
+		{"FooDuration", r.FooDuration, foo},
+		{"BarDuration", r.BarDuration, bar},
+	} {
+		if d.d < 0 {
+			return &FieldError{Field: d.field, Value: d.value, Err: fmt.Errorf("%w: negative", ErrDurationConsistency)}
+		}
+		if cfg.MaxDuration > 0 && d.d > cfg.MaxDuration {
+			return &FieldError{Field: d.field, Value: d.value, Err: fmt.Errorf("%w: longer than %v", ErrDurationConsistency, cfg.MaxDuration)}
+		}
+	}
+
+	total, err := parseDuration(r.TotalDuration, cfg.DurationInputFormat)
+	if err != nil {
+		return nil
+	}
+	if foo > total {
+		return &FieldError{Field: "FooDuration", Value: r.FooDuration, Err: fmt.Errorf("%w: longer than TotalDuration %q", ErrDurationConsistency, r.TotalDuration)}
+	}
+	if bar > total {
+		return &FieldError{Field: "BarDuration", Value: r.BarDuration, Err: fmt.Errorf("%w: longer than TotalDuration %q", ErrDurationConsistency, r.TotalDuration)}
+	}
+	return nil
+}
+
+// Returns a []string that can be fed to a CSV Writer
+func (r *Record) Fields() []string {
+	return []string{
+		r.Timestamp,
+		r.Address,
+		r.Zip,
+		r.FullName,
+		r.FooDuration,
+		r.BarDuration,
+		r.TotalDuration,
+		r.Notes,
+	}
+}
+
+// fieldPointers is Fields, but pointing into r so they can be changed
+func (r *Record) fieldPointers() []*string {
+	return []*string{
+		&r.Timestamp,
+		&r.Address,
+		&r.Zip,
+		&r.FullName,
+		&r.FooDuration,
+		&r.BarDuration,
+		&r.TotalDuration,
+		&r.Notes,
+	}
+}
+
+// outputFields is Fields in the order they're written out
+func (r *Record) outputFields() []string {
+	fields := r.Fields()
+	if r.order == nil {
+		return fields
+	}
+	ordered := make([]string, len(r.order))
+	for i, c := range r.order {
+		ordered[i] = fields[c]
+	}
+	return ordered
+}
+
+// Row is the fields in output order plus the named extra columns, in the
+// order given
+func (r *Record) Row(extra []string) []string {
+	row := r.outputFields()
+	for _, name := range extra {
+		row = append(row, r.Extra[name])
+	}
+	return row
+}
+
+func (r *Record) setExtra(name, value string) {
+	if r.Extra == nil {
+		r.Extra = make(map[string]string)
+	}
+	r.Extra[name] = value
+}
+
+// pow10 are the powers of ten up to a second's worth of nanoseconds
+var pow10 = [...]int64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000, 1000000000}
+
+// formatSeconds renders d as seconds with precision decimal places, rounded
+// the durationRounding* way. It works on the nanoseconds as integers rather
+// than going through a float, so a duration that's exactly a half at the last
+// place really is one, and rounds the way it's been asked to
+func formatSeconds(d time.Duration, precision int, rounding string) string {
+	n := int64(d)
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	unit := pow10[9-precision]
+	q, rem := n/unit, n%unit
+	switch rounding {
+	case durationRoundingHalfUp:
+		if rem*2 >= unit {
+			q++
+		}
+	case durationRoundingTruncate:
+	default:
+		if rem*2 > unit || (rem*2 == unit && q%2 == 1) {
+			q++
+		}
+	}
+
+	buf := make([]byte, 0, 24)
+	if neg && q != 0 {
+		buf = append(buf, '-')
+	}
+	scale := pow10[precision]
+	buf = strconv.AppendInt(buf, q/scale, 10)
+	if precision > 0 {
+		frac := strconv.FormatInt(q%scale, 10)
+		buf = append(buf, '.')
+		for i := len(frac); i < precision; i++ {
+			buf = append(buf, '0')
+		}
+		buf = append(buf, frac...)
+	}
+	return string(buf)
+}
+
+// formatPercent renders part as a percentage of total. A zero total has no
+// meaningful percentage, so we leave it blank rather than dividing by zero
+func formatPercent(part, total time.Duration, precision int) string {
+	if total == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(float64(part)/float64(total)*100, 'f', precision, 64)
+}
@@ -0,0 +1,261 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatPercent(t *testing.T) {
+	tests := []struct {
+		part, total time.Duration
+		precision   int
+		want        string
+	}{
+		{time.Second, 4 * time.Second, 2, "25.00"},
+		{time.Second, 3 * time.Second, 1, "33.3"},
+		{2 * time.Second, 3 * time.Second, 0, "67"},
+		{0, time.Second, 2, "0.00"},
+		{time.Second, 0, 2, ""},
+	}
+	for _, tt := range tests {
+		if got := formatPercent(tt.part, tt.total, tt.precision); got != tt.want {
+			t.Errorf("formatPercent(%v, %v, %d) = %q, want %q", tt.part, tt.total, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestPercentColumns(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		foo  string
+		bar  string
+		want []string
+	}{
+		{"quarter", nil, "0:00:01.000", "0:00:03.000", []string{"25.00", "75.00"}},
+		{"precision", []string{"-percent-precision", "0"}, "0:00:01.000", "0:00:02.000", []string{"33", "67"}},
+		{"zero total", nil, "0:00:00.000", "0:00:00.000", []string{"", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := strings.Replace(testRow, "1:23:32.123", tt.foo, 1)
+			row = strings.Replace(row, "1:32:33.123", tt.bar, 1)
+			records := outputRecords(t, testConfig(t, append(tt.args, "-add-percent-columns")...), testHeader+row)
+			header, got := records[0][8:], records[1][8:]
+			if strings.Join(header, ",") != "FooPercent,BarPercent" {
+				t.Errorf("extra columns = %v", header)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNoNormalize(t *testing.T) {
+	row := "4/1/11 11:00:00 AM,123 4th St,1,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa,notes\n"
+	normalized := []string{"2011-04-01T14:00:00-04:00", "123 4th St", "00001", "MONKEY ALBERTO", "5012.123000", "5553.123000", "10565.246000", "notes"}
+	tests := []struct {
+		flag string
+		// Which columns come out as they went in
+		untouched []int
+	}{
+		{"", nil},
+		{"-no-normalize-timestamp", []int{0}},
+		{"-no-normalize-zip", []int{2}},
+		{"-no-normalize-name", []int{3}},
+		{"-no-normalize-durations", []int{4, 5, 6}},
+	}
+	input := strings.Split(strings.TrimSuffix(row, "\n"), ",")
+	for _, tt := range tests {
+		t.Run(tt.flag, func(t *testing.T) {
+			var args []string
+			if tt.flag != "" {
+				args = append(args, tt.flag)
+			}
+			records := outputRecords(t, testConfig(t, args...), testHeader+row)
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want 2", len(records))
+			}
+			want := append([]string(nil), normalized...)
+			for _, c := range tt.untouched {
+				want[c] = input[c]
+			}
+			if got := records[1]; strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("got  %q\nwant %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCheckDurationConsistency(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		foo   string
+		total string
+		// The field rejected, empty if the row's fine
+		field string
+	}{
+		{"fine", nil, "1:23:32.123", "zzsasdfa", ""},
+		{"negative", nil, "-1h", "zzsasdfa", "FooDuration"},
+		{"too long", []string{"-max-duration", "1h"}, "1:23:32.123", "zzsasdfa", "FooDuration"},
+		{"under the limit", []string{"-max-duration", "2h"}, "1:23:32.123", "zzsasdfa", ""},
+		{"longer than the total", nil, "1:23:32.123", "1:00:00.000", "FooDuration"},
+		{"bar longer than the total", nil, "0:10:00.000", "1:00:00.000", "BarDuration"},
+		{"within the total", nil, "0:10:00.000", "2:00:00.000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := strings.Replace(testRow, "1:23:32.123", tt.foo, 1)
+			row = strings.Replace(row, "zzsasdfa", tt.total, 1)
+			entries := readErrorReport(t, testHeader+row, append(tt.args, "-check-duration-consistency")...)
+			switch {
+			case tt.field == "" && len(entries) > 0:
+				t.Errorf("got %+v, want the row written", entries)
+			case tt.field != "" && (len(entries) != 1 || entries[0].Field != tt.field || entries[0].Type != "duration_consistency"):
+				t.Errorf("got %+v, want a duration_consistency error in %s", entries, tt.field)
+			}
+		})
+	}
+
+	// Without the flag none of it is checked
+	row := strings.Replace(testRow, "1:23:32.123", "-1h", 1)
+	if entries := readErrorReport(t, testHeader+row); len(entries) != 0 {
+		t.Errorf("got %+v without -check-duration-consistency", entries)
+	}
+}
+
+func TestValidateUTF8(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"übertan 😀", "übertan 😀"},
+		{"caf\xe9", "caf�"},
+		{"\xff\xfe", "�"},
+	}
+	for _, tt := range tests {
+		if got := validateUTF8(tt.in); got != tt.want {
+			t.Errorf("validateUTF8(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	badNotes := strings.Replace(testRow, "notes", "caf\xe9", 1)
+	badFoo := strings.Replace(testRow, "1:23:32.123", "1:23:\xff32.123", 1)
+	tests := []struct {
+		name  string
+		mode  string
+		in    string
+		notes string
+		entry ReportEntry
+	}{
+		{"repaired", "repair", badNotes, "caf�", ReportEntry{}},
+		{"rejected", "reject", badNotes, "", ReportEntry{Type: "utf8", Field: "Notes", RawValue: `caf\xe9`}},
+		// Fails on its repaired value, with the original kept
+		{"repaired, then bad", "repair", badFoo, "", ReportEntry{Type: "duration", Field: "FooDuration", Value: "1:23:�32.123", RawValue: `1:23:\xff32.123`}},
+		{"rejected before it's checked", "reject", badFoo, "", ReportEntry{Type: "utf8", Field: "FooDuration", RawValue: `1:23:\xff32.123`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := readErrorReport(t, testHeader+tt.in, "-invalid-utf8", tt.mode)
+			if tt.entry.Type == "" {
+				records := outputRecords(t, testConfig(t, "-invalid-utf8", tt.mode), testHeader+tt.in)
+				if len(entries) != 0 || len(records) != 2 || records[1][7] != tt.notes {
+					t.Errorf("got %v and %+v", records[1:], entries)
+				}
+				return
+			}
+			if len(entries) != 1 {
+				t.Fatalf("got %+v, want one entry", entries)
+			}
+			got := entries[0]
+			if got.Type != tt.entry.Type || got.Field != tt.entry.Field || got.RawValue != tt.entry.RawValue || (tt.entry.Value != "" && got.Value != tt.entry.Value) {
+				t.Errorf("got %+v, want %+v", got, tt.entry)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		field int
+		value string
+		want  error
+	}{
+		{"clean", -1, "", nil},
+		{"bad timestamp", 0, "not a time", ErrTimestamp},
+		{"bad duration", 4, "1:xx", ErrDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t)
+			fields := strings.Split(strings.TrimSuffix(testRow, "\n"), ",")
+			if tt.field >= 0 {
+				fields[tt.field] = tt.value
+			}
+			r, err := RecordFromFields(fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Extra = map[string]string{"kept": "as is"}
+			before := *r
+
+			err = r.Validate(cfg)
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+			if r.Timestamp != before.Timestamp || r.FullName != before.FullName || r.FooDuration != before.FooDuration {
+				t.Errorf("Validate changed the record to %+v", r)
+			}
+			if len(r.Extra) != 1 || r.Extra["kept"] != "as is" {
+				t.Errorf("Validate changed Extra to %v", r.Extra)
+			}
+
+			// And it agrees with Normalize
+			if nerr := r.Normalize(cfg); (nerr == nil) != (err == nil) || (err != nil && nerr.Error() != err.Error()) {
+				t.Errorf("Normalize gave %v, Validate gave %v", nerr, err)
+			}
+		})
+	}
+}
+
+func TestRecordFromFieldsWidth(t *testing.T) {
+	if _, err := RecordFromFields([]string{"a", "b"}); err == nil {
+		t.Error("expected an error for two fields")
+	}
+}
+
+func TestNullTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		notes string
+		want  string
+	}{
+		{"off", nil, `\N`, `\N`},
+		{"backslash N", []string{"-null-tokens", `\N,NULL`}, `\N`, ""},
+		{"NULL", []string{"-null-tokens", `\N,NULL`}, "NULL", ""},
+		// Exactly, case and spaces included
+		{"other case", []string{"-null-tokens", `\N,NULL`}, "null", "null"},
+		{"spaces", []string{"-null-tokens", `\N,NULL`}, " NULL", " NULL"},
+		{"part of a value", []string{"-null-tokens", `\N,NULL`}, "NULL island", "NULL island"},
+		// Emptied before -replace sees it
+		{"before -replace", []string{"-null-tokens", "NULL", "-replace", "Notes:/^$/=none"}, "NULL", "none"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := strings.Replace(testRow, "notes", tt.notes, 1)
+			records := outputRecords(t, testConfig(t, tt.args...), testHeader+row)
+			if len(records) != 2 || records[1][7] != tt.want {
+				t.Errorf("got %q, want Notes %q", records, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Replacement is one -replace rule: swap Find, or matches of Pattern if
+// there is one, for With everywhere they turn up in Column
+type Replacement struct {
+	Column  string
+	Find    string
+	Pattern *regexp.Regexp
+	With    string
+}
+
+// parseReplacement parses Column:/regex/=replacement or Column:text=replacement.
+// A / in the regex is written \/, and the replacement can use $1 and so on
+// for the regex's groups. Literal text can't have = in it, since that's where
+// the replacement starts, but the replacement can
+func parseReplacement(s string) (Replacement, error) {
+	colon := strings.Index(s, ":")
+	if colon < 1 {
+		return Replacement{}, fmt.Errorf("expected Column:/regex/=replacement or Column:text=replacement, got %q", s)
+	}
+	column, rule := s[:colon], s[colon+1:]
+	if columnIndex(column) < 0 {
+		return Replacement{}, fmt.Errorf("unknown column %q", column)
+	}
+	column = canonicalHeaders[columnIndex(column)]
+
+	if !strings.HasPrefix(rule, "/") {
+		eq := strings.Index(rule, "=")
+		if eq < 1 {
+			return Replacement{}, fmt.Errorf("expected Column:text=replacement, got %q", s)
+		}
+		return Replacement{Column: column, Find: rule[:eq], With: rule[eq+1:]}, nil
+	}
+
+	// Find the / that ends the regex, skipping escaped ones
+	end := -1
+	for i := 1; i < len(rule); i++ {
+		if rule[i] == '\\' {
+			i++
+		} else if rule[i] == '/' {
+			end = i
+			break
+		}
+	}
+	if end < 0 || end+1 == len(rule) || rule[end+1] != '=' {
+		return Replacement{}, fmt.Errorf("expected Column:/regex/=replacement, got %q", s)
+	}
+	pattern, err := regexp.Compile(strings.ReplaceAll(rule[1:end], `\/`, "/"))
+	if err != nil {
+		return Replacement{}, err
+	}
+	return Replacement{Column: column, Pattern: pattern, With: rule[end+2:]}, nil
+}
+
+func (r Replacement) replace(s string) string {
+	if r.Pattern != nil {
+		return r.Pattern.ReplaceAllString(s, r.With)
+	}
+	return strings.ReplaceAll(s, r.Find, r.With)
+}
+
+// String is the rule the way it was written
+func (r Replacement) String() string {
+	if r.Pattern != nil {
+		return r.Column + ":/" + strings.ReplaceAll(r.Pattern.String(), "/", `\/`) + "/=" + r.With
+	}
+	return r.Column + ":" + r.Find + "=" + r.With
+}
+
+// replacementList is the flag.Value behind -replace, which can be repeated
+type replacementList []Replacement
+
+func (l *replacementList) String() string {
+	var rules []string
+	for _, r := range *l {
+		rules = append(rules, r.String())
+	}
+	return strings.Join(rules, " ")
+}
+
+func (l *replacementList) Set(s string) error {
+	r, err := parseReplacement(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, r)
+	return nil
+}
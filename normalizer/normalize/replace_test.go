@@ -0,0 +1,84 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseReplacement(t *testing.T) {
+	tests := []struct {
+		rule    string
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{`Notes:/^N\/A$/=`, "N/A", "", false},
+		{`Notes:/^N\/A$/=`, "N/A or not", "N/A or not", false},
+		{`notes:/(\d+) min/=${1}m`, "took 5 min", "took 5m", false},
+		{`Address:St.=Street`, "1 Elm St.", "1 Elm Street", false},
+		// Only the first = splits literal text from its replacement
+		{`Notes:a=b=c`, "a", "b=c", false},
+		{`Notes:/a/=`, "banana", "bnn", false},
+		{`Nope:a=b`, "", "", true},
+		{`Notes`, "", "", true},
+		{`:a=b`, "", "", true},
+		{`Notes:=b`, "", "", true},
+		{`Notes:/a=b`, "", "", true},
+		{`Notes:/a/b`, "", "", true},
+		{`Notes:/(/=`, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := parseReplacement(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %v, want an error", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.replace(tt.in); got != tt.want {
+				t.Errorf("replace(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			// It writes itself back out the way it was given, bar the
+			// column's case
+			if got := r.String(); !strings.EqualFold(got, tt.rule) {
+				t.Errorf("String() = %q, want %q", got, tt.rule)
+			}
+		})
+	}
+}
+
+func TestReplaceFlag(t *testing.T) {
+	row := strings.Replace(testRow, "1:23:32.123", "1h23:32.123", 1)
+	row = strings.Replace(row, "notes", "N/A", 1)
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"none", nil, ""},
+		// Fixed before the duration's parsed
+		{"fixes a value", []string{"-replace", "FooDuration:h=:", "-replace", `Notes:/^N\/A$/=`},
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,"},
+		// In the order given
+		{"in order", []string{"-replace", "FooDuration:h=:", "-replace", "Notes:N/A=x", "-replace", "Notes:x=y"},
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outputLines(t, testConfig(t, tt.args...), testHeader+row)
+			if tt.want == "" {
+				if len(got) != 1 {
+					t.Errorf("got %q, want the row rejected", got)
+				}
+				return
+			}
+			if len(got) != 2 || got[1] != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package normalize
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ReportEntry describes one rejected row in the -error-report file
+type ReportEntry struct {
+	// Only set when reading more than one -input
+	File  string `json:"file,omitempty"`
+	Line  int    `json:"line"`
+	Type  string `json:"type"`
+	Field string `json:"field"`
+	Value string `json:"value"`
+	// When the field wasn't valid UTF-8, what it really was before repair,
+	// with Go string escapes so the bad bytes show as \xff and so on. JSON
+	// can't carry them as they are
+	RawValue string `json:"raw_value,omitempty"`
+	Message  string `json:"message"`
+}
+
+func newReportEntry(line int, err error) ReportEntry {
+	entry := ReportEntry{
+		Line:    line,
+		Type:    errorType(err),
+		Message: err.Error(),
+	}
+	var fieldErr *FieldError
+	if errors.As(err, &fieldErr) {
+		entry.Field = fieldErr.Field
+		entry.Value = fieldErr.Value
+	}
+	return entry
+}
+
+// addRaw fills in RawValue from the record the error was about, if the field
+// needed UTF-8 repair. record can be nil, for rows that never got that far
+func (e *ReportEntry) addRaw(record *Record) {
+	if record == nil {
+		return
+	}
+	if i := columnIndex(e.Field); i >= 0 && !utf8.ValidString(record.original[i]) {
+		quoted := strconv.Quote(record.original[i])
+		e.RawValue = quoted[1 : len(quoted)-1]
+	}
+}
+
+// writeErrorReport writes entries to path as a JSON array. We always write an
+// array, even an empty one, so consumers don't have to special-case a clean run
+func writeErrorReport(path string, entries []ReportEntry) error {
+	if entries == nil {
+		entries = []ReportEntry{}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(entries); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// errorStream writes each rejected row to -error-stream as soon as it's
+// rejected, as one line of JSON, for a supervisor that wants them kept apart
+// from the messages on stderr. Each goes out in a single write, so whatever's
+// reading never sees half of one
+type errorStream struct {
+	f *os.File
+	// Set once a write's failed, after which we stop trying
+	failed bool
+}
+
+func openErrorStream(path string) (*errorStream, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open -error-stream: %w", err)
+	}
+	return &errorStream{f: f}, nil
+}
+
+func (s *errorStream) Write(entry ReportEntry) {
+	if s.failed {
+		return
+	}
+	encoded, err := json.Marshal(entry)
+	if err == nil {
+		_, err = s.f.Write(append(encoded, '\n'))
+	}
+	if err != nil {
+		// Not worth failing the run over
+		fmt.Fprintln(os.Stderr, "unable to write -error-stream: ", err.Error())
+		s.failed = true
+	}
+}
+
+func (s *errorStream) Close() {
+	if err := s.f.Close(); err != nil && !s.failed {
+		fmt.Fprintln(os.Stderr, "unable to write -error-stream: ", err.Error())
+	}
+}
+
+// summaryExamples is how many line numbers -pretty-errors gives for each group
+const summaryExamples = 5
+
+// errorSummary groups rejected rows by field and kind of error, for
+// -pretty-errors. It only keeps a few line numbers per group, so it stays
+// small however many rows fail
+type errorSummary struct {
+	groups map[string]*errorGroup
+	// Group keys in the order we first saw them, so ties print the same way
+	// every time
+	order []string
+}
+
+type errorGroup struct {
+	field string
+	kind  string
+	count int
+	lines []string
+}
+
+func newErrorSummary() *errorSummary {
+	return &errorSummary{groups: make(map[string]*errorGroup)}
+}
+
+func (s *errorSummary) Add(entry ReportEntry) {
+	key := entry.Field + "\x00" + entry.Type
+	group, ok := s.groups[key]
+	if !ok {
+		group = &errorGroup{field: entry.Field, kind: entry.Type}
+		s.groups[key] = group
+		s.order = append(s.order, key)
+	}
+	group.count++
+	if len(group.lines) < summaryExamples {
+		line := strconv.Itoa(entry.Line)
+		if entry.File != "" {
+			line = entry.File + ":" + line
+		}
+		group.lines = append(group.lines, line)
+	}
+}
+
+// Print writes one line per group, biggest first, like
+// FooDuration duration errors: 42 (lines 13, 88, 120, 121, 140, ...)
+func (s *errorSummary) Print(w io.Writer) {
+	if len(s.order) == 0 {
+		fmt.Fprintln(w, "Errors: none")
+		return
+	}
+	groups := make([]*errorGroup, len(s.order))
+	for i, key := range s.order {
+		groups[i] = s.groups[key]
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].count > groups[j].count })
+	for _, g := range groups {
+		name := g.kind
+		if g.field != "" {
+			name = g.field + " " + g.kind
+		}
+		lines := strings.Join(g.lines, ", ")
+		if g.count > len(g.lines) {
+			lines += ", ..."
+		}
+		word := "lines"
+		if len(g.lines) == 1 {
+			word = "line"
+		}
+		fmt.Fprintf(w, "%s errors: %d (%s %s)\n", name, g.count, word, lines)
+	}
+}
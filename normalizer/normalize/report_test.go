@@ -0,0 +1,259 @@
+package normalize
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readErrorReport runs in with -error-report and returns what it wrote there
+func readErrorReport(t *testing.T, in string, args ...string) []ReportEntry {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.json")
+	cfg := testConfig(t, append(args, "-error-report", path)...)
+	if _, err := runTest(t, cfg, in); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []ReportEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("report isn't a JSON array of entries: %v\n%s", err, data)
+	}
+	if entries == nil {
+		t.Fatalf("report is %s, want an array", data)
+	}
+	return entries
+}
+
+func TestErrorReport(t *testing.T) {
+	badTimestamp := strings.Replace(testRow, "4/1/11 11:00:00 AM", "yesterday", 1)
+	badDuration := strings.Replace(testRow, "1:23:32.123", "soon", 1)
+	badBoth := strings.Replace(badDuration, "1:32:33.123", "later", 1)
+	tests := []struct {
+		name string
+		in   string
+		want []ReportEntry
+	}{
+		{
+			name: "clean",
+			in:   testHeader + testRow,
+			want: []ReportEntry{},
+		},
+		{
+			name: "failures",
+			in:   testHeader + testRow + badTimestamp + badDuration + badBoth,
+			want: []ReportEntry{
+				{Line: 3, Type: "timestamp", Field: "Timestamp", Value: "yesterday"},
+				{Line: 4, Type: "duration", Field: "FooDuration", Value: "soon"},
+				// Only the first bad field in a row is reported
+				{Line: 5, Type: "duration", Field: "FooDuration", Value: "soon"},
+			},
+		},
+		{
+			name: "wrong width",
+			in:   testHeader + "a,b\n" + testRow + strings.TrimSuffix(testRow, "\n") + ",extra\n" + badTimestamp,
+			want: []ReportEntry{
+				{Line: 2, Type: "field_count", Field: "row", Value: "a,b"},
+				{Line: 4, Type: "field_count", Field: "row", Value: strings.TrimSuffix(testRow, "\n") + ",extra"},
+				// and the run carried on past them
+				{Line: 5, Type: "timestamp", Field: "Timestamp", Value: "yesterday"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := readErrorReport(t, test.in)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %d entries, want %d: %+v", len(got), len(test.want), got)
+			}
+			for i, want := range test.want {
+				g := got[i]
+				if g.Line != want.Line || g.Type != want.Type || g.Field != want.Field || g.Value != want.Value {
+					t.Errorf("entry %d = %+v, want %+v", i, g, want)
+				}
+				if g.Message == "" {
+					t.Errorf("entry %d has no message", i)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorReportShape(t *testing.T) {
+	// Consumers go by the key names, so they can't change
+	entry := newReportEntry(3, &FieldError{Field: "ZIP", Value: "x", Err: ErrZip})
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"line":3,"type":"zip","field":"ZIP","value":"x","message":"bad format for zip in ZIP: \"x\""}`
+	if string(data) != want {
+		t.Errorf("got %s, want %s", data, want)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&FieldError{Field: "Timestamp", Err: ErrTimestamp}, "timestamp"},
+		{fmt.Errorf("%w: 1h", ErrDuration), "duration"},
+		{&FieldError{Field: "ZIP", Err: ErrZipNotAllowed}, "zip_allowlist"},
+		{fmt.Errorf("%w: expected 8, got 2", ErrFieldCount), "field_count"},
+		{errors.New("something else"), "unknown"},
+	}
+	for _, test := range tests {
+		got := errorType(test.err)
+		if got != test.want {
+			t.Errorf("errorType(%v) = %q, want %q", test.err, got, test.want)
+		}
+		found := false
+		for _, name := range errorTypes {
+			found = found || name == got
+		}
+		if !found {
+			t.Errorf("%q isn't in errorTypes", got)
+		}
+	}
+}
+
+func TestErrorSummary(t *testing.T) {
+	entry := func(file string, line int, field, kind string) ReportEntry {
+		return ReportEntry{File: file, Line: line, Field: field, Type: kind}
+	}
+	tests := []struct {
+		name    string
+		entries []ReportEntry
+		want    string
+	}{
+		{"none", nil, "Errors: none\n"},
+		{"one", []ReportEntry{entry("", 3, "ZIP", "zip")}, "ZIP zip errors: 1 (line 3)\n"},
+		{
+			"biggest first",
+			[]ReportEntry{
+				entry("", 2, "ZIP", "zip"),
+				entry("", 3, "FooDuration", "duration"),
+				entry("", 4, "FooDuration", "duration"),
+			},
+			"FooDuration duration errors: 2 (lines 3, 4)\nZIP zip errors: 1 (line 2)\n",
+		},
+		{
+			"ties in the order seen",
+			[]ReportEntry{entry("", 2, "ZIP", "zip"), entry("", 3, "Timestamp", "timestamp")},
+			"ZIP zip errors: 1 (line 2)\nTimestamp timestamp errors: 1 (line 3)\n",
+		},
+		{
+			"more than the examples",
+			[]ReportEntry{
+				entry("", 1, "", "field_count"), entry("", 2, "", "field_count"),
+				entry("", 3, "", "field_count"), entry("", 4, "", "field_count"),
+				entry("", 5, "", "field_count"), entry("", 6, "", "field_count"),
+			},
+			"field_count errors: 6 (lines 1, 2, 3, 4, 5, ...)\n",
+		},
+		{"with files", []ReportEntry{entry("a.csv", 7, "ZIP", "zip")}, "ZIP zip errors: 1 (line a.csv:7)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := newErrorSummary()
+			for _, e := range tt.entries {
+				summary.Add(e)
+			}
+			var out bytes.Buffer
+			summary.Print(&out)
+			if out.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrettyErrorsFlag(t *testing.T) {
+	bad := strings.Replace(testRow, "1:23:32.123", "nope", 1)
+	_, stderr, status := runMain(t, testHeader+testRow+bad, "-pretty-errors")
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if !strings.Contains(stderr, "FooDuration duration errors: 1 (line 3)\n") {
+		t.Errorf("got stderr\n%s", stderr)
+	}
+
+	// A row that's the wrong width is just another rejected row
+	_, stderr, status = runMain(t, testHeader+"a,b\n"+bad+testRow, "-pretty-errors")
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	for _, want := range []string{"row field_count errors: 1 (line 2)\n", "FooDuration duration errors: 1 (line 3)\n"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("got stderr\n%s\nwant it to contain %q", stderr, want)
+		}
+	}
+}
+
+// peekingReader runs peek when it's read, and then ends the input, for
+// seeing what a run has done part way through
+type peekingReader struct {
+	peek func()
+}
+
+func (p peekingReader) Read([]byte) (int, error) {
+	p.peek()
+	return 0, io.EOF
+}
+
+func TestErrorStream(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.ndjson")
+	bad := strings.Replace(testRow, "1:23:32.123", "nope", 1)
+	badZip := strings.Replace(testRow, "94121", "9412x", 1)
+
+	// The first one's there before the input has even ended
+	var partWay string
+	in := io.MultiReader(
+		strings.NewReader(testHeader+bad+testRow+"a,b\n"),
+		peekingReader{func() {
+			data, _ := ioutil.ReadFile(path)
+			partWay = string(data)
+		}},
+		strings.NewReader(badZip),
+	)
+	cfg := testConfig(t, "-error-stream", path, "-zip-mode", "strip")
+	var out strings.Builder
+	if err := transform(cfg, in, &out, nil); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(partWay, "\n") != 2 {
+		t.Errorf("part way through, got %q, want the first errors already written", partWay)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	want := []struct {
+		line int
+		typ  string
+	}{{2, "duration"}, {4, "field_count"}, {5, "zip"}}
+	if len(lines) != len(want) {
+		t.Fatalf("got %q, want %d lines", lines, len(want))
+	}
+	for i, line := range lines {
+		var entry ReportEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		if entry.Line != want[i].line || entry.Type != want[i].typ {
+			t.Errorf("got %+v, want line %d with type %s", entry, want[i].line, want[i].typ)
+		}
+	}
+}
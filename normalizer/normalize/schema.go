@@ -0,0 +1,127 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The columns we know how to normalize, in the order Record stores them. These
+// are also the names we write in the output header, regardless of how the
+// input happened to spell them
+var canonicalHeaders = []string{
+	"Timestamp",
+	"Address",
+	"ZIP",
+	"FullName",
+	"FooDuration",
+	"BarDuration",
+	"TotalDuration",
+	"Notes",
+}
+
+// The extra columns -split-name adds
+const (
+	firstNameColumn = "FirstName"
+	lastNameColumn  = "LastName"
+)
+
+// outputHeaders is canonicalHeaders in the order of a Record's order field
+func outputHeaders(order []int) []string {
+	if order == nil {
+		return canonicalHeaders
+	}
+	headers := make([]string, len(order))
+	for i, c := range order {
+		headers[i] = canonicalHeaders[c]
+	}
+	return headers
+}
+
+// columnIndex finds a canonical column by name, ignoring case. It's for
+// naming columns on the command line, so -1 means there's no such column
+func columnIndex(name string) int {
+	for i, h := range canonicalHeaders {
+		if headerKey(h, true) == headerKey(name, true) {
+			return i
+		}
+	}
+	return -1
+}
+
+// headerKey is what we actually compare when matching an input header against
+// a canonical one. Surrounding whitespace never matters, case only matters if
+// we've been asked to be strict about it
+func headerKey(name string, caseInsensitive bool) string {
+	name = strings.TrimSpace(name)
+	if caseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// mapHeaders works out which input column feeds each canonical column. The
+// returned slice is indexed like canonicalHeaders and holds the position of
+// that column in the input, so vendors can send their columns in any order
+func mapHeaders(headers []string, caseInsensitive bool) ([]int, error) {
+	positions := make(map[string]int, len(headers))
+	for i, h := range headers {
+		key := headerKey(h, caseInsensitive)
+		if _, dup := positions[key]; dup {
+			return nil, fmt.Errorf("duplicate column %q in header", h)
+		}
+		positions[key] = i
+	}
+
+	mapping := make([]int, len(canonicalHeaders))
+	for i, name := range canonicalHeaders {
+		pos, ok := positions[headerKey(name, caseInsensitive)]
+		if !ok {
+			return nil, fmt.Errorf("missing column %q in header", name)
+		}
+		mapping[i] = pos
+	}
+	return mapping, nil
+}
+
+// findColumn finds a single named column in headers, matching the same way
+// mapHeaders does
+func findColumn(headers []string, name string, caseInsensitive bool) (int, error) {
+	want := headerKey(name, caseInsensitive)
+	for i, h := range headers {
+		if headerKey(h, caseInsensitive) == want {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("missing column %q in header", name)
+}
+
+// fieldCountField is what a FieldError about a row's width names as the field
+const fieldCountField = "row"
+
+// fitRow makes fields exactly width wide if we've been told we can, or
+// returns a FieldError wrapping ErrFieldCount if it isn't and we can't. delimiter is the
+// input's, for putting Notes back together
+func fitRow(fields []string, width int, delimiter rune, cfg *Config) ([]string, error) {
+	switch {
+	case len(fields) == width+1 && fields[width] == "" && cfg.TolerateTrailingComma:
+		// Just a stray delimiter at the end of the line
+		return fields[:width], nil
+	case len(fields) > width && cfg.MergeTrailingIntoNotes:
+		// Notes is the last column, so everything from there on is Notes
+		// that should have been quoted
+		merged := strings.Join(fields[width-1:], string(delimiter))
+		return append(fields[:width-1:width-1], merged), nil
+	case len(fields) < width && cfg.PadShortRows:
+		return append(fields, make([]string, width-len(fields))...), nil
+	case len(fields) > width && cfg.TruncateLongRows:
+		return fields[:width], nil
+	case len(fields) != width:
+		// It's the whole row that's wrong, so that's what the report gets
+		return fields, &FieldError{
+			Field: fieldCountField,
+			Value: strings.Join(fields, string(delimiter)),
+			Err:   fmt.Errorf("%w: expected %d, got %d", ErrFieldCount, width, len(fields)),
+		}
+	}
+	return fields, nil
+}
@@ -0,0 +1,278 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMapHeaders(t *testing.T) {
+	tests := []struct {
+		name            string
+		headers         string
+		caseInsensitive bool
+		want            []int
+		err             string
+	}{
+		{
+			name:    "canonical",
+			headers: "Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes",
+			want:    []int{0, 1, 2, 3, 4, 5, 6, 7},
+		},
+		{
+			name:            "mixed case",
+			headers:         "timestamp,ADDRESS,Zip,fullname,fooduration,BarDURATION,totalDuration,notes",
+			caseInsensitive: true,
+			want:            []int{0, 1, 2, 3, 4, 5, 6, 7},
+		},
+		{
+			name:    "mixed case, strict",
+			headers: "timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes",
+			err:     `missing column "Timestamp"`,
+		},
+		{
+			name:            "reordered with spaces",
+			headers:         " Notes ,ZIP,Timestamp,Address,FullName,FooDuration,BarDuration,TotalDuration",
+			caseInsensitive: true,
+			want:            []int{2, 3, 1, 4, 5, 6, 7, 0},
+		},
+		{
+			name:            "extra column",
+			headers:         "Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes,Email",
+			caseInsensitive: true,
+			want:            []int{0, 1, 2, 3, 4, 5, 6, 7},
+		},
+		{
+			name:            "duplicate ignoring case",
+			headers:         "Timestamp,Address,ZIP,zip,FullName,FooDuration,BarDuration,TotalDuration,Notes",
+			caseInsensitive: true,
+			err:             `duplicate column "zip"`,
+		},
+		{
+			name:    "different case isn't a duplicate when strict",
+			headers: "Timestamp,Address,ZIP,zip,FullName,FooDuration,BarDuration,TotalDuration,Notes",
+			want:    []int{0, 1, 2, 4, 5, 6, 7, 8},
+		},
+		{
+			name:            "missing",
+			headers:         "Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,Notes",
+			caseInsensitive: true,
+			err:             `missing column "TotalDuration"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := mapHeaders(strings.Split(test.headers, ","), test.caseInsensitive)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("got %v, %v, want an error like %q", got, err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range test.want {
+				if got[i] != test.want[i] {
+					t.Fatalf("got %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"ZIP", 2},
+		{"zip", 2},
+		{" Notes ", 7},
+		{"totalduration", 6},
+		{"Email", -1},
+	}
+	for _, test := range tests {
+		if got := columnIndex(test.name); got != test.want {
+			t.Errorf("columnIndex(%q) = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestMixedCaseHeaderOutput(t *testing.T) {
+	// Whatever the input calls them, the output header is the canonical one
+	in := "timestamp,address,zip,fullname,fooduration,barduration,totalduration,notes\n" + testRow
+	lines := outputLines(t, testConfig(t), in)
+	if lines[0]+"\n" != testHeader {
+		t.Errorf("header = %q, want %q", lines[0], testHeader)
+	}
+	if len(lines) != 2 {
+		t.Errorf("got %d lines, want 2:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+
+	_, err := runTest(t, testConfig(t, "-case-insensitive-headers=false"), in)
+	if err == nil || !strings.Contains(err.Error(), "missing column") {
+		t.Errorf("strict headers: got %v, want a missing column error", err)
+	}
+}
+
+func TestFitRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		fields  string
+		want    string
+		wantErr bool
+	}{
+		{"exact", nil, "a,b,c", "a,b,c", false},
+		{"short", nil, "a,b", "", true},
+		{"long", nil, "a,b,c,d", "", true},
+		{"short, padded", []string{"-pad-short-rows"}, "a", "a,,", false},
+		{"long, truncated", []string{"-truncate-long-rows"}, "a,b,c,d,e", "a,b,c", false},
+		{"long, only padding", []string{"-pad-short-rows"}, "a,b,c,d", "", true},
+		{"short, only truncating", []string{"-truncate-long-rows"}, "a,b", "", true},
+		{"trailing comma", []string{"-tolerate-trailing-comma"}, "a,b,c,", "a,b,c", false},
+		{"trailing comma, not empty", []string{"-tolerate-trailing-comma"}, "a,b,c,d", "", true},
+		{"trailing comma, two extra", []string{"-tolerate-trailing-comma"}, "a,b,c,,", "", true},
+		{"trailing comma, padded", []string{"-tolerate-trailing-comma", "-pad-short-rows"}, "a,b", "a,b,", false},
+		{"trailing comma, off", nil, "a,b,c,", "", true},
+		{"merge into notes", []string{"-merge-trailing-into-notes"}, "a,b,c,d,e", "a,b,c,d,e", false},
+		{"merge into notes, short", []string{"-merge-trailing-into-notes"}, "a,b", "", true},
+		{"merge after trailing comma", []string{"-merge-trailing-into-notes", "-tolerate-trailing-comma"}, "a,b,c,", "a,b,c", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := fitRow(strings.Split(tt.fields, ","), 3, ',', testConfig(t, tt.args...))
+			if tt.wantErr {
+				if !errors.Is(err, ErrFieldCount) {
+					t.Errorf("got %q, %v, want ErrFieldCount", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("got %q, want %q", strings.Join(got, ","), tt.want)
+			}
+		})
+	}
+}
+
+func TestPadShortRows(t *testing.T) {
+	short := "4/1/11 11:00:00 AM,123 4th St,94121,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa\n"
+	records := outputRecords(t, testConfig(t, "-pad-short-rows"), testHeader+short)
+	if len(records) != 2 || records[1][7] != "" {
+		t.Errorf("got %q, want the row with an empty Notes", records)
+	}
+}
+
+func TestTolerateTrailingComma(t *testing.T) {
+	trailing := strings.TrimSuffix(testRow, "\n") + ",\n"
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{"rows only", testHeader + trailing},
+		// The header's extra empty column is ignored anyway
+		{"header too", strings.TrimSuffix(testHeader, "\n") + ",\n" + trailing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := outputRecords(t, testConfig(t, "-tolerate-trailing-comma"), tt.in)
+			if len(records) != 2 || len(records[1]) != 8 || records[1][7] != "notes" {
+				t.Errorf("got %q, want the row read as normal", records)
+			}
+		})
+	}
+}
+
+func TestMergeTrailingIntoNotes(t *testing.T) {
+	unquoted := strings.TrimSuffix(testRow, "notes\n") + "Hello, world,again\n"
+	tests := []struct {
+		name  string
+		args  []string
+		in    string
+		notes string
+		err   string
+	}{
+		{"merged", []string{"-merge-trailing-into-notes"}, testHeader + unquoted, "Hello, world,again", ""},
+		{"quoted is left alone", []string{"-merge-trailing-into-notes"}, testHeader + testRow, "notes", ""},
+		{"notes not last", []string{"-merge-trailing-into-notes"},
+			"Notes,Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration\n", "", "needs Notes to be the last column"},
+		{"with truncating", []string{"-merge-trailing-into-notes", "-truncate-long-rows"}, "", "", "can't be used together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := parseTestFlags(t, tt.args...)
+			err := cfg.Check()
+			if err == nil {
+				var records [][]string
+				if tt.err == "" {
+					records = outputRecords(t, cfg, tt.in)
+				} else {
+					_, err = runTest(t, cfg, tt.in)
+				}
+				if tt.err == "" && (len(records) != 2 || records[1][7] != tt.notes) {
+					t.Errorf("got %q, want Notes %q", records, tt.notes)
+				}
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestOutputHeaders(t *testing.T) {
+	if got := outputHeaders(nil); strings.Join(got, ",") != strings.Join(canonicalHeaders, ",") {
+		t.Errorf("outputHeaders(nil) = %q", got)
+	}
+	if got := outputHeaders([]int{7, 0}); strings.Join(got, ",") != "Notes,Timestamp" {
+		t.Errorf("outputHeaders([7 0]) = %q", got)
+	}
+}
+
+func TestPreserveInputOrder(t *testing.T) {
+	in := "notes,ZIP,Unknown,Timestamp,Address,FullName,FooDuration,BarDuration,TotalDuration\n" +
+		"hi,94121,dropped,4/1/11 11:00:00 AM,123 4th St,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa\n"
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"canonical", nil, []string{
+			"Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes",
+			"2011-04-01T14:00:00-04:00,123 4th St,94121,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,hi",
+		}},
+		{"preserved", []string{"-preserve-input-order"}, []string{
+			"Notes,ZIP,Timestamp,Address,FullName,FooDuration,BarDuration,TotalDuration",
+			"hi,94121,2011-04-01T14:00:00-04:00,123 4th St,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000",
+		}},
+		// Extra columns still go on the end
+		{"with extras", []string{"-preserve-input-order", "-add-percent-columns"}, []string{
+			"Notes,ZIP,Timestamp,Address,FullName,FooDuration,BarDuration,TotalDuration,FooPercent,BarPercent",
+			"hi,94121,2011-04-01T14:00:00-04:00,123 4th St,MONKEY ALBERTO,5012.123000,5553.123000,10565.246000,47.44,52.56",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := outputLines(t, testConfig(t, tt.args...), in)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestPreserveInputOrderJSON(t *testing.T) {
+	in := "Notes,Timestamp,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration\n" +
+		"hi,4/1/11 11:00:00 AM,123 4th St,94121,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa\n"
+	out, err := runTest(t, testConfig(t, "-preserve-input-order", "-output-format", "ndjson"), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, `{"Notes":"hi","Timestamp":`) {
+		t.Errorf("got %s, want Notes first", out)
+	}
+}
@@ -0,0 +1,90 @@
+package normalize
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// separatorTranslator turns every -record-separator in its input into a
+// newline, for feeds that end records with something else, like \x1e, which
+// encoding/csv can't be told about. It doesn't know about quoting, so a
+// separator inside a quoted field becomes a newline in that field's value,
+// and newlines that were already in the input still end records wherever
+// they aren't quoted
+type separatorTranslator struct {
+	src       *bufio.Reader
+	separator rune
+	pending   bytes.Buffer
+}
+
+func newSeparatorTranslator(r io.Reader, separator rune) *separatorTranslator {
+	return &separatorTranslator{src: bufio.NewReader(r), separator: separator}
+}
+
+func (t *separatorTranslator) Read(p []byte) (int, error) {
+	for t.pending.Len() < len(p) {
+		// Give back what we have rather than wait on the source for the
+		// rest, or -interactive and -follow would sit on a line that's
+		// already arrived
+		if t.pending.Len() > 0 && t.src.Buffered() == 0 {
+			break
+		}
+		r, size, err := t.src.ReadRune()
+		if err != nil {
+			if t.pending.Len() > 0 {
+				break
+			}
+			return 0, err
+		}
+		switch {
+		case r == t.separator:
+			t.pending.WriteByte('\n')
+		case r == utf8.RuneError && size == 1:
+			// Keep invalid bytes exactly as they were, UTF-8 repair happens later
+			t.src.UnreadRune()
+			b, _ := t.src.ReadByte()
+			t.pending.WriteByte(b)
+		default:
+			t.pending.WriteRune(r)
+		}
+	}
+	return t.pending.Read(p)
+}
+
+// newlineTranslator is -canonicalize-newlines: it turns \r\n and lone \r, as
+// old Macs wrote, into \n, so a file with a mix of them still splits into
+// records in the right places. It works a byte at a time and never waits
+// for more input to decide, so a \r\n split across two reads still comes out
+// as one \n. Like separatorTranslator it doesn't know about quoting, so line
+// breaks inside quoted fields become \n too
+type newlineTranslator struct {
+	src io.Reader
+	// Whether the last byte was a \r, whose \n we'd already written
+	afterCR bool
+}
+
+func (t *newlineTranslator) Read(p []byte) (int, error) {
+	for {
+		n, err := t.src.Read(p)
+		out := 0
+		for _, b := range p[:n] {
+			switch {
+			case b == '\n' && t.afterCR:
+			case b == '\r':
+				p[out] = '\n'
+				out++
+			default:
+				p[out] = b
+				out++
+			}
+			t.afterCR = b == '\r'
+		}
+		// A read that was only the \n of a \r\n has nothing to give back,
+		// and mustn't look like it hit the end
+		if out > 0 || err != nil || n == 0 {
+			return out, err
+		}
+	}
+}
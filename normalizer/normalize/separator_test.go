@@ -0,0 +1,94 @@
+package normalize
+
+import (
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestSeparatorTranslator(t *testing.T) {
+	tests := []struct {
+		name string
+		sep  rune
+		in   string
+		want string
+	}{
+		{"record separator", '\x1e', "a,b\x1ec,d\x1e", "a,b\nc,d\n"},
+		{"newlines left alone", '\x1e', "a\nb\x1e", "a\nb\n"},
+		{"multi-byte separator", '¶', "a¶b¶", "a\nb\n"},
+		{"invalid UTF-8 kept", '\x1e', "\xff\x1e", "\xff\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ioutil.ReadAll(newSeparatorTranslator(strings.NewReader(tt.in), tt.sep))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeparatorTranslatorDoesntWait(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	readsWithoutWaiting(t, newSeparatorTranslator(pr, '\x1e'), pw, "a,b\x1e", "a,b\n")
+}
+
+func TestNewlineTranslator(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"unix", "a\nb\n", "a\nb\n"},
+		{"windows", "a\r\nb\r\n", "a\nb\n"},
+		{"old mac", "a\rb\r", "a\nb\n"},
+		{"mixed", "a\r\nb\rc\nd", "a\nb\nc\nd"},
+		{"blank lines kept", "a\r\r\nb\n\n", "a\n\nb\n\n"},
+		{"ends on a CR", "a\r", "a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, r := range []struct {
+				name string
+				r    io.Reader
+			}{
+				{"whole", strings.NewReader(tt.in)},
+				// So every \r\n is split across two reads
+				{"a byte at a time", iotest.OneByteReader(strings.NewReader(tt.in))},
+			} {
+				got, err := ioutil.ReadAll(&newlineTranslator{src: r.r})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("%s: got %q, want %q", r.name, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewlineTranslatorDoesntWait(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	readsWithoutWaiting(t, &newlineTranslator{src: pr}, pw, "a,b\r", "a,b\n")
+}
+
+func TestCanonicalizeNewlines(t *testing.T) {
+	crlf := strings.Replace(testRow, "\n", "\r\n", 1)
+	cr := strings.Replace(strings.Replace(testRow, "notes", "old mac", 1), "\n", "\r", 1)
+	in := strings.Replace(testHeader, "\n", "\r", 1) + crlf + cr + testRow
+	records := outputRecords(t, testConfig(t, "-canonicalize-newlines"), in)
+	if len(records) != 4 || records[2][7] != "old mac" {
+		t.Errorf("got %q, want the header and three rows", records)
+	}
+	if err := configError(t, "-canonicalize-newlines", "-add-offset-column"); err == nil {
+		t.Error("expected an error with -add-offset-column")
+	}
+}
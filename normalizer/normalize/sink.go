@@ -0,0 +1,179 @@
+package normalize
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Output formats for -output-format
+const (
+	outputFormatCSV    = "csv"
+	outputFormatJSON   = "json"
+	outputFormatNDJSON = "ndjson"
+)
+
+// Sink is somewhere normalized records go. Each output format is a Sink, so
+// the main loop doesn't have to care which one it's writing
+type Sink interface {
+	// WriteHeader is called at most once, before any records
+	WriteHeader(columns []string) error
+	WriteRecord(r *Record) error
+	// Flush pushes anything buffered out to the underlying writer
+	Flush() error
+	// Close finishes off the output and flushes it. It doesn't close the
+	// underlying writer, which the Sink doesn't own
+	Close() error
+}
+
+// newSink builds the Sink for cfg.OutputFormat, or the -preview table, or the
+// -diff or -compare-to report, or
+// batches for TransformBatches, or files for -split-rows and -split-by.
+// columns is the whole output header, extra columns and all
+func newSink(cfg *Config, w io.Writer, columns []string) (Sink, error) {
+	if cfg.batchEmit != nil {
+		return &batchSink{columns: columns, extra: cfg.ExtraColumns(), size: cfg.batchSize, emit: cfg.batchEmit}, nil
+	}
+	if cfg.Preview > 0 {
+		return newPreviewSink(cfg, w, columns), nil
+	}
+	if cfg.Diff {
+		return newDiffSink(w), nil
+	}
+	if cfg.CompareTo != "" {
+		return newCompareSink(cfg, w, columns)
+	}
+	if cfg.SplitRows > 0 || cfg.SplitBy != "" {
+		return newSplitSink(cfg), nil
+	}
+	return newFormatSink(cfg, w)
+}
+
+// newFormatSink builds the Sink for cfg.OutputFormat
+func newFormatSink(cfg *Config, w io.Writer) (Sink, error) {
+	switch cfg.OutputFormat {
+	case outputFormatCSV:
+		return &csvSink{writer: csv.NewWriter(w), extra: cfg.ExtraColumns()}, nil
+	case outputFormatJSON:
+		return newJSONSink(w, cfg.JSONPretty, cfg.jsonStrings()), nil
+	case outputFormatNDJSON:
+		return &ndjsonSink{w: bufio.NewWriter(w), allStrings: cfg.jsonStrings()}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", cfg.OutputFormat)
+}
+
+type csvSink struct {
+	writer *csv.Writer
+	extra  []string
+}
+
+func (s *csvSink) WriteHeader(columns []string) error {
+	return s.writer.Write(columns)
+}
+
+func (s *csvSink) WriteRecord(r *Record) error {
+	return s.writer.Write(r.Row(s.extra))
+}
+
+func (s *csvSink) Flush() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// jsonSink writes a single JSON array of records, one compact object per
+// line by default, or indented for people to read
+type jsonSink struct {
+	w          *bufio.Writer
+	buf        bytes.Buffer
+	enc        *json.Encoder
+	pretty     bool
+	allStrings bool
+	count      int
+}
+
+func newJSONSink(w io.Writer, pretty, allStrings bool) *jsonSink {
+	s := &jsonSink{w: bufio.NewWriter(w), pretty: pretty, allStrings: allStrings}
+	s.enc = json.NewEncoder(&s.buf)
+	if pretty {
+		// Objects sit one level in, inside the array
+		s.enc.SetIndent("  ", "  ")
+	}
+	return s
+}
+
+// JSON objects carry their own keys, so there's no header to write
+func (s *jsonSink) WriteHeader(columns []string) error {
+	return nil
+}
+
+func (s *jsonSink) WriteRecord(r *Record) error {
+	s.buf.Reset()
+	if err := s.enc.Encode(jsonValue(r, s.allStrings)); err != nil {
+		return err
+	}
+	// Encode ends every value with a newline, but we want the comma first
+	encoded := bytes.TrimSuffix(s.buf.Bytes(), []byte("\n"))
+
+	if s.count == 0 {
+		s.w.WriteString("[")
+	} else {
+		s.w.WriteString(",")
+	}
+	s.count++
+	s.w.WriteString("\n")
+	if s.pretty {
+		s.w.WriteString("  ")
+	}
+	_, err := s.w.Write(encoded)
+	return err
+}
+
+func (s *jsonSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *jsonSink) Close() error {
+	if s.count == 0 {
+		s.w.WriteString("[")
+	} else {
+		s.w.WriteString("\n")
+	}
+	s.w.WriteString("]\n")
+	return s.w.Flush()
+}
+
+// ndjsonSink writes one JSON object per line
+type ndjsonSink struct {
+	w          *bufio.Writer
+	allStrings bool
+}
+
+func (s *ndjsonSink) WriteHeader(columns []string) error {
+	return nil
+}
+
+func (s *ndjsonSink) WriteRecord(r *Record) error {
+	encoded, err := json.Marshal(jsonValue(r, s.allStrings))
+	if err != nil {
+		return err
+	}
+	s.w.Write(encoded)
+	_, err = s.w.WriteString("\n")
+	return err
+}
+
+func (s *ndjsonSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *ndjsonSink) Close() error {
+	return s.w.Flush()
+}
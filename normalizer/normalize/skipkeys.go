@@ -0,0 +1,67 @@
+package normalize
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// loadSkipKeys reads a -skip-keys file: CSV, one key per line, with a field
+// for each of columns in that order. A first line that just names the
+// columns is taken as a header, and lines starting with # are comments
+func loadSkipKeys(path string, columns []string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read -skip-keys: %w", err)
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = len(columns)
+	keys := make(map[string]bool)
+	first := true
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't parse -skip-keys %s: %w", path, err)
+		}
+		if first && isKeyHeader(fields, columns) {
+			first = false
+			continue
+		}
+		first = false
+		keys[joinKey(fields)] = true
+	}
+	return keys, nil
+}
+
+func isKeyHeader(fields, columns []string) bool {
+	for i, name := range columns {
+		if headerKey(fields[i], true) != headerKey(name, true) {
+			return false
+		}
+	}
+	return true
+}
+
+// joinKey makes one map key out of a composite key's values. They're joined
+// with NUL rather than a comma, which real values do have in them
+func joinKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+// skipKey is the record's -key-columns key, from its normalized values
+func (r *Record) skipKey(columns []int) string {
+	fields := r.Fields()
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = fields[c]
+	}
+	return joinKey(values)
+}
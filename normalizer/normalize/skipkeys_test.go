@@ -0,0 +1,76 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadSkipKeys(t *testing.T) {
+	columns := []string{"FullName", "ZIP"}
+	tests := []struct {
+		name    string
+		data    string
+		want    []string
+		wantErr bool
+	}{
+		{"plain", "A,1\nB,2\n", []string{"A\x001", "B\x002"}, false},
+		{"header", "fullname,zip\nA,1\n", []string{"A\x001"}, false},
+		{"header only first", "A,1\nFullName,ZIP\n", []string{"A\x001", "FullName\x00ZIP"}, false},
+		{"comments", "# known bad\nA,1\n", []string{"A\x001"}, false},
+		{"comma in value", "\"Doe, Jane\",1\n", []string{"Doe, Jane\x001"}, false},
+		{"wrong width", "A,1,extra\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := loadSkipKeys(writeTestFile(t, "keys.csv", tt.data), columns)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", keys)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != len(tt.want) {
+				t.Fatalf("got %v, want %q", keys, tt.want)
+			}
+			for _, key := range tt.want {
+				if !keys[key] {
+					t.Errorf("missing key %q in %v", key, keys)
+				}
+			}
+		})
+	}
+}
+
+func TestSkipKeysFlag(t *testing.T) {
+	second := strings.Replace(testRow, "notes", "second", 1)
+	second = strings.Replace(second, "Monkey Alberto", "Someone Else", 1)
+	in := testHeader + testRow + second
+
+	// Keys are the normalized values
+	keys := writeTestFile(t, "keys.csv", "FullName,Timestamp\nMONKEY ALBERTO,2011-04-01T14:00:00-04:00\n")
+	records := outputRecords(t, testConfig(t, "-skip-keys", keys, "-key-columns", "FullName,Timestamp"), in)
+	if len(records) != 2 || records[1][7] != "second" {
+		t.Errorf("got %q, want only the second row", records)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{"no columns", []string{"-skip-keys", keys}, "go together"},
+		{"no file", []string{"-key-columns", "FullName"}, "go together"},
+		{"unknown column", []string{"-skip-keys", keys, "-key-columns", "Nope"}, "unknown column"},
+		{"missing file", []string{"-skip-keys", keys + ".missing", "-key-columns", "FullName"}, "can't read -skip-keys"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
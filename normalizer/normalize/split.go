@@ -0,0 +1,220 @@
+package normalize
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultSplitMaxOpen is how many -split-by files can be open at once unless
+// told otherwise
+const defaultSplitMaxOpen = 64
+
+// splitSink is the Sink for -split-rows and -split-by. Instead of one output
+// it writes a file per chunk of rows, or per value of a column, each one a
+// complete output of its own with the header (if there is one) at the top
+type splitSink struct {
+	cfg    *Config
+	header []string
+
+	// The chunk being written, and how many rows are in it, for -split-rows
+	chunk     *splitPart
+	chunkRows int
+	chunks    int
+
+	// Every file so far by value, for -split-by, and the names they've
+	// taken. Only the -split-max-open most recently written to are open,
+	// most recent at the front; the others are closed until they're wanted
+	// again, and then added on to
+	parts map[string]*splitPart
+	names map[string]bool
+	open  *list.List
+}
+
+// splitPart is one of the files a splitSink writes. file and sink are nil
+// while it's closed
+type splitPart struct {
+	path string
+	file *os.File
+	sink Sink
+	// Whether it's been opened before, and so already has its header
+	started bool
+	// Where it is in splitSink.open
+	recent *list.Element
+}
+
+func newSplitSink(cfg *Config) *splitSink {
+	return &splitSink{cfg: cfg, parts: make(map[string]*splitPart), names: make(map[string]bool), open: list.New()}
+}
+
+func (s *splitSink) WriteHeader(columns []string) error {
+	// Nothing's open yet; each file gets the header as it's created
+	s.header = columns
+	return nil
+}
+
+func (s *splitSink) WriteRecord(r *Record) error {
+	var part *splitPart
+	if s.cfg.SplitRows > 0 {
+		if s.chunk == nil || s.chunkRows >= s.cfg.SplitRows {
+			if s.chunk != nil {
+				// Done with this one, so don't hold it open
+				if err := s.chunk.close(); err != nil {
+					return err
+				}
+			}
+			s.chunk = &splitPart{path: s.path(fmt.Sprintf("%03d", s.chunks))}
+			if err := s.chunk.reopen(s); err != nil {
+				return err
+			}
+			s.chunks++
+			s.chunkRows = 0
+		}
+		part = s.chunk
+		s.chunkRows++
+	} else {
+		var err error
+		part, err = s.part(splitValue(r, s.cfg.SplitBy))
+		if err != nil {
+			return err
+		}
+	}
+	return part.sink.WriteRecord(r)
+}
+
+// part is the open file for a -split-by value, closing the one written to
+// least recently if there are too many
+func (s *splitSink) part(value string) (*splitPart, error) {
+	part := s.parts[value]
+	if part == nil {
+		part = &splitPart{path: s.path(s.name(value))}
+		s.parts[value] = part
+	}
+	if part.file != nil {
+		s.open.MoveToFront(part.recent)
+		return part, nil
+	}
+	if s.open.Len() >= s.cfg.SplitMaxOpen {
+		if s.cfg.OutputFormat == outputFormatJSON {
+			// A JSON array is finished when it's closed, so there's no
+			// adding on to it later
+			return nil, fmt.Errorf("-split-by has more than -split-max-open %d values, and json files can't be closed and reopened", s.cfg.SplitMaxOpen)
+		}
+		oldest := s.open.Remove(s.open.Back()).(*splitPart)
+		if err := oldest.close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := part.reopen(s); err != nil {
+		return nil, err
+	}
+	part.recent = s.open.PushFront(part)
+	return part, nil
+}
+
+// name is the file name part for a -split-by value. Values that come out the
+// same once they're made safe for a file name, like a/b and a_b, get -2, -3
+// and so on after the first, so they never end up in the same file
+func (s *splitSink) name(value string) string {
+	base := splitKey(value)
+	name := base
+	for n := 2; s.names[name]; n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	s.names[name] = true
+	return name
+}
+
+func (s *splitSink) path(name string) string {
+	return fmt.Sprintf("%s-%s.%s", s.cfg.SplitPrefix, name, s.cfg.OutputFormat)
+}
+
+// reopen opens the part's file, new with a header the first time and added
+// on to after that
+func (p *splitPart) reopen(s *splitSink) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if p.started {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(p.path, flags, 0666)
+	if err != nil {
+		return err
+	}
+	sink, err := newFormatSink(s.cfg, f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if s.header != nil && !p.started {
+		if err := sink.WriteHeader(s.header); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	p.file, p.sink, p.started = f, sink, true
+	return nil
+}
+
+func (p *splitPart) close() error {
+	err := p.sink.Close()
+	if closeErr := p.file.Close(); err == nil {
+		err = closeErr
+	}
+	p.file, p.sink = nil, nil
+	return err
+}
+
+func (s *splitSink) Flush() error {
+	if s.chunk != nil {
+		if err := s.chunk.sink.Flush(); err != nil {
+			return err
+		}
+	}
+	for e := s.open.Front(); e != nil; e = e.Next() {
+		if err := e.Value.(*splitPart).sink.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *splitSink) Close() error {
+	var err error
+	if s.chunk != nil && s.chunk.file != nil {
+		err = s.chunk.close()
+	}
+	for e := s.open.Front(); e != nil; e = e.Next() {
+		if closeErr := e.Value.(*splitPart).close(); err == nil {
+			err = closeErr
+		}
+	}
+	s.open.Init()
+	return err
+}
+
+// unsafeFileChars are what we won't put in a file name from a -split-by value
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// splitValue is r's value of column, which is a canonical column or one of
+// the extras, without any space around it
+func splitValue(r *Record, column string) string {
+	var value string
+	if i := columnIndex(column); i >= 0 {
+		value = r.Fields()[i]
+	} else {
+		value = r.Extra[column]
+	}
+	return strings.TrimSpace(value)
+}
+
+// splitKey makes a -split-by value safe for a file name. Anything but
+// letters, digits, dots, dashes and underscores becomes an underscore, and a
+// blank value is "empty"
+func splitKey(value string) string {
+	if value == "" {
+		return "empty"
+	}
+	return unsafeFileChars.ReplaceAllString(value, "_")
+}
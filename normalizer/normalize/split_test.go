@@ -0,0 +1,146 @@
+package normalize
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// splitRow is testRow with notes in place of its Notes
+func splitRow(notes string) string {
+	return strings.TrimSuffix(testRow, "notes\n") + notes + "\n"
+}
+
+// splitFiles runs in split with args into a temporary directory, and returns
+// each file it wrote by name, with the Notes column of every row in it
+func splitFiles(t *testing.T, in string, args ...string) map[string][]string {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := testConfig(t, append(args, "-split-prefix", filepath.Join(dir, "out"))...)
+	if _, err := runTest(t, cfg, in); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]string)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if lines[0] != strings.TrimSuffix(testHeader, "\n") {
+			t.Errorf("%s starts with %q, not the header", filepath.Base(path), lines[0])
+		}
+		notes := []string{}
+		for _, line := range lines[1:] {
+			notes = append(notes, line[strings.LastIndex(line, ",")+1:])
+		}
+		files[filepath.Base(path)] = notes
+	}
+	return files
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		rows []string
+		want map[string][]string
+	}{
+		{
+			name: "rows",
+			args: []string{"-split-rows", "2"},
+			rows: []string{"1", "2", "3"},
+			want: map[string][]string{"out-000.csv": {"1", "2"}, "out-001.csv": {"3"}},
+		},
+		{
+			name: "column",
+			args: []string{"-split-by", "Notes"},
+			rows: []string{"x", "y", "x", ""},
+			want: map[string][]string{"out-x.csv": {"x", "x"}, "out-y.csv": {"y"}, "out-empty.csv": {""}},
+		},
+		{
+			name: "collision",
+			args: []string{"-split-by", "Notes"},
+			rows: []string{"a/b", "a_b", "a b", "a/b", "a_b-2"},
+			want: map[string][]string{
+				"out-a_b.csv":     {"a/b", "a/b"},
+				"out-a_b-2.csv":   {"a_b"},
+				"out-a_b-3.csv":   {"a b"},
+				"out-a_b-2-2.csv": {"a_b-2"},
+			},
+		},
+		{
+			name: "reopened",
+			args: []string{"-split-by", "Notes", "-split-max-open", "1"},
+			rows: []string{"x", "y", "x", "z", "y", "x"},
+			want: map[string][]string{"out-x.csv": {"x", "x", "x"}, "out-y.csv": {"y", "y"}, "out-z.csv": {"z"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := testHeader
+			for _, notes := range test.rows {
+				in += splitRow(notes)
+			}
+			got := splitFiles(t, in, test.args...)
+			if len(got) != len(test.want) {
+				t.Errorf("got files %v, want %v", fileNames(got), fileNames(test.want))
+			}
+			for name, want := range test.want {
+				if strings.Join(got[name], "|") != strings.Join(want, "|") {
+					t.Errorf("%s has %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitJSONTooManyValues(t *testing.T) {
+	// A write error is reported and the row skipped, like any other, so the
+	// second value never gets a file
+	dir := t.TempDir()
+	cfg := testConfig(t, "-split-by", "Notes", "-split-max-open", "1", "-output-format", "json", "-split-prefix", filepath.Join(dir, "out"))
+	if _, err := runTest(t, cfg, testHeader+splitRow("x")+splitRow("y")); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 || filepath.Base(paths[0]) != "out-x.json" {
+		t.Errorf("got files %v, want just out-x.json", paths)
+	}
+}
+
+func TestCheckSplitMaxOpen(t *testing.T) {
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{"1", true},
+		{"64", true},
+		{"0", false},
+		{"-1", false},
+	}
+	for _, test := range tests {
+		err := configError(t, "-split-by", "Notes", "-split-max-open", test.value)
+		if (err == nil) != test.ok {
+			t.Errorf("-split-max-open %s: got %v", test.value, err)
+		}
+	}
+}
+
+func fileNames(files map[string][]string) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,98 @@
+package normalize
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// durationStats collects TotalDuration values for -stats. Exact percentiles
+// need every value, so this keeps them all: 8 bytes a row, which is about 8MB
+// for a million rows. That's fine for the feeds we see, but worth knowing
+// before pointing -stats at something enormous
+type durationStats struct {
+	values []time.Duration
+	sum    time.Duration
+}
+
+func (s *durationStats) Add(d time.Duration) {
+	s.values = append(s.values, d)
+	s.sum += d
+}
+
+// percentile uses the nearest-rank method, so the result is always one of
+// the values we actually saw. values must already be sorted
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// Print writes a one line summary, in seconds
+func (s *durationStats) Print(w io.Writer) {
+	if len(s.values) == 0 {
+		fmt.Fprintln(w, "TotalDuration stats: no rows")
+		return
+	}
+	sorted := append([]time.Duration(nil), s.values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mean := s.sum / time.Duration(len(sorted))
+	fmt.Fprintf(w, "TotalDuration stats: count=%d min=%f max=%f mean=%f p50=%f p95=%f\n",
+		len(sorted),
+		sorted[0].Seconds(),
+		sorted[len(sorted)-1].Seconds(),
+		mean.Seconds(),
+		percentile(sorted, 50).Seconds(),
+		percentile(sorted, 95).Seconds(),
+	)
+}
+
+// layoutStats counts which Timestamp layout each row matched, for -stats. A
+// feed that quietly changes its format shows up here before anywhere else
+type layoutStats struct {
+	// Every layout we try, in the order we try them, so ones that never
+	// matched still get reported
+	layouts []string
+	counts  map[string]int
+}
+
+func newLayoutStats(layouts []string) *layoutStats {
+	return &layoutStats{layouts: layouts, counts: make(map[string]int)}
+}
+
+func (s *layoutStats) Add(layout string) {
+	s.counts[layout]++
+}
+
+// Go's named layouts, which read better by name
+var layoutNames = map[string]string{
+	time.RFC3339:     "RFC3339",
+	time.RFC3339Nano: "RFC3339Nano",
+	time.RFC1123:     "RFC1123",
+	time.RFC1123Z:    "RFC1123Z",
+	time.RFC822:      "RFC822",
+	time.RFC822Z:     "RFC822Z",
+	time.RFC850:      "RFC850",
+	time.ANSIC:       "ANSIC",
+	time.UnixDate:    "UnixDate",
+}
+
+// Print writes a one line summary, like
+//
+//	Timestamp layouts: layout "1/2/06 3:04:05 PM": 980, RFC3339: 20
+func (s *layoutStats) Print(w io.Writer) {
+	parts := make([]string, len(s.layouts))
+	for i, layout := range s.layouts {
+		name, ok := layoutNames[layout]
+		if !ok {
+			name = fmt.Sprintf("layout %q", layout)
+		}
+		parts[i] = fmt.Sprintf("%s: %d", name, s.counts[layout])
+	}
+	fmt.Fprintln(w, "Timestamp layouts:", strings.Join(parts, ", "))
+}
@@ -0,0 +1,104 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 1},
+		{10, 1},
+		{50, 5},
+		{51, 6},
+		{95, 10},
+		{100, 10},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("p%v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestDurationStatsPrint(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []time.Duration
+		want   string
+	}{
+		{"no rows", nil, "TotalDuration stats: no rows\n"},
+		{"one", []time.Duration{time.Second}, "TotalDuration stats: count=1 min=1.000000 max=1.000000 mean=1.000000 p50=1.000000 p95=1.000000\n"},
+		{
+			"out of order",
+			[]time.Duration{4 * time.Second, time.Second, 3 * time.Second, 2 * time.Second},
+			"TotalDuration stats: count=4 min=1.000000 max=4.000000 mean=2.500000 p50=2.000000 p95=4.000000\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s durationStats
+			for _, d := range tt.values {
+				s.Add(d)
+			}
+			var out strings.Builder
+			s.Print(&out)
+			if out.String() != tt.want {
+				t.Errorf("got %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestampLayout(t *testing.T) {
+	cfg := testConfig(t, "-timestamp-layout", time.RFC3339, "-timestamp-layout", "1/2/06 3:04:05 PM")
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2011-04-01T11:00:00-07:00", time.RFC3339},
+		{"4/1/11 11:00:00 AM", "1/2/06 3:04:05 PM"},
+		{"yesterday", ""},
+	}
+	for _, tt := range tests {
+		if _, got, _ := parseTimestampLayout(tt.in, cfg); got != tt.want {
+			t.Errorf("%q matched %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLayoutStatsPrint(t *testing.T) {
+	s := newLayoutStats([]string{"1/2/06 3:04:05 PM", time.RFC3339, time.ANSIC})
+	for i := 0; i < 3; i++ {
+		s.Add("1/2/06 3:04:05 PM")
+	}
+	s.Add(time.RFC3339)
+	var out strings.Builder
+	s.Print(&out)
+	// Every layout, in the order they're tried, even the ones that never matched
+	want := "Timestamp layouts: layout \"1/2/06 3:04:05 PM\": 3, RFC3339: 1, ANSIC: 0\n"
+	if out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestStatsOutput(t *testing.T) {
+	in := testHeader + testRow + strings.Replace(testRow, "1:23:32.123", "soon", 1)
+	_, stderr, status := runMain(t, in, "-stats")
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	for _, want := range []string{
+		"TotalDuration stats: count=1 min=10565.246000",
+		`Timestamp layouts: layout "1/2/06 3:04:05 PM": 1` + "\n",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("no %q in\n%s", want, stderr)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package normalize
+
+import (
+	"fmt"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// The steps Address, FullName and Notes go through in Normalize. Each only
+// does anything if its flags turn it on, and for the columns it's about
+const (
+	// -replace rules for the column
+	stepReplace = "replace"
+	// -unicode-normalize
+	stepUnicode = "unicode"
+	// -notes-newlines, Notes only
+	stepNewlines = "newlines"
+	// -collapse-whitespace
+	stepWhitespace = "whitespace"
+	// -strip-diacritics, then -name-case for FullName and -address-case for
+	// Address
+	stepCase = "case"
+	// -control-chars
+	stepControl = "control"
+)
+
+// defaultTextSteps is the order the steps run in unless a -schema gives a
+// column its own. Unicode normalization comes before casing so é is always
+// the same bytes when it's cased, and control characters are escaped after
+// it, or the escapes would get uppercased along with the name
+var defaultTextSteps = []string{stepReplace, stepUnicode, stepNewlines, stepWhitespace, stepCase, stepControl}
+
+// textColumns are the canonical positions of the columns that have steps, in
+// the same order as textFields
+var textColumns = []int{columnIndex("Address"), columnIndex("FullName"), columnIndex("Notes")}
+
+func isTextColumn(c int) bool {
+	for _, t := range textColumns {
+		if t == c {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSteps makes sure a -schema column's steps are the default ones in
+// some order, each exactly once, since they only say what order to go in and
+// not whether to run
+func checkSteps(c int, steps []string) error {
+	if !isTextColumn(c) {
+		return fmt.Errorf("steps only apply to Address, FullName and Notes")
+	}
+	seen := make(map[string]bool)
+	for _, step := range steps {
+		known := false
+		for _, s := range defaultTextSteps {
+			known = known || s == step
+		}
+		if !known {
+			return fmt.Errorf("unknown step %q", step)
+		}
+		if seen[step] {
+			return fmt.Errorf("step %q given twice", step)
+		}
+		seen[step] = true
+	}
+	if len(seen) != len(defaultTextSteps) {
+		return fmt.Errorf("steps has to list all of %v", defaultTextSteps)
+	}
+	return nil
+}
+
+// runTextSteps puts field, the text column at canonical position c, through
+// its steps
+func (r *Record) runTextSteps(c int, field *string, cfg *Config, form norm.Form, useForm bool) {
+	steps := defaultTextSteps
+	if cfg.schema != nil && cfg.schema.steps[c] != nil {
+		steps = cfg.schema.steps[c]
+	}
+	for _, step := range steps {
+		switch step {
+		case stepReplace:
+			for _, rule := range cfg.Replacements {
+				if columnIndex(rule.Column) == c {
+					*field = rule.replace(*field)
+				}
+			}
+		case stepUnicode:
+			if useForm {
+				*field = form.String(*field)
+			}
+		case stepNewlines:
+			if field == &r.Notes {
+				*field = fixNewlines(*field, cfg.NotesNewlines, cfg.NotesNewlineReplacement)
+			}
+		case stepWhitespace:
+			if cfg.CollapseWhitespace {
+				*field = collapseWhitespace(*field)
+			}
+		case stepCase:
+			// Folded first, so casing only ever sees ASCII
+			if cfg.StripDiacritics {
+				*field = stripDiacritics(*field, cfg.NonASCIIPlaceholder)
+			}
+			if field == &r.FullName && !cfg.NoNormalizeName {
+				*field = caseName(*field, cfg.NameCase, cfg.NameParticles)
+			}
+			if field == &r.Address && cfg.AddressCase == addressCaseSmart {
+				*field = smartAddress(*field, cfg.AddressUpper)
+			}
+		case stepControl:
+			*field = fixControl(*field, cfg.ControlChars)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSteps(t *testing.T) {
+	tests := []struct {
+		name   string
+		column string
+		steps  []string
+		err    string
+	}{
+		{"default", "Notes", defaultTextSteps, ""},
+		{"reordered", "FullName", []string{"case", "replace", "unicode", "newlines", "whitespace", "control"}, ""},
+		{"not a text column", "ZIP", defaultTextSteps, "only apply to"},
+		{"unknown", "Notes", []string{"replace", "unicode", "newlines", "whitespace", "case", "shout"}, "unknown step"},
+		{"twice", "Notes", []string{"replace", "replace", "unicode", "newlines", "whitespace", "case"}, "given twice"},
+		{"missing one", "Notes", []string{"replace", "unicode", "newlines", "whitespace", "case"}, "has to list all"},
+		{"empty", "Notes", []string{}, "has to list all"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSteps(columnIndex(tt.column), tt.steps)
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSchemaSteps(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		// -replace runs on the name as read, so it doesn't find the uppercase
+		{"default order", `{"columns": {}}`, "MONKEY ALBERTO"},
+		{"case first", `{"columns": {"FullName": {"steps": ["case", "replace", "unicode", "newlines", "whitespace", "control"]}}}`, "Ape ALBERTO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, "schema.json", tt.schema)
+			records := outputRecords(t, testConfig(t, "-schema", path, "-replace", "FullName:MONKEY=Ape"), testHeader+testRow)
+			if len(records) != 2 || records[1][3] != tt.want {
+				t.Errorf("got %q, want FullName %q", records, tt.want)
+			}
+		})
+	}
+
+	path := writeTestFile(t, "schema.json", `{"columns": {"ZIP": {"steps": ["replace"]}}}`)
+	if err := configError(t, "-schema", path); err == nil || !strings.Contains(err.Error(), "ZIP: steps only apply to") {
+		t.Errorf("got error %v, want steps on ZIP refused", err)
+	}
+}
@@ -0,0 +1,143 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Values for -unicode-normalize
+const (
+	unicodeNormalizeOff = "off"
+	unicodeNormalizeNFC = "nfc"
+	unicodeNormalizeNFD = "nfd"
+)
+
+// textFields are the free text fields, the ones transforms like Unicode
+// normalization apply to
+func (r *Record) textFields() []*string {
+	return []*string{&r.Address, &r.FullName, &r.Notes}
+}
+
+// stripDiacritics folds s down to ASCII for -strip-diacritics: accents come
+// off by decomposing and dropping the combining marks, so José is Jose, and
+// anything left that still isn't ASCII, like ø, ß or an emoji, becomes
+// placeholder
+func stripDiacritics(s, placeholder string) string {
+	ascii := true
+	for i := 0; i < len(s) && ascii; i++ {
+		ascii = s[i] < utf8.RuneSelf
+	}
+	if ascii {
+		return s
+	}
+	var b strings.Builder
+	for _, c := range norm.NFD.String(s) {
+		switch {
+		case c < utf8.RuneSelf:
+			b.WriteRune(c)
+		case combiningMarks.Contains(c):
+		default:
+			b.WriteString(placeholder)
+		}
+	}
+	return b.String()
+}
+
+// combiningMarks are what stripDiacritics drops once NFD has split them off
+// the letters they were on
+var combiningMarks = runes.In(unicode.Mn)
+
+// unicodeForm maps the -unicode-normalize value to a norm.Form. ok is false
+// for off
+func unicodeForm(mode string) (form norm.Form, ok bool, err error) {
+	switch mode {
+	case unicodeNormalizeOff, "":
+		return 0, false, nil
+	case unicodeNormalizeNFC:
+		return norm.NFC, true, nil
+	case unicodeNormalizeNFD:
+		return norm.NFD, true, nil
+	}
+	return 0, false, fmt.Errorf("unknown -unicode-normalize %q", mode)
+}
+
+// Values for -notes-newlines
+const (
+	notesNewlinesPreserve      = "preserve"
+	notesNewlinesStripTrailing = "strip-trailing"
+	notesNewlinesReplace       = "replace"
+)
+
+// fixNewlines applies one of the notesNewlines* modes to s. Some loaders can't
+// cope with multiline cells, so replace turns every line break (\n, \r\n or a
+// lone \r) into replacement
+func fixNewlines(s, mode, replacement string) string {
+	switch mode {
+	case notesNewlinesStripTrailing:
+		return strings.TrimRight(s, "\r\n")
+	case notesNewlinesReplace:
+		return newlineReplacer(replacement).Replace(s)
+	}
+	return s
+}
+
+func newlineReplacer(replacement string) *strings.Replacer {
+	return strings.NewReplacer("\r\n", replacement, "\n", replacement, "\r", replacement)
+}
+
+// collapseWhitespace trims s and turns each run of whitespace inside it into
+// one space. Line breaks count as whitespace too
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Values for -control-chars
+const (
+	controlCharsKeep   = "keep"
+	controlCharsStrip  = "strip"
+	controlCharsEscape = "escape"
+)
+
+// controlEscapes are the control characters with a shorter escape than \xNN
+var controlEscapes = map[rune]string{
+	'\a': `\a`,
+	'\b': `\b`,
+	'\t': `\t`,
+	'\v': `\v`,
+	'\f': `\f`,
+}
+
+// fixControl applies one of the controlChars* modes to s. Line breaks are
+// left alone, since the CSV writer quotes them properly and -notes-newlines
+// is the place to deal with them. Everything else unicode.IsControl matches
+// goes, tabs and NULs included
+func fixControl(s, mode string) string {
+	if mode == controlCharsKeep || strings.IndexFunc(s, isStrayControl) < 0 {
+		return s
+	}
+	var b strings.Builder
+	for _, c := range s {
+		switch {
+		case !isStrayControl(c):
+			b.WriteRune(c)
+		case mode == controlCharsEscape:
+			if esc, ok := controlEscapes[c]; ok {
+				b.WriteString(esc)
+			} else if c < 0x100 {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				fmt.Fprintf(&b, `\u%04x`, c)
+			}
+		}
+	}
+	return b.String()
+}
+
+func isStrayControl(c rune) bool {
+	return unicode.IsControl(c) && c != '\n' && c != '\r'
+}
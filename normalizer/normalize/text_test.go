@@ -0,0 +1,191 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnicodeNormalize(t *testing.T) {
+	const (
+		composed   = "José"
+		decomposed = "José"
+	)
+	tests := []struct {
+		mode string
+		in   string
+		want string
+	}{
+		{"nfc", decomposed, composed},
+		{"nfc", composed, composed},
+		{"nfd", composed, decomposed},
+		{"off", decomposed, decomposed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			row := strings.Replace(testRow, "notes", tt.in, 1)
+			records := outputRecords(t, testConfig(t, "-unicode-normalize", tt.mode), testHeader+row)
+			if got := records[1][7]; got != tt.want {
+				t.Errorf("Notes = %+q, want %+q", got, tt.want)
+			}
+		})
+	}
+
+	if err := configError(t, "-unicode-normalize", "nfkc"); err == nil {
+		t.Error("-unicode-normalize nfkc: got no error")
+	}
+}
+
+func TestFixNewlines(t *testing.T) {
+	tests := []struct {
+		mode        string
+		replacement string
+		in          string
+		want        string
+	}{
+		{notesNewlinesPreserve, " ", "a\nb\n", "a\nb\n"},
+		{notesNewlinesStripTrailing, " ", "a\nb\r\n\n", "a\nb"},
+		{notesNewlinesStripTrailing, " ", "a\nb", "a\nb"},
+		{notesNewlinesReplace, " ", "a\nb\r\nc\rd", "a b c d"},
+		{notesNewlinesReplace, `\n`, "a\r\nb", `a\nb`},
+		{notesNewlinesReplace, "", "a\n\nb", "ab"},
+	}
+	for _, tt := range tests {
+		if got := fixNewlines(tt.in, tt.mode, tt.replacement); got != tt.want {
+			t.Errorf("fixNewlines(%q, %s, %q) = %q, want %q", tt.in, tt.mode, tt.replacement, got, tt.want)
+		}
+	}
+}
+
+func TestNotesNewlines(t *testing.T) {
+	row := strings.Replace(testRow, "notes", "\"line one\nline two\n\"", 1)
+	records := outputRecords(t, testConfig(t, "-notes-newlines", "replace", "-notes-newline-replacement", " / "), testHeader+row)
+	if got := records[1][7]; got != "line one / line two / " {
+		t.Errorf("Notes = %q", got)
+	}
+	if err := configError(t, "-notes-newlines", "drop"); err == nil {
+		t.Error("-notes-newlines drop: got no error")
+	}
+}
+
+func TestFixControl(t *testing.T) {
+	tests := []struct {
+		mode string
+		in   string
+		want string
+	}{
+		{controlCharsKeep, "a\tb\x00", "a\tb\x00"},
+		{controlCharsStrip, "a\tb\x00c\x7f", "abc"},
+		{controlCharsStrip, "line\r\nbreaks\n", "line\r\nbreaks\n"},
+		{controlCharsEscape, "a\tb\vc\x00d\x7f", `a\tb\vc\x00d\x7f`},
+		{controlCharsEscape, "next\u0085line", `next\x85line`},
+		{controlCharsEscape, "no controls", "no controls"},
+		{controlCharsEscape, `already \t escaped`, `already \t escaped`},
+	}
+	for _, tt := range tests {
+		if got := fixControl(tt.in, tt.mode); got != tt.want {
+			t.Errorf("fixControl(%q, %s) = %q, want %q", tt.in, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestControlCharsFlag(t *testing.T) {
+	row := strings.Replace(testRow, "Monkey Alberto", "Monkey\x00 Alberto", 1)
+	row = strings.Replace(row, "notes", "tab\there", 1)
+	records := outputRecords(t, testConfig(t, "-control-chars", "escape"), testHeader+row)
+	if got := records[1][3] + "|" + records[1][7]; got != `MONKEY\x00 ALBERTO|tab\there` {
+		t.Errorf("got %q", got)
+	}
+	if err := configError(t, "-control-chars", "drop"); err == nil {
+		t.Error("-control-chars drop: got no error")
+	}
+}
+
+func TestCollapseWhitespace(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{" 1  Main\tSt ", "1 Main St"},
+		{"one\r\ntwo\n\nthree", "one two three"},
+		{"already fine", "already fine"},
+		{"   ", ""},
+		{"no break", "no break"},
+	}
+	for _, tt := range tests {
+		if got := collapseWhitespace(tt.in); got != tt.want {
+			t.Errorf("collapseWhitespace(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCollapseWhitespaceFlag(t *testing.T) {
+	row := strings.Replace(testRow, "123 4th St", "  123   4th\tSt ", 1)
+	row = strings.Replace(row, "notes", "\"a\nb\"", 1)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "  123   4th\tSt |a\nb"},
+		{[]string{"-collapse-whitespace"}, "123 4th St|a b"},
+		// The replacement is collapsed too
+		{[]string{"-collapse-whitespace", "-notes-newlines", "replace", "-notes-newline-replacement", "   "}, "123 4th St|a b"},
+	}
+	for _, tt := range tests {
+		records := outputRecords(t, testConfig(t, tt.args...), testHeader+row)
+		if got := records[1][1] + "|" + records[1][7]; got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestStripDiacritics(t *testing.T) {
+	tests := []struct {
+		in          string
+		placeholder string
+		want        string
+	}{
+		{"plain ascii", "?", "plain ascii"},
+		{"José", "?", "Jose"},
+		// Already decomposed
+		{"José", "?", "Jose"},
+		{"Ångström Čapek", "?", "Angstrom Capek"},
+		{"Søren Groß", "?", "S?ren Gro?"},
+		{"¡hola! 東京 👍", "?", "?hola! ?? ?"},
+		{"Søren", "", "Sren"},
+		{"Søren", "[?]", "S[?]ren"},
+		{"", "?", ""},
+	}
+	for _, tt := range tests {
+		if got := stripDiacritics(tt.in, tt.placeholder); got != tt.want {
+			t.Errorf("stripDiacritics(%q, %q) = %q, want %q", tt.in, tt.placeholder, got, tt.want)
+		}
+	}
+}
+
+func TestStripDiacriticsFlag(t *testing.T) {
+	row := strings.Replace(testRow, "123 4th St", "1 Rue de l'Église", 1)
+	row = strings.Replace(row, "Monkey Alberto", "Résumé Ron", 1)
+	row = strings.Replace(row, "notes", "naïve café ø", 1)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "1 Rue de l'Église|RÉSUMÉ RON|naïve café ø"},
+		{[]string{"-strip-diacritics"}, "1 Rue de l'Eglise|RESUME RON|naive cafe ?"},
+		{[]string{"-strip-diacritics", "-non-ascii-placeholder", ""}, "1 Rue de l'Eglise|RESUME RON|naive cafe "},
+		// Folded before the casing, so it's the same whichever case is asked for
+		{[]string{"-strip-diacritics", "-name-case", "title-smart"}, "1 Rue de l'Eglise|Resume Ron|naive cafe ?"},
+		// Only the placeholder without -strip-diacritics does nothing
+		{[]string{"-non-ascii-placeholder", "_"}, "1 Rue de l'Église|RÉSUMÉ RON|naïve café ø"},
+	}
+	for _, tt := range tests {
+		records := outputRecords(t, testConfig(t, tt.args...), testHeader+row)
+		if got := records[1][1] + "|" + records[1][3] + "|" + records[1][7]; got != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	if err := configError(t, "-strip-diacritics", "-non-ascii-placeholder", "¿"); err == nil {
+		t.Error("-non-ascii-placeholder ¿: got no error")
+	}
+}
@@ -0,0 +1,309 @@
+package normalize
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Go's own pivot for two-digit years: 69-99 are the 1900s, 00-68 the 2000s
+const defaultYearPivot = 69
+
+// DefaultTimestampLayouts are the time.Parse layouts we accept for Timestamp,
+// tried in order. Examining the sample it looks like there's only one time
+// format to deal with
+var DefaultTimestampLayouts = []string{
+	"1/2/06 3:04:05 PM",
+}
+
+// zoneAbbreviations are the zone abbreviations we'll accept in a timestamp,
+// for layouts with MST in them, and their offsets from UTC in hours.
+// Abbreviations aren't unique (CST is China Standard Time too, and IST is
+// three different zones) so these are only the North American ones, which is
+// what our feeds send. Anything else is rejected rather than guessed at
+var zoneAbbreviations = map[string]int{
+	"UTC":  0,
+	"GMT":  0,
+	"EST":  -5,
+	"EDT":  -4,
+	"CST":  -6,
+	"CDT":  -5,
+	"MST":  -7,
+	"MDT":  -6,
+	"PST":  -8,
+	"PDT":  -7,
+	"AKST": -9,
+	"AKDT": -8,
+	"HST":  -10,
+}
+
+// Values for -dst-policy, deciding what a wall clock time means when the
+// clocks go back and it happens twice
+const (
+	dstPolicyEarliest = "earliest"
+	dstPolicyLatest   = "latest"
+	dstPolicyError    = "error"
+)
+
+// parseTimestamp parses an input timestamp as though it's in US/Pacific time,
+// or whatever -source-tz says, unless it names its own zone
+func parseTimestamp(s string, cfg *Config) (time.Time, error) {
+	t, _, err := parseTimestampLayout(s, cfg)
+	return t, err
+}
+
+// parseTimestampLayout is parseTimestamp, also saying which of the layouts
+// matched
+func parseTimestampLayout(s string, cfg *Config) (time.Time, string, error) {
+	source, err := cfg.zones.load(cfg.SourceTZ)
+	if err != nil {
+		return time.Time{}, "", ErrTimezone
+	}
+
+	if cfg.NormalizeAMPM {
+		s = normalizeAMPM(s)
+	}
+	for _, layout := range cfg.TimestampLayouts {
+		// Parse as a plain wall clock reading first, and only then work out
+		// which instant that is in the source zone. ParseInLocation would do both
+		// at once, but it doesn't promise anything about DST edge cases
+		wall, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		wall, err = adjustCentury(wall, layout, cfg.YearPivot)
+		if err != nil {
+			return time.Time{}, "", err
+		}
+		if strings.Contains(layout, "MST") {
+			// time.Parse only knows the offsets of abbreviations used by the
+			// local zone, and makes up a zero offset for the rest, so look
+			// it up ourselves. There's no DST to resolve with a fixed offset
+			name, _ := wall.Zone()
+			hours, ok := zoneAbbreviations[name]
+			if !ok {
+				return time.Time{}, "", fmt.Errorf("%w (unknown zone abbreviation %s)", ErrTimestamp, name)
+			}
+			zone := time.FixedZone(name, hours*60*60)
+			return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), zone), layout, nil
+		}
+		if layoutHasOffset(layout) {
+			// The input said exactly which instant it meant, so that's it
+			return wall, layout, nil
+		}
+		t, err := resolveWallClock(wall, source, cfg.DSTPolicy)
+		return t, layout, err
+	}
+	return time.Time{}, "", ErrTimestamp
+}
+
+// layoutHasOffset is whether a layout reads a numeric offset from UTC, or Z
+// for UTC itself, like -0700, -07:00 or Z07:00
+func layoutHasOffset(layout string) bool {
+	return strings.Contains(layout, "-07") || strings.Contains(layout, "Z07")
+}
+
+// ampmMarker matches an AM/PM marker written some other way than the layouts
+// expect, right after the time: am, pm, a.m., P.M., with or without the
+// space in front. It has to follow a digit, so it can't match the start of a
+// zone abbreviation
+var ampmMarker = regexp.MustCompile(`(?i)(\d)\s*([ap])\.?\s?m\.?(\s|$)`)
+
+// normalizeAMPM turns any ampmMarker in s into a plain AM or PM, which is all
+// time.Parse accepts for PM in a layout
+func normalizeAMPM(s string) string {
+	return ampmMarker.ReplaceAllStringFunc(s, func(marker string) string {
+		m := ampmMarker.FindStringSubmatch(marker)
+		return m[1] + " " + strings.ToUpper(m[2]) + "M" + m[3]
+	})
+}
+
+// resolveWallClock finds the instant at which clocks in loc showed the wall
+// clock reading in wall (whose own zone is ignored). Usually there's exactly
+// one. When the clocks go back there are two, and policy picks between them.
+// When the clocks go forward there are none; unless policy is error we read
+// the time with the offset from before the change, which moves it forward by
+// the size of the gap (2:30 AM on a spring forward day becomes 3:30 AM)
+func resolveWallClock(wall time.Time, loc *time.Location, policy string) (time.Time, error) {
+	asUTC := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.UTC)
+
+	// US zones change at most once in a day, so the offsets a day either
+	// side are the only ones this reading could be in
+	_, before := asUTC.Add(-24 * time.Hour).In(loc).Zone()
+	_, after := asUTC.Add(24 * time.Hour).In(loc).Zone()
+
+	var candidates []time.Time
+	for _, offset := range []int{before, after} {
+		t := asUTC.Add(-time.Duration(offset) * time.Second).In(loc)
+		if sameWallClock(t, asUTC) && (len(candidates) == 0 || !t.Equal(candidates[0])) {
+			candidates = append(candidates, t)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		if policy == dstPolicyError {
+			return time.Time{}, fmt.Errorf("%w (skipped when the clocks went forward)", ErrTimestamp)
+		}
+		return asUTC.Add(-time.Duration(before) * time.Second).In(loc), nil
+	case 1:
+		return candidates[0], nil
+	}
+
+	first, second := candidates[0], candidates[1]
+	if second.Before(first) {
+		first, second = second, first
+	}
+	switch policy {
+	case dstPolicyLatest:
+		return second, nil
+	case dstPolicyError:
+		return time.Time{}, fmt.Errorf("%w (ambiguous, the clocks went back)", ErrTimestamp)
+	}
+	return first, nil
+}
+
+func sameWallClock(t, wall time.Time) bool {
+	y, mo, d := t.Date()
+	h, mi, s := t.Clock()
+	wy, wmo, wd := wall.Date()
+	wh, wmi, ws := wall.Clock()
+	return y == wy && mo == wmo && d == wd && h == wh && mi == wmi && s == ws && t.Nanosecond() == wall.Nanosecond()
+}
+
+// Values for -timestamp-truncate
+const (
+	truncateOff    = "off"
+	truncateDay    = "day"
+	truncateHour   = "hour"
+	truncateMinute = "minute"
+	truncateSecond = "second"
+)
+
+// truncateTime cuts t down to the start of its day, hour, minute or second
+// on its own wall clock. time.Truncate works in UTC, which puts the start of
+// the day in the wrong place anywhere else, so this doesn't use it. The
+// smaller units just take the wall clock's minutes, seconds and so on off,
+// which can't land in a missing hour; midnight very rarely is one, and Go
+// picks a time either side of the gap if it is
+func truncateTime(t time.Time, unit string) time.Time {
+	wall := time.Duration(t.Nanosecond())
+	switch unit {
+	case truncateDay:
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	case truncateHour:
+		wall += time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	case truncateMinute:
+		wall += time.Duration(t.Second()) * time.Second
+	case truncateSecond:
+	default:
+		return t
+	}
+	return t.Add(-wall)
+}
+
+// twoDigitYear says whether layout writes the year as 06 rather than 2006
+func twoDigitYear(layout string) bool {
+	return strings.Contains(layout, "06") && !strings.Contains(layout, "2006")
+}
+
+// adjustCentury re-decides the century of t, if layout parsed it from a
+// two-digit year. Two-digit years below pivot land in the 2000s, the rest in
+// the 1900s, so a pivot of 0 puts everything in the 1900s and 100 puts
+// everything in the 2000s. A four-digit year already says its century, so
+// it's left alone
+func adjustCentury(t time.Time, layout string, pivot int) (time.Time, error) {
+	if !twoDigitYear(layout) {
+		return t, nil
+	}
+	yy := t.Year() % 100
+	year := 1900 + yy
+	if yy < pivot {
+		year = 2000 + yy
+	}
+	if year == t.Year() {
+		return t, nil
+	}
+
+	adjusted := time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	// 2/29/00 is fine in 2000 but 1900 wasn't a leap year
+	if adjusted.Day() != t.Day() {
+		return time.Time{}, ErrTimestamp
+	}
+	return adjusted, nil
+}
+
+// zoneCache remembers time zones we've already loaded by name, since
+// LoadLocation goes to disk every time and a per-row zone column would
+// otherwise have us doing that for every row
+type zoneCache struct {
+	mu    sync.Mutex
+	zones map[string]*time.Location
+}
+
+func (c *zoneCache) load(name string) (*time.Location, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if loc, ok := c.zones[name]; ok {
+		return loc, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	if c.zones == nil {
+		c.zones = make(map[string]*time.Location)
+	}
+	c.zones[name] = loc
+	return loc, nil
+}
+
+// verifyZone is loaded by -verify-tz as well as the configured zones. It's a
+// fixed offset, so it can only fail to load if there's no database at all
+const verifyZone = "Etc/GMT+5"
+
+// tzdataSource says where time.LoadLocation will find its zone database,
+// checking the places it looks in the order it looks
+func tzdataSource() string {
+	if zoneinfo := os.Getenv("ZONEINFO"); zoneinfo != "" {
+		if _, err := os.Stat(zoneinfo); err == nil {
+			return zoneinfo + " (from $ZONEINFO)"
+		}
+	}
+	for _, dir := range []string{"/usr/share/zoneinfo/", "/usr/share/lib/zoneinfo/", "/usr/lib/locale/TZ/", "/etc/zoneinfo/"} {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+	}
+	zip := filepath.Join(runtime.GOROOT(), "lib", "time", "zoneinfo.zip")
+	if _, err := os.Stat(zip); err == nil {
+		return zip
+	}
+	return "none found, unless the binary embeds time/tzdata"
+}
+
+// verifyZones loads each zone -verify-tz checks, saying how it went on w, and
+// returns an error if any of them wouldn't load
+func verifyZones(w io.Writer, cfg *Config) error {
+	fmt.Fprintln(w, "tzdata:", tzdataSource())
+	failed := 0
+	for _, name := range []string{cfg.SourceTZ, cfg.DestTZ, verifyZone} {
+		if _, err := cfg.zones.load(name); err != nil {
+			fmt.Fprintf(w, "%s: FAILED: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(w, "%s: ok\n", name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d zone(s) failed to load", failed)
+	}
+	return nil
+}
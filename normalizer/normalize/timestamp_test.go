@@ -0,0 +1,425 @@
+package normalize
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdjustCentury(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+		in     string
+		pivot  int
+		want   int
+	}{
+		{"go's pivot, 50", "1/2/06", "1/2/50", defaultYearPivot, 2050},
+		{"go's pivot, 99", "1/2/06", "1/2/99", defaultYearPivot, 1999},
+		{"pivot 30 sends 50 back", "1/2/06", "1/2/50", 30, 1950},
+		{"pivot 100 keeps everything in the 2000s", "1/2/06", "1/2/99", 100, 2099},
+		{"pivot 0 keeps everything in the 1900s", "1/2/06", "1/2/05", 0, 1905},
+		{"four-digit year, 2080", "2006-01-02", "2080-01-02", defaultYearPivot, 2080},
+		{"four-digit year, 1850", "2006-01-02", "1850-01-02", defaultYearPivot, 1850},
+		{"four-digit year ignores the pivot", "2006-01-02", "1950-01-02", 100, 1950},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := time.Parse(tt.layout, tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := adjustCentury(parsed, tt.layout, tt.pivot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Year() != tt.want {
+				t.Errorf("year = %d, want %d", got.Year(), tt.want)
+			}
+		})
+	}
+}
+
+func TestAdjustCenturyLeapDay(t *testing.T) {
+	// 2/29/00 is fine in 2000, but 1900 wasn't a leap year
+	parsed, err := time.Parse("1/2/06", "2/29/00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := adjustCentury(parsed, "1/2/06", 0); err != ErrTimestamp {
+		t.Errorf("err = %v, want ErrTimestamp", err)
+	}
+}
+
+func TestTwoDigitYear(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   bool
+	}{
+		{"1/2/06 3:04:05 PM", true},
+		{"06-01-02", true},
+		{"2006-01-02 15:04:05", false},
+		{"1/2/2006 3:04:05 PM MST", false},
+		{"15:04:05", false},
+	}
+	for _, tt := range tests {
+		if got := twoDigitYear(tt.layout); got != tt.want {
+			t.Errorf("twoDigitYear(%q) = %v, want %v", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestParseTimestampLayouts(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout string
+		in     string
+		want   string
+	}{
+		{"default layout", "1/2/06 3:04:05 PM", "4/1/11 11:00:00 AM", "2011-04-01T18:00:00Z"},
+		{"four-digit year far ahead", "2006-01-02 15:04:05", "2080-04-01 11:00:00", "2080-04-01T18:00:00Z"},
+		{"four-digit year long ago", "2006-01-02 15:04:05", "1950-01-02 11:00:00", "1950-01-02T19:00:00Z"},
+		{"PST", "1/2/06 3:04:05 PM MST", "1/2/06 3:04:05 PM PST", "2006-01-02T23:04:05Z"},
+		{"EDT", "1/2/06 3:04:05 PM MST", "7/4/20 9:00:00 AM EDT", "2020-07-04T13:00:00Z"},
+		{"UTC with a four-digit year", "1/2/2006 3:04:05 PM MST", "7/4/2020 9:00:00 AM UTC", "2020-07-04T09:00:00Z"},
+		{"RFC 3339 in UTC", time.RFC3339, "2011-04-01T11:00:00Z", "2011-04-01T11:00:00Z"},
+		{"RFC 3339 with an offset", time.RFC3339, "2011-04-01T11:00:00+02:00", "2011-04-01T09:00:00Z"},
+		{"numeric offset", "1/2/06 3:04:05 PM -0700", "4/1/11 11:00:00 AM -0500", "2011-04-01T16:00:00Z"},
+		{"offset in hours", "2006-01-02 15:04 -07", "2016-11-06 01:30 -08", "2016-11-06T09:30:00Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, "-timestamp-layout", tt.layout)
+			got, layout, err := parseTimestampLayout(tt.in, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if layout != tt.layout {
+				t.Errorf("layout = %q, want %q", layout, tt.layout)
+			}
+			if s := got.UTC().Format(time.RFC3339); s != tt.want {
+				t.Errorf("got %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTimestampUnknownAbbreviation(t *testing.T) {
+	// IST is three different zones, so it's not guessed at
+	cfg := testConfig(t, "-timestamp-layout", "1/2/06 3:04:05 PM MST")
+	if _, err := parseTimestamp("1/2/06 3:04:05 PM IST", cfg); !errors.Is(err, ErrTimestamp) {
+		t.Errorf("err = %v, want ErrTimestamp", err)
+	}
+}
+
+func TestTimestampLayoutOffset(t *testing.T) {
+	tests := []struct {
+		layout string
+		in     string
+		want   string
+	}{
+		{"2006-01-02T15:04:05Z07:00", "2011-04-01T11:00:00Z", "2011-04-01T07:00:00-04:00"},
+		{"2006-01-02T15:04:05Z07:00", "2011-04-01T11:00:00-07:00", "2011-04-01T14:00:00-04:00"},
+		// Not in the gap, whatever -dst-policy says, since it was never a
+		// wall clock in the source zone
+		{"2006-01-02 15:04:05 -0700", "2016-03-13 02:30:00 -0800", "2016-03-13T06:30:00-04:00"},
+	}
+	for _, tt := range tests {
+		row := strings.Replace(testRow, "4/1/11 11:00:00 AM", tt.in, 1)
+		records := outputRecords(t, testConfig(t, "-timestamp-layout", tt.layout, "-dst-policy", "error"), testHeader+row)
+		if got := records[1][0]; got != tt.want {
+			t.Errorf("%s with %s: got %s, want %s", tt.in, tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestYearPivotFlag(t *testing.T) {
+	row := strings.Replace(testRow, "4/1/11", "1/2/50", 1)
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "2050-01-02T14:00:00-05:00"},
+		{[]string{"-year-pivot", "40"}, "1950-01-02T14:00:00-05:00"},
+	}
+	for _, tt := range tests {
+		records := outputRecords(t, testConfig(t, tt.args...), testHeader+row)
+		if got := records[1][0]; got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.args, got, tt.want)
+		}
+	}
+
+	for _, pivot := range []string{"-1", "101"} {
+		if err := configError(t, "-year-pivot", pivot); err == nil {
+			t.Errorf("-year-pivot %s: got no error", pivot)
+		}
+	}
+}
+
+func TestDestTZColumn(t *testing.T) {
+	tests := []struct {
+		zone string
+		want string
+	}{
+		{"Europe/London", "2011-04-01T19:00:00+01:00"},
+		{"Asia/Tokyo", "2011-04-02T03:00:00+09:00"},
+		{"", "2011-04-01T14:00:00-04:00"},
+		{"Not/AZone", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			in := strings.TrimSuffix(testHeader, "\n") + ",Zone\n" + strings.TrimSuffix(testRow, "\n") + "," + tt.zone + "\n"
+			records := outputRecords(t, testConfig(t, "-dest-tz-column", "Zone"), in)
+			if len(records[0]) != len(canonicalHeaders) {
+				t.Errorf("header = %v, want the Zone column left out", records[0])
+			}
+			if tt.want == "" {
+				if len(records) != 1 {
+					t.Errorf("got %v, want the row rejected", records[1:])
+				}
+				return
+			}
+			if len(records) != 2 || records[1][0] != tt.want {
+				t.Errorf("got %v, want Timestamp %s", records[1:], tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveWallClock(t *testing.T) {
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name    string
+		wall    time.Time
+		policy  string
+		want    string
+		wantErr bool
+	}{
+		{"ordinary", time.Date(2016, 7, 1, 12, 0, 0, 0, time.UTC), dstPolicyError, "2016-07-01T12:00:00-07:00", false},
+		{"fall back, earliest", time.Date(2016, 11, 6, 1, 30, 0, 0, time.UTC), dstPolicyEarliest, "2016-11-06T01:30:00-07:00", false},
+		{"fall back, latest", time.Date(2016, 11, 6, 1, 30, 0, 0, time.UTC), dstPolicyLatest, "2016-11-06T01:30:00-08:00", false},
+		{"fall back, error", time.Date(2016, 11, 6, 1, 30, 0, 0, time.UTC), dstPolicyError, "", true},
+		{"spring forward", time.Date(2016, 3, 13, 2, 30, 0, 0, time.UTC), dstPolicyEarliest, "2016-03-13T03:30:00-07:00", false},
+		{"spring forward, latest", time.Date(2016, 3, 13, 2, 30, 0, 0, time.UTC), dstPolicyLatest, "2016-03-13T03:30:00-07:00", false},
+		{"spring forward, error", time.Date(2016, 3, 13, 2, 30, 0, 0, time.UTC), dstPolicyError, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveWallClock(tt.wall, pacific, tt.policy)
+			if tt.wantErr {
+				if !errors.Is(err, ErrTimestamp) {
+					t.Errorf("got %v, %v, want ErrTimestamp", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := got.Format(time.RFC3339); s != tt.want {
+				t.Errorf("got %s, want %s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestDSTPolicyFlag(t *testing.T) {
+	row := strings.Replace(testRow, "4/1/11 11:00:00 AM", "11/6/16 1:30:00 AM", 1)
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{"earliest", "2016-11-06T03:30:00-05:00"},
+		{"latest", "2016-11-06T04:30:00-05:00"},
+		{"error", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			records := outputRecords(t, testConfig(t, "-dst-policy", tt.policy), testHeader+row)
+			var got string
+			if len(records) > 1 {
+				got = records[1][0]
+			}
+			if got != tt.want {
+				t.Errorf("Timestamp = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if err := configError(t, "-dst-policy", "nearest"); err == nil {
+		t.Error("-dst-policy nearest: got no error")
+	}
+}
+
+func TestVerifyZones(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		lines   []string
+		wantErr bool
+	}{
+		{"defaults", nil, []string{"US/Pacific: ok", "US/Eastern: ok", "Etc/GMT+5: ok"}, false},
+		{"bad source", []string{"-source-tz", "Mars/Olympus"}, []string{"Mars/Olympus: FAILED", "US/Eastern: ok"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			err := verifyZones(&out, parseTestFlags(t, tt.args...))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if !strings.HasPrefix(out.String(), "tzdata: ") {
+				t.Errorf("doesn't say where the zones came from:\n%s", out.String())
+			}
+			for _, line := range tt.lines {
+				if !strings.Contains(out.String(), "\n"+line) {
+					t.Errorf("no %q in\n%s", line, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyTZExitStatus(t *testing.T) {
+	if _, stderr, status := runMain(t, "", "-verify-tz"); status != 0 {
+		t.Errorf("exit status %d: %s", status, stderr)
+	}
+	if _, _, status := runMain(t, "", "-verify-tz", "-dest-tz", "Mars/Olympus"); status != 1 {
+		t.Errorf("exit status %d with a bad zone, want 1", status)
+	}
+}
+
+func TestNormalizeAMPM(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"4/1/11 11:00:00 AM", "4/1/11 11:00:00 AM"},
+		{"4/1/11 11:00:00 am", "4/1/11 11:00:00 AM"},
+		{"4/1/11 11:00:00 p.m.", "4/1/11 11:00:00 PM"},
+		{"4/1/11 11:00:00PM", "4/1/11 11:00:00 PM"},
+		{"4/1/11 11:00:00 P.M. PST", "4/1/11 11:00:00 PM PST"},
+		{"4/1/11 11:00:00 pm.", "4/1/11 11:00:00 PM"},
+		// Not a marker, it's the start of the zone
+		{"4/1/11 11:00:00 AM AMT", "4/1/11 11:00:00 AM AMT"},
+		{"4/1/11 23:00:00 amsterdam", "4/1/11 23:00:00 amsterdam"},
+	}
+	for _, tt := range tests {
+		if got := normalizeAMPM(tt.in); got != tt.want {
+			t.Errorf("normalizeAMPM(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeAMPMFlag(t *testing.T) {
+	row := strings.Replace(testRow, "11:00:00 AM", "11:00:00 p.m.", 1)
+	if records := outputRecords(t, testConfig(t), testHeader+row); len(records) != 1 {
+		t.Errorf("got %v without -normalize-ampm, want the row rejected", records[1:])
+	}
+	records := outputRecords(t, testConfig(t, "-normalize-ampm"), testHeader+row)
+	if len(records) != 2 || records[1][0] != "2011-04-02T02:00:00-04:00" {
+		t.Errorf("got %v", records[1:])
+	}
+}
+
+func TestTimestampColumns(t *testing.T) {
+	header := "Date,Time,Address,ZIP,FullName,FooDuration,BarDuration,TotalDuration,Notes\n"
+	rest := ",123 4th St,94121,Monkey Alberto,1:23:32.123,1:32:33.123,zzsasdfa,notes\n"
+	tests := []struct {
+		name string
+		args []string
+		date string
+		time string
+		want string
+	}{
+		{"joined with a space", nil, "4/1/11", "11:00:00 AM", "2011-04-01T14:00:00-04:00"},
+		{
+			"joined with T",
+			[]string{"-timestamp-join", "T", "-timestamp-layout", "2006-01-02T15:04:05"},
+			"2011-04-01", "11:00:00", "2011-04-01T14:00:00-04:00",
+		},
+		{"bad part", nil, "4/1/11", "noon", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(t, append(tt.args, "-timestamp-columns", "Date,Time")...)
+			records := outputRecords(t, cfg, header+tt.date+","+tt.time+rest)
+			if got := strings.Join(records[0], ",") + "\n"; got != testHeader {
+				t.Errorf("header = %s, want the canonical one", got)
+			}
+			var got string
+			if len(records) > 1 {
+				got = records[1][0]
+			}
+			if got != tt.want {
+				t.Errorf("Timestamp = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncateTime(t *testing.T) {
+	eastern, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	base := time.Date(2011, 4, 1, 14, 25, 36, 123456789, eastern)
+	tests := []struct {
+		unit string
+		want time.Time
+	}{
+		{truncateOff, base},
+		{truncateDay, time.Date(2011, 4, 1, 0, 0, 0, 0, eastern)},
+		{truncateHour, time.Date(2011, 4, 1, 14, 0, 0, 0, eastern)},
+		{truncateMinute, time.Date(2011, 4, 1, 14, 25, 0, 0, eastern)},
+		{truncateSecond, time.Date(2011, 4, 1, 14, 25, 36, 0, eastern)},
+	}
+	for _, tt := range tests {
+		if got := truncateTime(base, tt.unit); !got.Equal(tt.want) {
+			t.Errorf("truncateTime(%s) = %v, want %v", tt.unit, got, tt.want)
+		}
+	}
+
+	// In the repeated hour after clocks go back, the hour is the one we're
+	// in, not the first 1am
+	second := time.Date(2016, 11, 6, 6, 30, 0, 0, time.UTC).In(eastern)
+	if got := truncateTime(second, truncateHour); got.Format(time.RFC3339) != "2016-11-06T01:00:00-05:00" {
+		t.Errorf("truncating %v to the hour got %v", second, got.Format(time.RFC3339))
+	}
+}
+
+func TestTimestampTruncateFlags(t *testing.T) {
+	late := strings.Replace(testRow, "4/1/11 11:00:00 AM", "4/1/11 10:30:15 PM", 1)
+	tests := []struct {
+		name string
+		args []string
+		want string
+		err  string
+	}{
+		{"off", nil, "2011-04-02T01:30:15-04:00", ""},
+		// The destination's day, not the input's
+		{"day", []string{"-timestamp-truncate", "day"}, "2011-04-02T00:00:00-04:00", ""},
+		{"hour", []string{"-timestamp-truncate", "hour"}, "2011-04-02T01:00:00-04:00", ""},
+		{"minute", []string{"-timestamp-truncate", "minute"}, "2011-04-02T01:30:00-04:00", ""},
+		{"date only", []string{"-timestamp-date-only"}, "2011-04-02", ""},
+		{"date only and day", []string{"-timestamp-date-only", "-timestamp-truncate", "day"}, "2011-04-02", ""},
+		{"date only and hour", []string{"-timestamp-date-only", "-timestamp-truncate", "hour"}, "", "already truncates"},
+		{"unknown", []string{"-timestamp-truncate", "week"}, "", "unknown -timestamp-truncate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err != "" {
+				if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("got error %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			records := outputRecords(t, testConfig(t, tt.args...), testHeader+late)
+			if len(records) != 2 || records[1][0] != tt.want {
+				t.Errorf("got %q, want Timestamp %q", records, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,797 @@
+package normalize
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Transform is the whole normalizer as a library call: it reads CSV from r,
+// normalizes each row with the default settings and writes CSV to w. If hook
+// isn't nil it's called with each record after Normalize, and can change it
+// or return ErrSkip to drop it. Any other error from hook rejects the row the
+// same way a normalization error would. Warnings about rejected rows go to
+// stderr, and the returned error is only for problems that stop the run
+func Transform(r io.Reader, w io.Writer, hook func(*Record) error) error {
+	return transform(DefaultConfig(), r, w, hook)
+}
+
+// input is one of the files we're reading, named for warnings and the error
+// report. The name is empty when there's only stdin
+type input struct {
+	name string
+	r    io.Reader
+}
+
+// openedInput is an input whose header has been read and mapped
+type openedInput struct {
+	rows         rowReader
+	headers      []string
+	mapping      []int
+	width        int
+	destTZColumn int
+	// With -timestamp-columns, the columns Timestamp is put together from.
+	// mapping then has Timestamp one past the end of the input's fields,
+	// where newRecord puts it
+	timestampParts []int
+	// What the fields are separated by, which -delimiter auto works out
+	// for each input
+	delimiter rune
+	// Where each of the -bool-columns is
+	boolColumns []int
+	// With -add-offset-column, where each line of the input starts
+	offsets *lineOffsets
+}
+
+func openMapped(cfg *Config, in io.Reader, known []string) (*openedInput, error) {
+	// Underneath everything else, so the offsets are in the input as it is
+	var offsets *lineOffsets
+	if cfg.AddOffsetColumn {
+		offsets = newLineOffsets(in)
+		in = offsets
+	}
+	// Before anything looks for the end of a line
+	if cfg.RecordSeparator != 0 {
+		in = newSeparatorTranslator(in, cfg.RecordSeparator)
+	}
+	if cfg.CanonicalizeNewlines {
+		in = &newlineTranslator{src: in}
+	}
+	delimiter := cfg.Delimiter
+	if delimiter == delimiterAuto && cfg.InputFormat == inputFormatCSV {
+		// What we expect the first line to have in it
+		want, atLeast := len(canonicalHeaders), !cfg.NoHeader && known == nil
+		switch {
+		case known != nil:
+			want = len(known)
+		case cfg.NoHeader && len(cfg.Columns) > 0:
+			want = len(cfg.Columns)
+		case !cfg.NoHeader && len(cfg.TimestampColumns) > 0:
+			want += len(cfg.TimestampColumns) - 1
+		}
+		buffered := bufio.NewReader(in)
+		delimiter = sniffDelimiter(buffered, want, atLeast)
+		in = buffered
+	}
+	rows, headers, err := openInput(cfg, in, known, delimiter)
+	if err != nil {
+		return nil, err
+	}
+	var timestampParts []int
+	mapped := headers
+	if len(cfg.TimestampColumns) > 0 {
+		for _, name := range cfg.TimestampColumns {
+			i, err := findColumn(headers, name, cfg.CaseInsensitiveHeaders)
+			if err != nil {
+				return nil, fmt.Errorf("unusable csv header: %w", err)
+			}
+			timestampParts = append(timestampParts, i)
+		}
+		mapped = append(append([]string(nil), headers...), "Timestamp")
+	}
+	mapping, err := mapHeaders(mapped, cfg.CaseInsensitiveHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("unusable csv header: %w", err)
+	}
+	if cfg.MergeTrailingIntoNotes && mapping[columnIndex("Notes")] != len(headers)-1 {
+		return nil, fmt.Errorf("unusable csv header: -merge-trailing-into-notes needs Notes to be the last column")
+	}
+
+	destTZColumn := -1
+	if cfg.DestTZColumn != "" {
+		destTZColumn, err = findColumn(headers, cfg.DestTZColumn, cfg.CaseInsensitiveHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("unusable csv header: %w", err)
+		}
+	}
+	var boolColumns []int
+	for _, name := range cfg.BoolColumns {
+		i, err := findColumn(headers, name, cfg.CaseInsensitiveHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("unusable csv header: %w", err)
+		}
+		boolColumns = append(boolColumns, i)
+	}
+	return &openedInput{rows, headers, mapping, len(headers), destTZColumn, timestampParts, delimiter, boolColumns, offsets}, nil
+}
+
+// newRecord builds the Record for one of this input's rows, which fitRow has
+// already made the right width
+func (o *openedInput) newRecord(fields []string, cfg *Config) *Record {
+	if o.timestampParts != nil {
+		parts := make([]string, len(o.timestampParts))
+		for i, column := range o.timestampParts {
+			parts[i] = fields[column]
+		}
+		fields = append(fields, strings.Join(parts, cfg.TimestampJoin))
+	}
+	record := newRecord(fields, o.mapping)
+	if o.destTZColumn >= 0 {
+		record.destZone = fields[o.destTZColumn]
+	}
+	for i, column := range o.boolColumns {
+		record.setExtra(cfg.BoolColumns[i], validateUTF8(fields[column]))
+	}
+	return record
+}
+
+// inputOrder is the canonical columns in the order this input has them.
+// A Timestamp put together by -timestamp-columns goes where its first part is
+func (o *openedInput) inputOrder() []int {
+	position := func(c int) int {
+		if c == 0 && o.timestampParts != nil {
+			return o.timestampParts[0]
+		}
+		return o.mapping[c]
+	}
+	order := make([]int, len(canonicalHeaders))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return position(order[a]) < position(order[b]) })
+	return order
+}
+
+// withoutColumn is an output order with column c left out. A nil order is
+// canonical order, so that's where it starts from
+func withoutColumn(order []int, c int) []int {
+	if order == nil {
+		for i := range canonicalHeaders {
+			order = append(order, i)
+		}
+	}
+	var kept []int
+	for _, i := range order {
+		if i != c {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// annotatedRecord is what -annotate-errors writes for a row that failed with
+// err: the fields just as they were read, with the error in the extra column.
+// record is the one that failed, or nil if fields never made it that far
+func annotatedRecord(record *Record, fields []string, opened *openedInput, cfg *Config, line int, err error) *Record {
+	if record == nil {
+		// The wrong number of fields, so line them up as best we can
+		fitted := make([]string, opened.width)
+		copy(fitted, fields)
+		record = opened.newRecord(fitted, cfg)
+	}
+	annotated, _ := RecordFromFields(record.original)
+	annotated.line = line
+	if opened.offsets != nil {
+		opened.setOffset(annotated, line)
+	}
+	annotated.setExtra(annotateErrorsColumn, err.Error())
+	return annotated
+}
+
+// inputError says which input err came from, if we've got more than stdin
+func inputError(name string, err error) error {
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// sameHeaders reports whether two inputs have the same columns in the same
+// order, going by the case sensitivity the headers are matched with
+func sameHeaders(a, b []string, caseInsensitive bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if headerKey(a[i], caseInsensitive) != headerKey(b[i], caseInsensitive) {
+			return false
+		}
+	}
+	return true
+}
+
+func transform(cfg *Config, in io.Reader, out io.Writer, hook func(*Record) error) error {
+	_, err := transformInputs(cfg, []input{{r: in}}, out, hook)
+	return err
+}
+
+// runCounts is how many rows went which way, for anything that wants to
+// report on a run
+type runCounts struct {
+	// Data rows read, not counting blank lines or the header
+	Read int `json:"read"`
+	// Rows written out, rejected because they wouldn't normalize, and
+	// dropped on purpose, by the hook with ErrSkip or -on-duplicate-timestamp
+	Written  int `json:"written"`
+	Rejected int `json:"rejected"`
+	Skipped  int `json:"skipped"`
+	// Inputs -header-mismatch-policy skip-file left out
+	SkippedFiles int `json:"skipped_files"`
+	// Fields read, and how many of those had invalid UTF-8 we had to repair
+	Fields         int `json:"fields"`
+	RepairedFields int `json:"repaired_fields"`
+	// Rejected broken down by errorType, and Skipped by one of the
+	// skipReason* constants
+	RejectedByType  map[string]int `json:"rejected_by_type,omitempty"`
+	SkippedByReason map[string]int `json:"skipped_by_reason,omitempty"`
+}
+
+// Why rows were skipped, for runCounts.SkippedByReason
+const (
+	skipReasonHook      = "hook"
+	skipReasonSkipKeys  = "skip_keys"
+	skipReasonDuplicate = "duplicate"
+)
+
+// reject counts a rejected row
+func (c *runCounts) reject(err error) {
+	c.Rejected++
+	if c.RejectedByType == nil {
+		c.RejectedByType = make(map[string]int)
+	}
+	c.RejectedByType[errorType(err)]++
+}
+
+// skip counts a skipped row
+func (c *runCounts) skip(reason string) {
+	c.Skipped++
+	if c.SkippedByReason == nil {
+		c.SkippedByReason = make(map[string]int)
+	}
+	c.SkippedByReason[reason]++
+}
+
+// minRepairSample is how many rows we read before -max-utf8-replacement-rate
+// starts checking, so one bad field near the top can't end the run. At the
+// end it's checked regardless
+const minRepairSample = 100
+
+// checkRepairRate returns an error if too many fields needed UTF-8 repair
+func (c runCounts) checkRepairRate(cfg *Config) error {
+	if cfg.MaxUTF8ReplacementRate <= 0 || c.Fields == 0 {
+		return nil
+	}
+	rate := float64(c.RepairedFields) / float64(c.Fields)
+	if rate <= cfg.MaxUTF8ReplacementRate {
+		return nil
+	}
+	return fmt.Errorf("%d of %d fields (%.1f%%) had invalid UTF-8, more than -max-utf8-replacement-rate allows; is the input in some other encoding?", c.RepairedFields, c.Fields, rate*100)
+}
+
+// Values for -on-duplicate-timestamp
+const (
+	duplicateKeepAll   = "keep-all"
+	duplicateKeepFirst = "keep-first"
+	duplicateKeepLast  = "keep-last"
+)
+
+// windowKey is a timestamp keep-first is remembering under -dedupe-window,
+// kept in the order they were first seen so the oldest can be forgotten
+type windowKey struct {
+	key string
+	at  time.Time
+}
+
+// transformInputs reads each of inputs in turn and writes them all out as
+// one. Inputs after the first are held to the first one's header according
+// to -header-mismatch-policy. The counts are good as far as we got, even if
+// there's an error
+func transformInputs(cfg *Config, inputs []input, out io.Writer, hook func(*Record) error) (runCounts, error) {
+	var counts runCounts
+
+	// Set when -max-rows or -time-limit stops us early. We still finish off
+	// the output and reports as normal, then say so
+	limitHit := false
+	timeHit := false
+	var deadline time.Time
+	if cfg.TimeLimit > 0 {
+		deadline = time.Now().Add(cfg.TimeLimit)
+	}
+
+	// The idleReader's reads happen on their own goroutine, so it can stop
+	// waiting on an input that's gone quiet, whether that's for
+	// -idle-timeout or because the -time-limit is up
+	if cfg.streaming() || cfg.TimeLimit > 0 {
+		for i := range inputs {
+			idle := newIdleReader(inputs[i].name, inputs[i].r, cfg.Follow, cfg.IdleTimeout)
+			idle.deadline = deadline
+			defer idle.Close()
+			inputs[i].r = idle
+		}
+	}
+
+	// Look at the first header before writing anything, so a bad one doesn't
+	// leave half an output behind
+	first, err := openMapped(cfg, inputs[0].r, nil)
+	if err != nil {
+		return counts, inputError(inputs[0].name, err)
+	}
+
+	// The checkpoint also says how much output there was, so a resume can
+	// cut off anything written after it
+	var written *countingWriter
+	if cfg.Checkpoint != "" {
+		written = &countingWriter{w: out}
+		if cfg.resume != nil {
+			written.n = cfg.resume.Bytes
+		}
+		out = written
+	}
+
+	// Excel wants the BOM to tell it the file's UTF-8. It goes out first of
+	// all, and the footer checksum never sees it
+	if cfg.OutputBOM && !cfg.resuming() {
+		if _, err := io.WriteString(out, "\ufeff"); err != nil {
+			return counts, fmt.Errorf("unexpected error writing output: %w", err)
+		}
+	}
+
+	if cfg.ProvenanceComment && !cfg.resuming() {
+		if err := writeProvenance(out, cfg, time.Now()); err != nil {
+			return counts, err
+		}
+	}
+
+	// The footer checksum only covers data rows, so it starts after the header
+	// has been flushed out
+	var checksum *checksumWriter
+	if cfg.Footer {
+		checksum = newChecksumWriter(out)
+		out = checksum
+	}
+
+	// With -preserve-input-order the columns go out in the order the first
+	// input had them, and every input after it is written the same way.
+	// -drop-full-name leaves one out
+	var order []int
+	if cfg.PreserveInputOrder {
+		order = first.inputOrder()
+	}
+	if cfg.DropFullName {
+		order = withoutColumn(order, columnIndex("FullName"))
+	}
+	columns := append(append([]string(nil), outputHeaders(order)...), cfg.ExtraColumns()...)
+	sink, err := newSink(cfg, out, columns)
+	if err != nil {
+		return counts, err
+	}
+	// Only write a header if the input had one, or we were asked to, and not
+	// when the output we're adding on to already has it
+	if (!cfg.NoHeader || cfg.WriteHeader || cfg.InputFormat == inputFormatFixed) && !cfg.resuming() {
+		sink.WriteHeader(columns)
+	}
+	if checksum != nil {
+		if err := sink.Flush(); err != nil {
+			return counts, fmt.Errorf("unexpected error writing output: %w", err)
+		}
+		checksum.started = true
+	}
+
+	// Rejected rows, only collected if someone asked for the report
+	var rejected []ReportEntry
+	var summary *errorSummary
+	if cfg.PrettyErrors {
+		summary = newErrorSummary()
+	}
+	var stream *errorStream
+	if cfg.ErrorStream != "" {
+		stream, err = openErrorStream(cfg.ErrorStream)
+		if err != nil {
+			sink.Close()
+			return counts, err
+		}
+		defer stream.Close()
+	}
+	var profile *columnsReport
+	if cfg.ColumnsReport != "" {
+		profile = newColumnsReport(columns)
+	}
+	var stats durationStats
+	layouts := newLayoutStats(cfg.TimestampLayouts)
+
+	// With -rate we hold each write until the ticker says we can go, which
+	// caps how fast records flow downstream
+	var throttle <-chan time.Time
+	if cfg.Rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.Rate))
+		defer ticker.Stop()
+		throttle = ticker.C
+	}
+
+	// -metrics-interval lines get written in the background while we go
+	var metrics *metricsLogger
+	if cfg.MetricsInterval > 0 {
+		metrics, err = newMetricsLogger(cfg.MetricsFile, cfg.MetricsInterval)
+		if err != nil {
+			return counts, err
+		}
+		defer metrics.Close()
+	}
+
+	// Rows written that aren't -annotate-errors rejects, for -fail-on-empty
+	goodWritten := 0
+	write := func(record *Record, good bool) {
+		if good && cfg.Stats {
+			stats.Add(record.totalDuration)
+			layouts.Add(record.timestampLayout)
+		}
+		if throttle != nil {
+			<-throttle
+		}
+		record.order = order
+		if good && profile != nil {
+			profile.Add(record.Row(cfg.ExtraColumns()))
+		}
+		err := sink.WriteRecord(record)
+		if err == nil && (throttle != nil || cfg.streaming()) {
+			// Otherwise the sink's buffer would undo the throttling, or hold
+			// rows back until an input that's gone quiet picks up again
+			err = sink.Flush()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "unexpected error writing fields: ", err.Error())
+		} else {
+			counts.Written++
+			if good {
+				goodWritten++
+			}
+		}
+	}
+
+	// State for -on-duplicate-timestamp. keep-first only has to remember the
+	// timestamps it's seen, but keep-last holds every record until the end
+	seen := make(map[string]bool)
+	lastIndex := make(map[string]int)
+	var held []*Record
+
+	// With -dedupe-window, keep-first forgets timestamps that have fallen
+	// more than the window behind the newest one. On roughly sorted input
+	// that's the front of the queue, so we only ever look there
+	var window []windowKey
+	var newest time.Time
+	forget := func(at time.Time) {
+		if at.After(newest) {
+			newest = at
+		}
+		cutoff := newest.Add(-cfg.DedupeWindow)
+		for len(window) > 0 && window[0].at.Before(cutoff) {
+			delete(seen, window[0].key)
+			window = window[1:]
+		}
+	}
+
+	// With -assume-sorted, rows with the same timestamp all come together, so
+	// once it moves on nothing from before can turn up again. seen and held
+	// only ever cover the latest one
+	var latest string
+	var latestAt time.Time
+	sorted := func(record *Record) error {
+		if record.Timestamp == latest {
+			return nil
+		}
+		if !cfg.NoVerifySorted && record.timestamp.Before(latestAt) {
+			return fmt.Errorf("line %d: %w: %s comes after %s", record.line, ErrNotSorted, record.Timestamp, latest)
+		}
+		latest, latestAt = record.Timestamp, record.timestamp
+		if len(seen) > 0 {
+			seen = make(map[string]bool)
+		}
+		for _, record := range held {
+			if record != nil {
+				write(record, record.Extra[annotateErrorsColumn] == "")
+			}
+		}
+		held = held[:0]
+		if len(lastIndex) > 0 {
+			lastIndex = make(map[string]int)
+		}
+		return nil
+	}
+
+	// -checkpoint goes by the last input line we've finished with. The rows
+	// written since the last one are flushed out first, so the output is
+	// always at least as far along as the checkpoint says
+	var done checkpoint
+	if cfg.Checkpoint != "" {
+		done.Input = checkpointInput(cfg.Inputs)
+		if cfg.resume != nil {
+			done = *cfg.resume
+		}
+	}
+	sinceCheckpoint := 0
+	saveCheckpoint := func() error {
+		if err := sink.Flush(); err != nil {
+			return fmt.Errorf("unexpected error writing output: %w", err)
+		}
+		done.Written += counts.Written - sinceCheckpoint
+		sinceCheckpoint = counts.Written
+		done.Bytes = written.n
+		if err := writeCheckpoint(cfg.Checkpoint, done); err != nil {
+			return fmt.Errorf("unable to write -checkpoint: %w", err)
+		}
+		return nil
+	}
+
+inputLoop:
+	for i, in := range inputs {
+		opened := first
+		if i > 0 {
+			// With -columns-from-first-file only the first input has a
+			// header, and the rest have their columns in the same order
+			var known []string
+			if cfg.ColumnsFromFirstFile {
+				known = first.headers
+			}
+			opened, err = openMapped(cfg, in.r, known)
+			if err != nil {
+				sink.Close()
+				return counts, inputError(in.name, err)
+			}
+			if !sameHeaders(first.headers, opened.headers, cfg.CaseInsensitiveHeaders) {
+				switch cfg.HeaderMismatchPolicy {
+				case headerMismatchError:
+					sink.Close()
+					return counts, fmt.Errorf("%s: header doesn't match the first input's, see -header-mismatch-policy", in.name)
+				case headerMismatchSkipFile:
+					fmt.Fprintln(os.Stderr, "skipping ", in.name, ": header doesn't match the first input's")
+					counts.SkippedFiles++
+					continue
+				}
+				// remap: openMapped already worked out where this file keeps
+				// each column, so there's nothing more to do
+			}
+		}
+		rows, width := opened.rows, opened.width
+
+		fields, err := rows.Read()
+		for err == nil {
+			// Skip totally empty lines
+			if fields != nil {
+				if cfg.Preview > 0 && counts.Written >= cfg.Preview {
+					// That's all -preview shows, and it isn't an error
+					break inputLoop
+				}
+				if cfg.MaxRows > 0 && counts.Read >= cfg.MaxRows {
+					// There's more than we're allowed
+					limitHit = true
+					break inputLoop
+				}
+				if cfg.TimeLimit > 0 && time.Now().After(deadline) {
+					// Reads that are still waiting when it's up are stopped
+					// by the idleReader, below
+					timeHit = true
+					break inputLoop
+				}
+
+				// Line has to be asked before the next Read
+				lineNum := rows.Line()
+				if cfg.resume != nil && lineNum <= cfg.resume.Line {
+					// Done by the run we're resuming
+					fields, err = rows.Read()
+					continue
+				}
+				counts.Read++
+				if metrics != nil {
+					metrics.AddRow()
+				}
+
+				var record *Record
+				fields, err := fitRow(fields, width, opened.delimiter, cfg)
+				if err == nil {
+					record = opened.newRecord(fields, cfg)
+					record.line = lineNum
+					if opened.offsets != nil {
+						opened.setOffset(record, lineNum)
+					}
+					counts.Fields += record.fieldCount
+					counts.RepairedFields += record.repaired
+					if counts.Read >= minRepairSample {
+						if err := counts.checkRepairRate(cfg); err != nil {
+							sink.Close()
+							return counts, inputError(in.name, err)
+						}
+					}
+
+					// Debug output, can remove
+					// fmt.Printf("%+v\n", record)
+
+					err = record.Normalize(cfg)
+					if err == nil && cfg.skipKeys != nil && cfg.skipKeys[record.skipKey(cfg.keyColumns)] {
+						err = errSkipKey
+					}
+					if err == nil && hook != nil {
+						err = hook(record)
+					}
+				}
+				if err == nil && cfg.AssumeSorted {
+					if err := sorted(record); err != nil {
+						sink.Close()
+						return counts, inputError(in.name, err)
+					}
+				}
+				if errors.Is(err, ErrSkip) {
+					// The hook or -skip-keys asked us to quietly drop this one
+					if err == errSkipKey {
+						counts.skip(skipReasonSkipKeys)
+					} else {
+						counts.skip(skipReasonHook)
+					}
+				} else if err != nil {
+					// A partially normalized record is no use to anyone, so warn
+					// and drop the row
+					counts.reject(err)
+					if metrics != nil {
+						metrics.AddError()
+					}
+					line := strings.Join(fields, ",") // rebuild the line so we can render the one with the error
+					if in.name != "" {
+						fmt.Fprint(os.Stderr, in.name, ": ")
+					}
+					fmt.Fprintln(os.Stderr, "normalization error: ", err.Error(), " for line \"", line, "\"")
+					if cfg.ErrorReport != "" || summary != nil || stream != nil {
+						entry := newReportEntry(lineNum, err)
+						entry.addRaw(record)
+						entry.File = in.name
+						if stream != nil {
+							stream.Write(entry)
+						}
+						if cfg.ErrorReport != "" {
+							rejected = append(rejected, entry)
+						}
+						if summary != nil {
+							summary.Add(entry)
+						}
+					}
+					if cfg.AnnotateErrors {
+						annotated := annotatedRecord(record, fields, opened, cfg, lineNum, err)
+						if cfg.OnDuplicateTimestamp == duplicateKeepLast {
+							// Kept in its place among the held rows
+							held = append(held, annotated)
+						} else {
+							write(annotated, false)
+						}
+					}
+				} else if cfg.OnDuplicateTimestamp == duplicateKeepFirst {
+					if cfg.DedupeWindow > 0 {
+						forget(record.timestamp)
+					}
+					if seen[record.Timestamp] {
+						counts.skip(skipReasonDuplicate)
+					} else {
+						seen[record.Timestamp] = true
+						if cfg.DedupeWindow > 0 {
+							window = append(window, windowKey{record.Timestamp, record.timestamp})
+						}
+						write(record, true)
+					}
+				} else if cfg.OnDuplicateTimestamp == duplicateKeepLast {
+					// We can't know a row is the last with its timestamp until
+					// we've read everything, so these all wait until the end.
+					// An earlier one is blanked out rather than removed, which
+					// leaves the rest in input order
+					if earlier, ok := lastIndex[record.Timestamp]; ok {
+						held[earlier] = nil
+						counts.skip(skipReasonDuplicate)
+					}
+					lastIndex[record.Timestamp] = len(held)
+					held = append(held, record)
+				} else {
+					write(record, true)
+				}
+
+				// Debug output, can remove
+				// fmt.Printf("%+v\n", record)
+
+				if cfg.Checkpoint != "" {
+					done.Line = lineNum
+					if counts.Read%checkpointEvery == 0 {
+						if err := saveCheckpoint(); err != nil {
+							sink.Close()
+							return counts, err
+						}
+					}
+				}
+			}
+
+			fields, err = rows.Read()
+		}
+		if errors.Is(err, ErrTimeLimit) {
+			// The time ran out waiting on the input. Whatever of a row had
+			// arrived is dropped
+			timeHit = true
+			break inputLoop
+		}
+		// reader returns io.EOF if everything went well
+		if err != nil && err != io.EOF {
+			// Still finish off what we did manage to write
+			sink.Close()
+			return counts, inputError(in.name, fmt.Errorf("unexpected error: %w", err))
+		}
+	}
+
+	if err := counts.checkRepairRate(cfg); err != nil {
+		// Everything's been read, so this is the last chance to fail
+		sink.Close()
+		return counts, err
+	}
+
+	for _, record := range held {
+		if record != nil {
+			write(record, record.Extra[annotateErrorsColumn] == "")
+		}
+	}
+
+	if cfg.Checkpoint != "" {
+		if err := saveCheckpoint(); err != nil {
+			sink.Close()
+			return counts, err
+		}
+	}
+	if err := sink.Close(); err != nil {
+		return counts, fmt.Errorf("unexpected error writing output: %w", err)
+	}
+	if checksum != nil {
+		if err := writeFooter(checksum.w, counts.Written, checksum.crc.Sum32()); err != nil {
+			return counts, fmt.Errorf("unexpected error writing footer: %w", err)
+		}
+	}
+
+	if cfg.Stats {
+		stats.Print(os.Stderr)
+		if !cfg.NoNormalizeTimestamp {
+			layouts.Print(os.Stderr)
+		}
+	}
+	if summary != nil {
+		summary.Print(os.Stderr)
+	}
+
+	if cfg.ErrorReport != "" {
+		if err := writeErrorReport(cfg.ErrorReport, rejected); err != nil {
+			fmt.Fprintln(os.Stderr, "unable to write error report: ", err.Error())
+		}
+	}
+	if profile != nil {
+		if err := profile.write(cfg.ColumnsReport); err != nil {
+			fmt.Fprintln(os.Stderr, "unable to write columns report: ", err.Error())
+		}
+	}
+	if compare, ok := sink.(*compareSink); ok && compare.mismatches > 0 {
+		return counts, fmt.Errorf("%w: %d mismatched", ErrMismatch, compare.mismatches)
+	}
+	if cfg.FailOnEmpty && goodWritten == 0 {
+		return counts, fmt.Errorf("%w: read %d, rejected %d", ErrEmptyOutput, counts.Read, counts.Rejected)
+	}
+	if timeHit {
+		return counts, fmt.Errorf("%w: stopped after %s with %d rows read", ErrTimeLimit, cfg.TimeLimit, counts.Read)
+	}
+	if limitHit {
+		return counts, fmt.Errorf("%w: stopped after %d rows", ErrMaxRows, cfg.MaxRows)
+	}
+	return counts, nil
+}
@@ -0,0 +1,187 @@
+package normalize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Column types a -schema file can declare
+const (
+	columnTypeString    = "string"
+	columnTypeInt       = "int"
+	columnTypeZip       = "zip"
+	columnTypeTimestamp = "timestamp"
+	columnTypeDuration  = "duration"
+)
+
+// A schema file describes the input columns, e.g.
+//
+//	{
+//	  "columns": {
+//	    "ZIP": {"type": "zip"},
+//	    "Timestamp": {"type": "timestamp"},
+//	    "TotalDuration": {"type": "duration"}
+//	  }
+//	}
+//
+// Columns are canonical names, in any case, and ones it leaves out aren't
+// checked
+type Schema struct {
+	Columns map[string]ColumnSchema `json:"columns"`
+
+	// Declared types and steps by canonical column position, filled in by
+	// loadSchema
+	types []string
+	steps [][]string
+}
+
+// ColumnSchema is what a schema says about one column: the type it has to be,
+// if any, and for Address, FullName and Notes, the order to run their
+// normalization steps in (see defaultTextSteps)
+type ColumnSchema struct {
+	Type  string   `json:"type,omitempty"`
+	Steps []string `json:"steps,omitempty"`
+}
+
+func loadSchema(path string) (*Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read -schema: %w", err)
+	}
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("can't parse -schema %s: %w", path, err)
+	}
+	s.types = make([]string, len(canonicalHeaders))
+	s.steps = make([][]string, len(canonicalHeaders))
+	for name, col := range s.Columns {
+		i := columnIndex(name)
+		if i < 0 {
+			return nil, fmt.Errorf("-schema %s: unknown column %q", path, name)
+		}
+		switch col.Type {
+		case "", columnTypeString, columnTypeInt, columnTypeZip, columnTypeTimestamp, columnTypeDuration:
+		default:
+			return nil, fmt.Errorf("-schema %s: unknown type %q for %s", path, col.Type, name)
+		}
+		s.types[i] = col.Type
+		if col.Steps != nil {
+			if err := checkSteps(i, col.Steps); err != nil {
+				return nil, fmt.Errorf("-schema %s: %s: %w", path, name, err)
+			}
+			s.steps[i] = col.Steps
+		}
+	}
+	return &s, nil
+}
+
+// zipPattern is what a zip looks like before it's been padded
+var zipPattern = regexp.MustCompile(`^[0-9]{1,5}$`)
+
+// checkTypes holds each of the record's fields, as read, to the type the
+// schema declares for it
+func (r *Record) checkTypes(cfg *Config) error {
+	for i, value := range r.Fields() {
+		typ := cfg.schema.types[i]
+		if typ != "" && !fitsType(value, typ, cfg) {
+			return &FieldError{Field: canonicalHeaders[i], Value: value, Err: fmt.Errorf("%w %s", ErrType, typ)}
+		}
+	}
+	return nil
+}
+
+// fitsType is whether value, as read, is a typ
+func fitsType(value string, typ string, cfg *Config) bool {
+	switch typ {
+	case columnTypeInt:
+		_, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		return err == nil
+	case columnTypeZip:
+		return zipPattern.MatchString(value)
+	case columnTypeTimestamp:
+		_, err := parseTimestamp(value, cfg)
+		return err == nil
+	case columnTypeDuration:
+		_, err := parseInputDuration(value, cfg)
+		return err == nil
+	}
+	return true
+}
+
+// inferredTypes are the types -infer-schema tries, pickiest first. A column
+// gets the first one every sampled value fits, and string if none of them do
+var inferredTypes = []string{columnTypeTimestamp, columnTypeDuration, columnTypeZip, columnTypeInt}
+
+// defaultInferRows is how many rows -infer-schema looks at unless told
+// otherwise
+const defaultInferRows = 100
+
+// inferSchema reads up to limit rows of in and works out a schema they'd all
+// pass. Rows that are the wrong width are left out, the same as they'd be
+// rejected, but a single odd value anywhere else is enough to make its
+// column a string, so it's worth a look before it's used
+func inferSchema(cfg *Config, in io.Reader, limit int) (*Schema, error) {
+	opened, err := openMapped(cfg, in, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Which of inferredTypes each column still fits
+	fits := make([][]bool, len(canonicalHeaders))
+	for i := range fits {
+		fits[i] = make([]bool, len(inferredTypes))
+		for j := range fits[i] {
+			fits[i][j] = true
+		}
+	}
+
+	sampled := 0
+	fields, err := opened.rows.Read()
+	for err == nil && sampled < limit {
+		if fields != nil {
+			if fields, err := fitRow(fields, opened.width, opened.delimiter, cfg); err == nil {
+				sampled++
+				for i, value := range opened.newRecord(fields, cfg).Fields() {
+					for j, typ := range inferredTypes {
+						if fits[i][j] && !fitsType(value, typ, cfg) {
+							fits[i][j] = false
+						}
+					}
+				}
+			}
+		}
+		fields, err = opened.rows.Read()
+	}
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("unexpected error: %w", err)
+	}
+	if sampled == 0 {
+		return nil, fmt.Errorf("no rows to infer a schema from")
+	}
+
+	s := &Schema{Columns: make(map[string]ColumnSchema)}
+	for i, name := range canonicalHeaders {
+		typ := columnTypeString
+		for j, ok := range fits[i] {
+			if ok {
+				typ = inferredTypes[j]
+				break
+			}
+		}
+		s.Columns[name] = ColumnSchema{Type: typ}
+	}
+	return s, nil
+}
+
+// writeSchema writes s to path in the form loadSchema reads
+func writeSchema(path string, s *Schema) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,172 @@
+package normalize
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestFile writes data to a file called name in a temporary directory
+// and returns its path
+func writeTestFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFitsType(t *testing.T) {
+	cfg := testConfig(t)
+	tests := []struct {
+		value string
+		typ   string
+		want  bool
+	}{
+		{"anything", columnTypeString, true},
+		{"42", columnTypeInt, true},
+		{" -7 ", columnTypeInt, true},
+		{"4.2", columnTypeInt, false},
+		{"501", columnTypeZip, true},
+		{"94121", columnTypeZip, true},
+		{"941211", columnTypeZip, false},
+		{"9412a", columnTypeZip, false},
+		{"4/1/11 11:00:00 AM", columnTypeTimestamp, true},
+		{"2011-04-01", columnTypeTimestamp, false},
+		{"1:23:32.123", columnTypeDuration, true},
+		{"zzsasdfa", columnTypeDuration, false},
+	}
+	for _, tt := range tests {
+		if got := fitsType(tt.value, tt.typ, cfg); got != tt.want {
+			t.Errorf("fitsType(%q, %s) = %v, want %v", tt.value, tt.typ, got, tt.want)
+		}
+	}
+}
+
+func TestLoadSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		want   string
+	}{
+		{"not json", `{"columns":`, "can't parse -schema"},
+		{"unknown column", `{"columns": {"Email": {"type": "string"}}}`, `unknown column "Email"`},
+		{"unknown type", `{"columns": {"ZIP": {"type": "postcode"}}}`, `unknown type "postcode"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadSchema(writeTestFile(t, "schema.json", tt.schema))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error like %q", err, tt.want)
+			}
+		})
+	}
+	if _, err := loadSchema(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("a missing schema: got no error")
+	}
+}
+
+func TestSchemaChecksRows(t *testing.T) {
+	schema := writeTestFile(t, "schema.json", `{"columns": {"zip": {"type": "zip"}, "Notes": {"type": "int"}}}`)
+	tests := []struct {
+		name  string
+		zip   string
+		notes string
+		field string
+	}{
+		{"fits", "501", "12", ""},
+		{"bad zip", "ABC12", "12", "ZIP"},
+		{"bad int", "501", "twelve", "Notes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := strings.Replace(testRow, "94121", tt.zip, 1)
+			row = strings.Replace(row, "notes", tt.notes, 1)
+			entries := readErrorReport(t, testHeader+row, "-schema", schema)
+			switch {
+			case tt.field == "" && len(entries) != 0:
+				t.Errorf("got %+v, want the row written", entries)
+			case tt.field != "" && (len(entries) != 1 || entries[0].Field != tt.field || entries[0].Type != "type"):
+				t.Errorf("got %+v, want a type error in %s", entries, tt.field)
+			}
+		})
+	}
+}
+
+func TestInferSchema(t *testing.T) {
+	short := "4/1/11 11:00:00 AM,123 4th St\n"
+	odd := strings.Replace(testRow, "1:23:32.123", "soon", 1)
+	// Short rows get as far as fitRow if the reader lets them through
+	lenient := []string{"-truncate-long-rows"}
+	tests := []struct {
+		name    string
+		args    []string
+		in      string
+		limit   int
+		want    map[string]string
+		wantErr bool
+	}{
+		{"typed", nil, testHeader + testRow, 100, map[string]string{
+			"Timestamp":   columnTypeTimestamp,
+			"Address":     columnTypeString,
+			"ZIP":         columnTypeZip,
+			"FooDuration": columnTypeDuration,
+			"Notes":       columnTypeString,
+		}, false},
+		{"one odd value", nil, testHeader + testRow + odd, 100, map[string]string{"FooDuration": columnTypeString}, false},
+		// The odd row is past the sample
+		{"limited", nil, testHeader + testRow + odd, 1, map[string]string{"FooDuration": columnTypeDuration}, false},
+		{"wrong width left out", lenient, testHeader + short + testRow, 100, map[string]string{"Timestamp": columnTypeTimestamp}, false},
+		{"short row left out", nil, testHeader + testRow + short + testRow, 100, map[string]string{"Timestamp": columnTypeTimestamp, "ZIP": columnTypeZip}, false},
+		{"no rows", nil, testHeader, 100, nil, true},
+		{"only wrong width", lenient, testHeader + short, 100, nil, true},
+		{"only short rows", nil, testHeader + short + short, 100, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema, err := inferSchema(testConfig(t, tt.args...), strings.NewReader(tt.in), tt.limit)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want an error", schema)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(schema.Columns) != len(canonicalHeaders) {
+				t.Errorf("got %d columns, want %d", len(schema.Columns), len(canonicalHeaders))
+			}
+			for name, typ := range tt.want {
+				if got := schema.Columns[name].Type; got != typ {
+					t.Errorf("%s: got %s, want %s", name, got, typ)
+				}
+			}
+		})
+	}
+}
+
+func TestInferSchemaFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	stdout, stderr, status := runMain(t, testHeader+testRow, "-infer-schema", path)
+	if status != 0 {
+		t.Fatalf("exit status %d: %s", status, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("wrote %q, want nothing normalized", stdout)
+	}
+	// What it writes is a schema -schema takes, and the sample passes it
+	cfg := testConfig(t, "-schema", path)
+	if got := outputLines(t, cfg, testHeader+testRow); len(got) != 2 {
+		t.Errorf("got %q, want the row to pass its own schema", got)
+	}
+
+	if _, _, status := runMain(t, testHeader+testRow, "-infer-schema", path, "-infer-schema-rows", "0"); status != 2 {
+		t.Errorf("-infer-schema-rows 0: exit status %d, want 2", status)
+	}
+	if _, _, status := runMain(t, testHeader, "-infer-schema", path); status != 1 {
+		t.Errorf("no rows: exit status %d, want 1", status)
+	}
+}
@@ -0,0 +1,84 @@
+package normalize
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Values for -zip-format
+const (
+	zipFormatUS          = "us"
+	zipFormatCA          = "ca"
+	zipFormatUK          = "uk"
+	zipFormatPassthrough = "passthrough"
+)
+
+// Values for -zip-mode, which is how -zip-format us writes a zip
+const (
+	// Five digits, zeroes added on the left, what the spec asks for
+	zipModePad5 = "pad5"
+	// Leading zeroes taken off, for consumers that store zips as integers
+	zipModeStrip = "strip"
+	// Five digits, or ZIP+4 as 12345-6789
+	zipModePlus4 = "plus4"
+)
+
+// What Canadian and UK postcodes look like once normalizeZip has uppercased
+// them and taken the spaces out. The UK one is the general shape rather than
+// a check against the real list of areas
+var (
+	caPostcode = regexp.MustCompile(`^[A-Z][0-9][A-Z][0-9][A-Z][0-9]$`)
+	ukPostcode = regexp.MustCompile(`^[A-Z]{1,2}[0-9][A-Z0-9]?[0-9][A-Z]{2}$`)
+	digits     = regexp.MustCompile(`^[0-9]+$`)
+	// ZIP+4, with the halves written together or split by a hyphen or space
+	zipPlus4 = regexp.MustCompile(`^([0-9]{5})[- ]?([0-9]{4})$`)
+)
+
+// normalizeZip tidies up a postal code the way that country writes them, or
+// returns ErrZip if it isn't one. US zips are written the way mode says, and
+// are only checked for the modes that need it, so pad5 takes anything
+func normalizeZip(zip, format, mode string) (string, error) {
+	switch format {
+	case zipFormatPassthrough:
+		return zip, nil
+	case zipFormatCA, zipFormatUK:
+		// Both are written as two halves with a space between, the second
+		// always three characters, whatever spacing the input used
+		compact := strings.ToUpper(strings.Join(strings.Fields(zip), ""))
+		pattern := caPostcode
+		if format == zipFormatUK {
+			pattern = ukPostcode
+		}
+		if !pattern.MatchString(compact) {
+			return zip, ErrZip
+		}
+		return compact[:len(compact)-3] + " " + compact[len(compact)-3:], nil
+	}
+
+	switch mode {
+	case zipModeStrip:
+		if !digits.MatchString(zip) {
+			return zip, ErrZip
+		}
+		if stripped := strings.TrimLeft(zip, "0"); stripped != "" {
+			return stripped, nil
+		}
+		return "0", nil
+	case zipModePlus4:
+		if m := zipPlus4.FindStringSubmatch(zip); m != nil {
+			return m[1] + "-" + m[2], nil
+		}
+		if !zipPattern.MatchString(zip) {
+			return zip, ErrZip
+		}
+	}
+
+	// Pad zips shorter than 5 digits with zeroes on the left. This used to
+	// be Sprintf("%05s"), which counts characters rather than bytes, so
+	// this does too
+	if n := utf8.RuneCountInString(zip); n < 5 {
+		zip = strings.Repeat("0", 5-n) + zip
+	}
+	return zip, nil
+}
@@ -0,0 +1,142 @@
+package normalize
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeZip(t *testing.T) {
+	tests := []struct {
+		zip     string
+		format  string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{"501", zipFormatUS, zipModePad5, "00501", false},
+		{"94121", zipFormatUS, zipModePad5, "94121", false},
+		{"00501", zipFormatUS, zipModeStrip, "501", false},
+		{"00000", zipFormatUS, zipModeStrip, "0", false},
+		{"5O1", zipFormatUS, zipModeStrip, "", true},
+		{"12345 6789", zipFormatUS, zipModePlus4, "12345-6789", false},
+		{"123456789", zipFormatUS, zipModePlus4, "12345-6789", false},
+		{"501", zipFormatUS, zipModePlus4, "00501", false},
+		{"1234-5", zipFormatUS, zipModePlus4, "", true},
+		{"k1a0b1", zipFormatCA, zipModePad5, "K1A 0B1", false},
+		{"K1A  0B1", zipFormatCA, zipModePad5, "K1A 0B1", false},
+		{"12345", zipFormatCA, zipModePad5, "", true},
+		{"sw1a1aa", zipFormatUK, zipModePad5, "SW1A 1AA", false},
+		{"M1 1AE", zipFormatUK, zipModePad5, "M1 1AE", false},
+		{"anything", zipFormatPassthrough, zipModePad5, "anything", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.mode+"/"+tt.zip, func(t *testing.T) {
+			got, err := normalizeZip(tt.zip, tt.format, tt.mode)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("got %q, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func BenchmarkNormalizeZip(b *testing.B) {
+	b.Run("strings", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			normalizeZip("501", zipFormatUS, zipModePad5)
+		}
+	})
+	// What padding every zip cost before
+	b.Run("sprintf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = fmt.Sprintf("%05s", "501")
+		}
+	})
+}
+
+func TestZipFormatFlag(t *testing.T) {
+	tests := []struct {
+		format string
+		zip    string
+		want   string
+	}{
+		{"us", "501", "00501"},
+		{"ca", "k1a0b1", "K1A 0B1"},
+		{"uk", "sw1a  1aa", "SW1A 1AA"},
+		{"uk", "501", ""},
+		{"passthrough", "sw1a  1aa", "sw1a  1aa"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.zip, func(t *testing.T) {
+			row := strings.Replace(testRow, "94121", tt.zip, 1)
+			entries := readErrorReport(t, testHeader+row, "-zip-format", tt.format)
+			records := outputRecords(t, testConfig(t, "-zip-format", tt.format), testHeader+row)
+			if tt.want == "" {
+				if len(records) != 1 || len(entries) != 1 || entries[0].Type != "zip" {
+					t.Errorf("got %v and %+v, want a zip error", records[1:], entries)
+				}
+				return
+			}
+			if len(records) != 2 || records[1][2] != tt.want {
+				t.Errorf("got %v, want ZIP %q", records[1:], tt.want)
+			}
+		})
+	}
+	if err := configError(t, "-zip-format", "de"); err == nil {
+		t.Error("-zip-format de: got no error")
+	}
+}
+
+func TestZipModeFlag(t *testing.T) {
+	tests := []struct {
+		mode string
+		zip  string
+		want string
+	}{
+		{"pad5", "501", "00501"},
+		{"strip", "00501", "501"},
+		{"strip", "5O1", ""},
+		{"plus4", "94121 1234", "94121-1234"},
+		{"plus4", "9412-1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode+"/"+tt.zip, func(t *testing.T) {
+			row := strings.Replace(testRow, "94121", tt.zip, 1)
+			entries := readErrorReport(t, testHeader+row, "-zip-mode", tt.mode)
+			records := outputRecords(t, testConfig(t, "-zip-mode", tt.mode), testHeader+row)
+			if tt.want == "" {
+				if len(records) != 1 || len(entries) != 1 || entries[0].Type != "zip" {
+					t.Errorf("got %v and %+v, want a zip error", records[1:], entries)
+				}
+				return
+			}
+			if len(records) != 2 || records[1][2] != tt.want {
+				t.Errorf("got %v, want ZIP %q", records[1:], tt.want)
+			}
+		})
+	}
+
+	errs := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-zip-mode", "pad9"}, "unknown -zip-mode"},
+		{[]string{"-zip-mode", "strip", "-zip-format", "uk"}, "-zip-format us only"},
+	}
+	for _, tt := range errs {
+		if err := configError(t, tt.args...); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: got error %v, want one containing %q", tt.args, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package normalize
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadZipAllowlist reads a -zip-allowlist file: one ZIP per line, with blank
+// lines and lines starting with # ignored. Each one is normalized the way the
+// ZIP column is, so 501 in the file matches the 00501 we write
+func loadZipAllowlist(path, format, mode string) (map[string]bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("can't read -zip-allowlist: %w", err)
+	}
+	defer f.Close()
+
+	allowed := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		zip := strings.TrimSpace(scanner.Text())
+		if zip == "" || strings.HasPrefix(zip, "#") {
+			continue
+		}
+		normalized, err := normalizeZip(zip, format, mode)
+		if err != nil {
+			return nil, fmt.Errorf("-zip-allowlist %s line %d: %q isn't a zip", path, line, zip)
+		}
+		allowed[normalized] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("can't read -zip-allowlist: %w", err)
+	}
+	if len(allowed) == 0 {
+		// Everything would be rejected, which can't be what anyone wants
+		return nil, fmt.Errorf("-zip-allowlist %s doesn't have any zips in it", path)
+	}
+	return allowed, nil
+}
+
+// checkZipAllowed is -zip-allowlist, for after the ZIP's been normalized
+func (r *Record) checkZipAllowed(allowed map[string]bool) error {
+	if allowed[r.Zip] {
+		return nil
+	}
+	return &FieldError{Field: "ZIP", Value: r.Zip, Err: ErrZipNotAllowed}
+}
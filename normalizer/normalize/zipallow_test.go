@@ -0,0 +1,103 @@
+package normalize
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadZipAllowlist(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		format  string
+		mode    string
+		want    map[string]bool
+		wantErr bool
+	}{
+		{"padded", "94121\n501\n", zipFormatUS, zipModePad5, map[string]bool{"94121": true, "00501": true}, false},
+		{"comments and blanks", "# service area\n\n  94121  \n#94122\n", zipFormatUS, zipModePad5, map[string]bool{"94121": true}, false},
+		{"stripped", "00501\n", zipFormatUS, zipModeStrip, map[string]bool{"501": true}, false},
+		{"canadian", "k1a0b1\n", zipFormatCA, zipModePad5, map[string]bool{"K1A 0B1": true}, false},
+		{"passthrough", "501\n", zipFormatPassthrough, zipModePad5, map[string]bool{"501": true}, false},
+		{"not a zip", "94121\nnowhere\n", zipFormatUS, zipModeStrip, nil, true},
+		{"no zips", "# nothing yet\n\n", zipFormatUS, zipModePad5, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadZipAllowlist(writeTestFile(t, "allow.txt", tt.file), tt.format, tt.mode)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("got error %v, want one %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if _, err := loadZipAllowlist(filepath.Join(t.TempDir(), "missing.txt"), zipFormatUS, zipModePad5); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestZipAllowlist(t *testing.T) {
+	in := testHeader + testRow +
+		strings.Replace(testRow, "94121", "501", 1) +
+		strings.Replace(testRow, "94121", "10001", 1)
+	tests := []struct {
+		name     string
+		file     string
+		args     []string
+		wantZips []string
+		wantBad  []int
+	}{
+		{"normalized", "94121\n00501\n", nil, []string{"94121", "00501"}, []int{4}},
+		{"stripped", "94121\n501\n", []string{"-zip-mode", "strip"}, []string{"94121", "501"}, []int{4}},
+		{"not normalized", "501\n", []string{"-no-normalize-zip"}, []string{"501"}, []int{2, 4}},
+		{"everything", "94121\n501\n10001\n", nil, []string{"94121", "00501", "10001"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-zip-allowlist", writeTestFile(t, "allow.txt", tt.file)}, tt.args...)
+			records := outputRecords(t, testConfig(t, args...), in)
+			var zips []string
+			for _, r := range records[1:] {
+				zips = append(zips, r[2])
+			}
+			if !reflect.DeepEqual(zips, tt.wantZips) {
+				t.Errorf("got zips %v, want %v", zips, tt.wantZips)
+			}
+			var bad []int
+			for _, e := range readErrorReport(t, in, args...) {
+				if e.Type != "zip_allowlist" || e.Field != "ZIP" {
+					t.Errorf("got %+v, want a zip_allowlist error", e)
+				}
+				bad = append(bad, e.Line)
+			}
+			if !reflect.DeepEqual(bad, tt.wantBad) {
+				t.Errorf("got rejected lines %v, want %v", bad, tt.wantBad)
+			}
+		})
+	}
+}
+
+func TestZipAllowlistFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		err  string
+	}{
+		{"empty", "", nil, "doesn't have any zips"},
+		{"bad zip", "94121\nnowhere\n", []string{"-zip-mode", "strip"}, "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"-zip-allowlist", writeTestFile(t, "allow.txt", tt.file)}, tt.args...)
+			err := configError(t, args...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
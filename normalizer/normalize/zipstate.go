@@ -0,0 +1,99 @@
+package normalize
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// zipStateRange is a run of three-digit ZIP prefixes that are all in one
+// state (or territory, or military mail region)
+type zipStateRange struct {
+	lo, hi int
+	state  string
+}
+
+// zipStates is which state each ZIP prefix is in, from the USPS prefix list.
+// Prefixes that aren't in it aren't in use, and can't be checked. A few
+// straddle state lines in real life, which this can't see
+var zipStates = []zipStateRange{
+	{5, 5, "NY"}, {6, 7, "PR"}, {8, 8, "VI"}, {9, 9, "PR"},
+	{10, 27, "MA"}, {28, 29, "RI"}, {30, 38, "NH"}, {39, 49, "ME"},
+	{50, 54, "VT"}, {55, 55, "MA"}, {56, 59, "VT"}, {60, 69, "CT"},
+	{70, 89, "NJ"}, {90, 99, "AE"}, {100, 149, "NY"}, {150, 196, "PA"},
+	{197, 199, "DE"}, {200, 200, "DC"}, {201, 201, "VA"}, {202, 205, "DC"},
+	{206, 219, "MD"}, {220, 246, "VA"}, {247, 268, "WV"}, {270, 289, "NC"},
+	{290, 299, "SC"}, {300, 319, "GA"}, {320, 339, "FL"}, {340, 340, "AA"},
+	{341, 349, "FL"}, {350, 369, "AL"}, {370, 385, "TN"}, {386, 397, "MS"},
+	{398, 399, "GA"}, {400, 427, "KY"}, {430, 459, "OH"}, {460, 479, "IN"},
+	{480, 499, "MI"}, {500, 528, "IA"}, {530, 549, "WI"}, {550, 567, "MN"},
+	{569, 569, "DC"}, {570, 577, "SD"}, {580, 588, "ND"}, {590, 599, "MT"},
+	{600, 629, "IL"}, {630, 658, "MO"}, {660, 679, "KS"}, {680, 693, "NE"},
+	{700, 714, "LA"}, {716, 729, "AR"}, {730, 732, "OK"}, {733, 733, "TX"},
+	{734, 749, "OK"}, {750, 799, "TX"}, {800, 816, "CO"}, {820, 831, "WY"},
+	{832, 838, "ID"}, {840, 847, "UT"}, {850, 865, "AZ"}, {870, 884, "NM"},
+	{885, 885, "TX"}, {889, 898, "NV"}, {900, 961, "CA"}, {962, 966, "AP"},
+	{967, 968, "HI"}, {969, 969, "GU"}, {970, 979, "OR"}, {980, 994, "WA"},
+	{995, 999, "AK"},
+}
+
+// stateCodes is every state code zipStates uses, for spotting one in an
+// Address. The military ones are left out: AA and friends turn up at the end
+// of addresses as placeholders far more often than as real APO addresses
+var stateCodes = func() map[string]bool {
+	codes := make(map[string]bool)
+	for _, r := range zipStates {
+		codes[r.state] = true
+	}
+	delete(codes, "AA")
+	delete(codes, "AE")
+	delete(codes, "AP")
+	return codes
+}()
+
+// zipState is the state a US ZIP is in, going by its first three digits.
+// Zips that had their leading zeroes stripped get them back first. ok is
+// false if it isn't a ZIP, or the prefix isn't in use
+func zipState(zip string) (state string, ok bool) {
+	if i := strings.IndexAny(zip, "- "); i >= 0 {
+		zip = zip[:i]
+	}
+	if len(zip) > 5 || !digits.MatchString(zip) {
+		return "", false
+	}
+	prefix, _ := strconv.Atoi((strings.Repeat("0", 5-len(zip)) + zip)[:3])
+	for _, r := range zipStates {
+		if prefix >= r.lo && prefix <= r.hi {
+			return r.state, true
+		}
+	}
+	return "", false
+}
+
+// addressState is the state an Address ends with, if it's written the usual
+// way: a two-letter code starting the last comma-separated part, maybe with
+// the ZIP after it, like "Springfield, IL 62701". ok is false if there isn't
+// one, which is most of the time for anything written another way
+func addressState(address string) (state string, ok bool) {
+	parts := strings.Split(address, ",")
+	words := strings.Fields(parts[len(parts)-1])
+	if len(parts) < 2 || len(words) == 0 {
+		return "", false
+	}
+	state = strings.ToUpper(strings.TrimRight(words[0], "."))
+	return state, stateCodes[state]
+}
+
+// checkZipState is -check-zip-state: if the Address names a state and the ZIP
+// is in a different one, the row's rejected
+func (r *Record) checkZipState() error {
+	named, ok := addressState(r.Address)
+	if !ok {
+		return nil
+	}
+	state, ok := zipState(r.Zip)
+	if !ok || state == named {
+		return nil
+	}
+	return &FieldError{Field: "ZIP", Value: r.Zip, Err: fmt.Errorf("%w: %s, not %s", ErrZipState, state, named)}
+}
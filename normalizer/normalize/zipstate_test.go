@@ -0,0 +1,89 @@
+package normalize
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestZipState(t *testing.T) {
+	tests := []struct {
+		zip   string
+		state string
+		ok    bool
+	}{
+		{"94121", "CA", true},
+		{"62701", "IL", true},
+		{"00501", "NY", true},
+		// Leading zeroes stripped
+		{"501", "NY", true},
+		{"02134-1234", "MA", true},
+		{"10001 1234", "NY", true},
+		{"09012", "AE", true},
+		// Not in use
+		{"00100", "", false},
+		{"71500", "", false},
+		{"9412x", "", false},
+		{"941210", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		state, ok := zipState(tt.zip)
+		if state != tt.state || ok != tt.ok {
+			t.Errorf("zipState(%q) = %q, %v, want %q, %v", tt.zip, state, ok, tt.state, tt.ok)
+		}
+	}
+}
+
+func TestAddressState(t *testing.T) {
+	tests := []struct {
+		address string
+		state   string
+		ok      bool
+	}{
+		{"1 Main St, Springfield, IL", "IL", true},
+		{"1 Main St, Springfield, il 62701", "IL", true},
+		{"1 Main St, Springfield, Ill.", "ILL", false},
+		{"1 Main St, Springfield, Wash.", "WASH", false},
+		{"1 Main St, Springfield", "SPRINGFIELD", false},
+		{"PSC 1234, Box 5678, APO AE 09012", "APO", false},
+		{"Unit 1, FPO, AE", "AE", false},
+		{"123 4th St", "", false},
+		{"1 Main St,", "", false},
+	}
+	for _, tt := range tests {
+		state, ok := addressState(tt.address)
+		if ok != tt.ok || (ok && state != tt.state) {
+			t.Errorf("addressState(%q) = %q, %v, want %q, %v", tt.address, state, ok, tt.state, tt.ok)
+		}
+	}
+}
+
+func TestCheckZipStateFlag(t *testing.T) {
+	tests := []struct {
+		address string
+		zip     string
+		reject  bool
+	}{
+		{"1 Main St, San Francisco, CA", "94121", false},
+		{"1 Main St, Springfield, IL 62701", "94121", true},
+		// Nothing to check against
+		{"123 4th St", "62701", false},
+		{"1 Main St, Springfield, IL", "00100", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address+"/"+tt.zip, func(t *testing.T) {
+			address := `"` + tt.address + `"`
+			row := strings.Replace(strings.Replace(testRow, "123 4th St", address, 1), "94121", tt.zip, 1)
+			entries := readErrorReport(t, testHeader+row, "-check-zip-state")
+			if tt.reject != (len(entries) == 1) {
+				t.Fatalf("got %+v, want rejected %v", entries, tt.reject)
+			}
+			if tt.reject && (entries[0].Type != "zip_state" || entries[0].Field != "ZIP") {
+				t.Errorf("got %+v, want a zip_state error on ZIP", entries[0])
+			}
+		})
+	}
+	if err := configError(t, "-check-zip-state", "-zip-format", "uk"); err == nil {
+		t.Error("expected an error with -zip-format uk")
+	}
+}